@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// selectConfigFiles narrows configFiles down to the names, matched against
+// the file base names, listed with --accounts, in the order they are listed,
+// and leaves out the ones listed with --exclude. An empty include list keeps
+// every file.
+func selectConfigFiles(configFiles []string, include, exclude []string) ([]string, error) {
+	byName := make(map[string]string, len(configFiles))
+	for _, path := range configFiles {
+		byName[filepath.Base(path)] = path
+	}
+	for _, name := range slices.Concat(include, exclude) {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("unknown account config: %s", name)
+		}
+	}
+
+	selected := configFiles
+	if len(include) > 0 {
+		selected = nil
+		for _, name := range include {
+			if !slices.Contains(selected, byName[name]) {
+				selected = append(selected, byName[name])
+			}
+		}
+	}
+	selected = slices.DeleteFunc(slices.Clone(selected), func(path string) bool {
+		return slices.Contains(exclude, filepath.Base(path))
+	})
+	if len(selected) == 0 {
+		return nil, errors.New("no account configs left to load")
+	}
+	return selected, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// account holds the credentials and connection settings of one config file.
+// Accounts log in at startup, or with lazy_login the first time they're
+// used.
+type account struct {
+	configPath string
+	config     *config.AppConfig
+	username   string
+	auth       *beatport.Auth
+	bp         *beatport.Beatport
+	bs         *beatport.Beatport
+	exhausted  bool
+
+	loggedIn   bool
+	loginErr   error
+	loginMutex sync.Mutex
+	// retryErr is the last login error other than rejected credentials,
+	// returned until retryAt instead of logging in again.
+	retryErr error
+	retryAt  time.Time
+
+	httpClient     *http.Client
+	httpClientErr  error
+	httpClientOnce sync.Once
+
+	owned      map[beatport.Store]*ownedTracks
+	ownedMutex sync.Mutex
+
+	downloaded atomic.Int64
+	skipped    atomic.Int64
+	failed     atomic.Int64
+	// bytes counts the bytes transferred for the account's downloads,
+	// excluding partial files resumed from an earlier attempt.
+	bytes atomic.Int64
+}
+
+func newAccount(configPath string, cfg *config.AppConfig, cachePath string) *account {
+	auth := beatport.NewAuth(cfg.Username, cfg.Password, cachePath)
+	return &account{
+		configPath: configPath,
+		config:     cfg,
+		username:   cfg.Username,
+		auth:       auth,
+		bp:         beatport.New(beatport.StoreBeatport, cfg.Proxy, auth),
+		bs:         beatport.New(beatport.StoreBeatsource, cfg.Proxy, auth),
+	}
+}
+
+// login logs the account in the first time it is called, and returns the
+// same result on later calls once it succeeded or the credentials were
+// rejected. Other errors, e.g. a timeout or a proxy error, are retried on
+// the first call after loginRetryDelay.
+func (acc *account) login(ctx context.Context) error {
+	acc.loginMutex.Lock()
+	defer acc.loginMutex.Unlock()
+	if acc.loggedIn || acc.loginErr != nil {
+		return acc.loginErr
+	}
+	if time.Now().Before(acc.retryAt) {
+		return acc.retryErr
+	}
+	// login_timeout bounds the login, so an unresponsive auth endpoint
+	// doesn't hold up the startup or the first download of a lazy account.
+	timeout := time.Duration(acc.config.LoginTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := acc.auth.Init(ctx, acc.bp)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("login of %s %w after %s", acc.username, errLoginTimedOut, timeout)
+	}
+	switch {
+	case err == nil:
+		acc.loggedIn = true
+	case credentialsRejected(err):
+		acc.loginErr = err
+	default:
+		acc.retryErr = err
+		acc.retryAt = time.Now().Add(loginRetryDelay)
+	}
+	return err
+}
+
+// loginRetryDelay is how long a login that failed for another reason than
+// rejected credentials isn't tried again.
+const loginRetryDelay = 30 * time.Second
+
+// errLoginTimedOut is wrapped by the error of a login that took longer
+// than login_timeout.
+var errLoginTimedOut = errors.New("timed out")
+
+// credentialsRejected reports whether err is Beatport rejecting the
+// account's username or password, which logging in again won't fix.
+func credentialsRejected(err error) bool {
+	var statusErr *beatport.StatusError
+	switch {
+	case errors.Is(err, beatport.ErrReloginFailed), errors.Is(err, beatport.ErrInvalidSessionCookie):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+			statusErr.StatusCode != http.StatusTooManyRequests
+	}
+	return false
+}
+
+// maxConcurrentLogins bounds the number of accounts logging in at once.
+const maxConcurrentLogins = 4
+
+// loginAccounts logs the accounts in concurrently and returns the login error
+// of each account, in the same order as accounts.
+func loginAccounts(ctx context.Context, accounts []*account) []error {
+	errs := make([]error, len(accounts))
+	sem := make(chan struct{}, maxConcurrentLogins)
+	var wg sync.WaitGroup
+	for i, acc := range accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = acc.login(ctx)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// downloadClient returns the account's download client, built for its proxy
+// and worker limits the first time it's needed.
+func (acc *account) downloadClient() (proxiedClient, error) {
+	acc.httpClientOnce.Do(func() {
+		cfg := acc.config
+		acc.httpClient, acc.httpClientErr = NewHttpClient(cfg.Proxy, cfg.MaxDownloadWorkers*max(cfg.DownloadSegments, 1))
+	})
+	return proxiedClient{client: acc.httpClient, proxy: acc.config.Proxy}, acc.httpClientErr
+}
+
+// client returns the account's own client for the store of inst.
+func (acc *account) client(inst *beatport.Beatport) *beatport.Beatport {
+	if inst.Store() == beatport.StoreBeatsource {
+		return acc.bs
+	}
+	return acc.bp
+}
+
+var ErrNoAccountsLeft = errors.New("no accounts left to switch to")
+
+// quotaMessages are parts of the error details the API returns with a 403
+// when the account's download limit or subscription quota is used up. A
+// track that the subscription doesn't cover is refused with a message that
+// mentions the subscription too, so that word alone doesn't count.
+var quotaMessages = []string{"limit reached", "limit exceeded", "quota"}
+
+// quotaExceeded reports whether err means the account can't download
+// anything else for now, rather than just the requested track.
+func quotaExceeded(err error) bool {
+	var statusErr *beatport.StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	if statusErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	detail := strings.ToLower(statusErr.Detail)
+	return statusErr.StatusCode == http.StatusForbidden &&
+		slices.ContainsFunc(quotaMessages, func(message string) bool {
+			return strings.Contains(detail, message)
+		})
+}
+
+// trackRefused reports whether err is a 403 for the requested track only,
+// such as a track that is region locked, not covered by the subscription or
+// removed. Another account may still get it, but the active account keeps
+// serving the other tracks.
+func trackRefused(err error) bool {
+	var statusErr *beatport.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusForbidden && !quotaExceeded(err)
+}
+
+func (app *application) currentAccount() *account {
+	app.accountsMutex.Lock()
+	defer app.accountsMutex.Unlock()
+	return app.accounts[app.accountIndex]
+}
+
+// switchAccount makes the next account that isn't exhausted or in tried the
+// active one, logging in to it if needed. If another worker has already
+// switched away from the from account, the new active account is returned
+// instead. A from account that ran out of quota is marked exhausted and
+// never switched back to. Accounts whose credentials are rejected are
+// marked exhausted too, while accounts that fail to log in for another
+// reason are only added to tried.
+//
+// The login happens without holding accountsMutex, so the other workers
+// aren't held up by a slow auth endpoint; the state is checked again once
+// it's done, as another worker may have switched in the meantime.
+//
+// The download client is rebuilt for the new account's proxy, and the worker
+// semaphores are resized to its worker limits. Workers already running keep
+// their slots: a smaller limit only applies once enough of them finish.
+func (app *application) switchAccount(from *account, tried map[*account]bool, exhausted bool) (*account, error) {
+	app.accountsMutex.Lock()
+	defer app.accountsMutex.Unlock()
+
+	if exhausted {
+		from.exhausted = true
+	}
+	for {
+		active := app.accounts[app.accountIndex]
+		if active != from && !active.exhausted && !tried[active] {
+			return active, nil
+		}
+
+		index := -1
+		for i := 1; i < len(app.accounts); i++ {
+			candidate := (app.accountIndex + i) % len(app.accounts)
+			acc := app.accounts[candidate]
+			if !acc.exhausted && !tried[acc] && !app.quotaReached(acc) {
+				index = candidate
+				break
+			}
+		}
+		if index < 0 {
+			return nil, ErrNoAccountsLeft
+		}
+		acc := app.accounts[index]
+
+		app.accountsMutex.Unlock()
+		loginErr := acc.login(app.ctx)
+		app.accountsMutex.Lock()
+
+		err := loginErr
+		if err == nil && app.accounts[app.accountIndex] == active && !acc.exhausted {
+			err = app.activateAccount(acc)
+		}
+		if err != nil {
+			app.LogError("switch account", fmt.Errorf("%s: %w", acc.configPath, err))
+			if loginErr != nil && !credentialsRejected(loginErr) {
+				tried[acc] = true
+			} else {
+				acc.exhausted = true
+			}
+			continue
+		}
+		if app.accounts[app.accountIndex] != active || acc.exhausted {
+			// Another worker switched while this one was logging in.
+			continue
+		}
+		app.accountIndex = index
+		app.LogInfo(fmt.Sprintf("Switched to account %s (%s)", acc.username, acc.configPath))
+		return acc, nil
+	}
+}
+
+// activateAccount points the clients and worker limits at acc, which must be
+// logged in already.
+func (app *application) activateAccount(acc *account) error {
+	cfg := acc.config
+	client, err := acc.downloadClient()
+	if err != nil {
+		return err
+	}
+
+	app.httpClientMutex.Lock()
+	app.httpClient = client.client
+	app.httpProxy = client.proxy
+	app.httpClientMutex.Unlock()
+
+	app.downloadSem.resize(cfg.MaxDownloadWorkers)
+	app.globalSem.resize(cfg.MaxGlobalWorkers)
+
+	for _, inst := range []*beatport.Beatport{app.bp, app.bs} {
+		inst.SetProxy(cfg.Proxy)
+		inst.SetAuth(acc.auth)
+	}
+	return nil
+}
+
+// rotateAccount returns the account after the one it returned last time,
+// for the roundrobin account strategy. Accounts that are exhausted or
+// reached their monthly quota are skipped, and accounts that haven't logged
+// in yet log in first, and are skipped if that fails. Unlike switchAccount, it doesn't change the active
+// account. If no account is usable, the active account is returned.
+func (app *application) rotateAccount() *account {
+	for range app.accounts {
+		app.accountsMutex.Lock()
+		acc := app.accounts[app.nextAccount]
+		app.nextAccount = (app.nextAccount + 1) % len(app.accounts)
+		skip := acc.exhausted || app.quotaReached(acc)
+		app.accountsMutex.Unlock()
+		if skip {
+			continue
+		}
+		// Logging in without accountsMutex, like switchAccount.
+		if err := acc.login(app.ctx); err != nil {
+			app.LogError("log in", fmt.Errorf("%s: %w", acc.configPath, err))
+			if credentialsRejected(err) {
+				app.accountsMutex.Lock()
+				acc.exhausted = true
+				app.accountsMutex.Unlock()
+			}
+			continue
+		}
+		return acc
+	}
+	return app.currentAccount()
+}
+
+// quotaReached reports whether acc downloaded its monthly_quota of tracks
+// this month.
+func (app *application) quotaReached(acc *account) bool {
+	quota := int64(acc.config.MonthlyQuota)
+	return quota > 0 && app.usage.Month(acc.username) >= quota
+}
+
+// otherAccount returns the next account after the active one that isn't
+// exhausted, in tried or out of monthly quota, logging in to it if needed,
+// without changing the active account.
+func (app *application) otherAccount(tried map[*account]bool) (*account, error) {
+	app.accountsMutex.Lock()
+	var candidates []*account
+	for i := 1; i < len(app.accounts); i++ {
+		acc := app.accounts[(app.accountIndex+i)%len(app.accounts)]
+		if !acc.exhausted && !tried[acc] && !app.quotaReached(acc) {
+			candidates = append(candidates, acc)
+		}
+	}
+	app.accountsMutex.Unlock()
+
+	for _, acc := range candidates {
+		if err := acc.login(app.ctx); err != nil {
+			app.LogError("log in", fmt.Errorf("%s: %w", acc.configPath, err))
+			continue
+		}
+		return acc, nil
+	}
+	return nil, ErrNoAccountsLeft
+}
+
+// withAccount calls fn with the active account, or with the next account in
+// rotation for the roundrobin strategy. While the account fails to log in,
+// also after its session was rejected, or runs out of quota, it switches to
+// the next account and calls fn again. A track refused with any other 403 is
+// retried with the other accounts without switching. Every account is tried
+// at most once. It returns the account of the last call.
+func (app *application) withAccount(url string, fn func(acc *account) error) (*account, error) {
+	acc := app.currentAccount()
+	if app.config.AccountStrategy == "roundrobin" && len(app.accounts) > 1 {
+		acc = app.rotateAccount()
+	}
+	tried := make(map[*account]bool)
+	if app.quotaReached(acc) && len(app.accounts) > 1 {
+		if next, err := app.switchAccount(acc, tried, true); err == nil {
+			acc = next
+		}
+	}
+	for {
+		err := acc.login(app.ctx)
+		loginFailed := err != nil
+		if !loginFailed {
+			err = fn(acc)
+			loginFailed = errors.Is(err, beatport.ErrReloginFailed)
+		}
+		if err == nil || len(app.accounts) < 2 {
+			return acc, err
+		}
+		refused := trackRefused(err)
+		if !loginFailed && !quotaExceeded(err) && !refused {
+			return acc, err
+		}
+		tried[acc] = true
+		var next *account
+		var switchErr error
+		if refused {
+			next, switchErr = app.otherAccount(tried)
+		} else {
+			// An account that failed to log in because of e.g. a timeout
+			// isn't exhausted, and may be used again for later tracks.
+			exhausted := !loginFailed || credentialsRejected(err)
+			next, switchErr = app.switchAccount(acc, tried, exhausted)
+		}
+		if switchErr != nil {
+			return acc, err
+		}
+		switch {
+		case loginFailed:
+			app.infoLogWrapper(url, fmt.Sprintf("account %s failed to log in (%v), retrying with %s", acc.username, err, next.username))
+		case refused:
+			app.infoLogWrapper(url, fmt.Sprintf("account %s was refused the track (%v), retrying with %s", acc.username, err, next.username))
+		default:
+			app.infoLogWrapper(url, fmt.Sprintf("account %s ran out of quota (%v), retrying with %s", acc.username, err, next.username))
+		}
+		acc = next
+	}
+}
+
+// loginActiveAccount makes sure the active account is logged in before a
+// batch, switching to the next account while logging in fails. It only has
+// work to do with lazy_login.
+func (app *application) loginActiveAccount() error {
+	tried := make(map[*account]bool)
+	acc := app.currentAccount()
+	for {
+		err := acc.login(app.ctx)
+		if err == nil {
+			return nil
+		}
+		app.LogError("log in", fmt.Errorf("%s: %w", acc.configPath, err))
+		tried[acc] = true
+		if acc, err = app.switchAccount(acc, tried, credentialsRejected(err)); err != nil {
+			return err
+		}
+	}
+}
+
+// printAccountSummary prints the account table after a batch, if more than
+// one account is configured.
+func (app *application) printAccountSummary() {
+	if len(app.accounts) < 2 {
+		return
+	}
+	app.printAccounts()
+}
+
+// printAccounts prints the tracks and bytes each account downloaded during
+// this run, and the tracks it downloaded this month.
+func (app *application) printAccounts() {
+	width := len("Account")
+	for _, acc := range app.accounts {
+		width = max(width, len(acc.username)+1)
+	}
+	fmt.Printf("%-*s  %10s  %7s  %6s  %10s  %10s\n", width, "Account", "Downloaded", "Skipped", "Failed", "Size", "This month")
+	fmt.Println(strings.Repeat("-", width+53))
+	for _, acc := range app.accounts {
+		username := acc.username
+		if acc.exhausted {
+			username += "*"
+		}
+		month := strconv.FormatInt(app.usage.Month(acc.username), 10)
+		if quota := acc.config.MonthlyQuota; quota > 0 {
+			month += "/" + strconv.Itoa(quota)
+		}
+		fmt.Printf(
+			"%-*s  %10d  %7d  %6d  %10s  %10s\n",
+			width, username, acc.downloaded.Load(), acc.skipped.Load(), acc.failed.Load(),
+			formatByteSize(uint64(acc.bytes.Load())), month,
+		)
+	}
+	if slices.ContainsFunc(app.accounts, func(acc *account) bool { return acc.exhausted }) {
+		fmt.Println("* exhausted or failed to log in")
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestWithAccount(t *testing.T) {
+	newApp := func(usernames ...string) *application {
+		app := &application{
+			config:      &config.AppConfig{},
+			ctx:         context.Background(),
+			logWriter:   io.Discard,
+			downloadSem: newSemaphore(1),
+			globalSem:   newSemaphore(1),
+		}
+		for i, username := range usernames {
+			acc := newAccount(username+".yml", &config.AppConfig{
+				Username:           username,
+				MaxDownloadWorkers: i + 1,
+				MaxGlobalWorkers:   i + 1,
+			}, "")
+			acc.loggedIn = true
+			app.accounts = append(app.accounts, acc)
+		}
+		app.bp = beatport.New(beatport.StoreBeatport, "", app.accounts[0].auth)
+		app.bs = beatport.New(beatport.StoreBeatsource, "", app.accounts[0].auth)
+		return app
+	}
+	forbidden := &beatport.StatusError{StatusCode: 403}
+	limitReached := &beatport.StatusError{StatusCode: 403, Detail: "Download limit reached"}
+
+	t.Run("Switch to the next account", func(t *testing.T) {
+		app := newApp("first", "second")
+		acc, err := app.withAccount("", func(acc *account) error {
+			if acc.username == "first" {
+				return limitReached
+			}
+			return nil
+		})
+		if err != nil || acc.username != "second" {
+			t.Fatalf("withAccount() = %v, %v, expected second account", acc.username, err)
+		}
+		if current := app.currentAccount(); current != acc {
+			t.Errorf("Active account is %s, expected second", current.username)
+		}
+		if app.downloadSem.size != 2 || app.globalSem.size != 2 {
+			t.Errorf("Semaphore sizes %d and %d, expected the second account's 2", app.downloadSem.size, app.globalSem.size)
+		}
+	})
+
+	t.Run("Keep the proxy of each account", func(t *testing.T) {
+		app := newApp("first", "second")
+		app.accounts[0].config.Proxy = "http://first-proxy:8080"
+		app.accounts[1].config.Proxy = "http://second-proxy:8080"
+		app.config = app.accounts[0].config
+		app.withAccount("", func(acc *account) error {
+			if acc.username == "first" {
+				return limitReached
+			}
+			return nil
+		})
+		if proxy := app.downloadClient().proxy; proxy != "http://second-proxy:8080" {
+			t.Errorf("Download client proxy is %q, expected the second account's", proxy)
+		}
+		if proxy := app.accounts[0].config.Proxy; proxy != "http://first-proxy:8080" {
+			t.Errorf("First account's proxy changed to %q", proxy)
+		}
+	})
+
+	t.Run("Try every account once", func(t *testing.T) {
+		app := newApp("first", "second", "third")
+		calls := make(map[string]int)
+		_, err := app.withAccount("", func(acc *account) error {
+			calls[acc.username]++
+			return forbidden
+		})
+		if err != forbidden {
+			t.Fatalf("withAccount() error = %v, expected %v", err, forbidden)
+		}
+		for _, username := range []string{"first", "second", "third"} {
+			if calls[username] != 1 {
+				t.Errorf("Account %s tried %d times, expected once", username, calls[username])
+			}
+		}
+	})
+
+	t.Run("Skip exhausted accounts", func(t *testing.T) {
+		app := newApp("first", "second")
+		app.withAccount("", func(acc *account) error {
+			if acc.username == "first" {
+				return &beatport.StatusError{StatusCode: 429}
+			}
+			return nil
+		})
+		if !app.accounts[0].exhausted {
+			t.Error("First account not marked exhausted after running out of quota")
+		}
+	})
+
+	t.Run("Rotate accounts with the roundrobin strategy", func(t *testing.T) {
+		app := newApp("first", "second")
+		app.config.AccountStrategy = "roundrobin"
+		var served []string
+		for range 4 {
+			acc, _ := app.withAccount("", func(acc *account) error { return nil })
+			served = append(served, acc.username)
+		}
+		if !slices.Equal(served, []string{"first", "second", "first", "second"}) {
+			t.Errorf("Downloads served by %v, expected alternating accounts", served)
+		}
+	})
+
+	t.Run("Exhaust accounts that reached their download limit", func(t *testing.T) {
+		app := newApp("first", "second")
+		app.withAccount("", func(acc *account) error {
+			if acc.username == "first" {
+				return &beatport.StatusError{StatusCode: 403, Detail: "Download limit reached"}
+			}
+			return nil
+		})
+		if !app.accounts[0].exhausted {
+			t.Error("First account not marked exhausted after reaching its download limit")
+		}
+		if app.accounts[1].exhausted {
+			t.Error("Second account marked exhausted")
+		}
+	})
+
+	t.Run("Keep accounts that can't download one track", func(t *testing.T) {
+		app := newApp("first", "second")
+		app.withAccount("", func(acc *account) error {
+			if acc.username == "first" {
+				return &beatport.StatusError{StatusCode: 403, Detail: "This track is not available in your subscription"}
+			}
+			return nil
+		})
+		if app.accounts[0].exhausted {
+			t.Error("First account marked exhausted after a track its subscription doesn't cover")
+		}
+	})
+
+	t.Run("Retry refused tracks without switching accounts", func(t *testing.T) {
+		app := newApp("first", "second")
+		acc, err := app.withAccount("", func(acc *account) error {
+			if acc.username == "first" {
+				return forbidden
+			}
+			return nil
+		})
+		if err != nil || acc.username != "second" {
+			t.Fatalf("withAccount() = %v, %v, expected second account", acc.username, err)
+		}
+		if current := app.currentAccount(); current.username != "first" {
+			t.Errorf("Active account is %s after a refused track, expected first", current.username)
+		}
+	})
+
+	t.Run("Switch accounts that fail to log in", func(t *testing.T) {
+		app := newApp("first", "second")
+		app.accounts[0].loggedIn = false
+		app.accounts[0].loginErr = beatport.ErrInvalidSessionCookie
+		var calls []string
+		acc, err := app.withAccount("", func(acc *account) error {
+			calls = append(calls, acc.username)
+			return nil
+		})
+		if err != nil || acc.username != "second" {
+			t.Fatalf("withAccount() = %v, %v, expected second account", acc.username, err)
+		}
+		if !slices.Equal(calls, []string{"second"}) {
+			t.Errorf("fn called with %v, expected only the second account", calls)
+		}
+		if !app.accounts[0].exhausted {
+			t.Error("First account not marked exhausted after failing to log in")
+		}
+	})
+
+	t.Run("Keep accounts that fail to log in for another reason", func(t *testing.T) {
+		app := newApp("first", "second")
+		app.accounts[0].loggedIn = false
+		app.accounts[0].retryErr = errors.New("proxyconnect: connection refused")
+		app.accounts[0].retryAt = time.Now().Add(time.Minute)
+		acc, err := app.withAccount("", func(acc *account) error { return nil })
+		if err != nil || acc.username != "second" {
+			t.Fatalf("withAccount() = %v, %v, expected second account", acc.username, err)
+		}
+		if app.accounts[0].exhausted {
+			t.Error("First account marked exhausted after a login error other than rejected credentials")
+		}
+	})
+}
+
+func TestSwitchAccountLoginUnlocked(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer proxy.Close()
+	defer close(release)
+
+	first := newAccount("first.yml", &config.AppConfig{Username: "first", MaxDownloadWorkers: 1, MaxGlobalWorkers: 1}, "")
+	first.loggedIn = true
+	second := newAccount("second.yml", &config.AppConfig{
+		Username: "second", Password: "pass", Proxy: proxy.URL, LoginTimeout: 5,
+		MaxDownloadWorkers: 1, MaxGlobalWorkers: 1,
+	}, "")
+	app := &application{
+		config:      &config.AppConfig{},
+		ctx:         context.Background(),
+		logWriter:   io.Discard,
+		downloadSem: newSemaphore(1),
+		globalSem:   newSemaphore(1),
+		accounts:    []*account{first, second},
+	}
+	go app.switchAccount(first, map[*account]bool{first: true}, true)
+
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The second account didn't start logging in")
+	}
+	current := make(chan *account)
+	go func() { current <- app.currentAccount() }()
+	select {
+	case acc := <-current:
+		if acc != first {
+			t.Errorf("Active account is %s during the login, expected first", acc.username)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("currentAccount() blocked while another account logged in")
+	}
+}
+
+func TestSelectConfigFiles(t *testing.T) {
+	files := []string{"/config/a.yml", "/config/b.yml", "/config/c.json"}
+	tests := []struct {
+		include, exclude string
+		expected         []string
+		err              bool
+	}{
+		{include: "c.json, a.yml", expected: []string{"/config/c.json", "/config/a.yml"}},
+		{exclude: "b.yml", expected: []string{"/config/a.yml", "/config/c.json"}},
+		{include: "a.yml,b.yml", exclude: "a.yml", expected: []string{"/config/b.yml"}},
+		{include: "d.yml", err: true},
+		{exclude: "a.yml,b.yml,c.json", err: true},
+	}
+	for _, test := range tests {
+		selected, err := selectConfigFiles(files, splitList(test.include), splitList(test.exclude))
+		if (err != nil) != test.err || !reflect.DeepEqual(selected, test.expected) {
+			t.Errorf("selectConfigFiles(%q, %q) = %v, %v", test.include, test.exclude, selected, err)
+		}
+	}
+}
+
+func TestLoginTimeout(t *testing.T) {
+	release := make(chan struct{})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer proxy.Close()
+	defer close(release)
+
+	cfg := &config.AppConfig{Username: "user", Password: "pass", Proxy: proxy.URL, LoginTimeout: 1}
+	acc := newAccount("a.yml", cfg, "")
+	start := time.Now()
+	err := acc.login(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "login of user timed out after 1s") {
+		t.Errorf("login() = %v, expected a timeout naming the account", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("login() took %s with a 1 second timeout", elapsed)
+	}
+}
+
+func TestLoginRetry(t *testing.T) {
+	var requests int
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	cfg := &config.AppConfig{Username: "user", Password: "pass", Proxy: proxy.URL, LoginTimeout: 5}
+	acc := newAccount("a.yml", cfg, "")
+	if err := acc.login(context.Background()); err == nil {
+		t.Fatal("login() succeeded through a failing proxy")
+	}
+	if err := acc.login(context.Background()); err == nil || requests != 1 {
+		t.Errorf("login() = %v after %d requests, expected the error again without a request", err, requests)
+	}
+	acc.retryAt = time.Time{}
+	acc.login(context.Background())
+	if requests != 2 {
+		t.Errorf("%d requests, expected the login to be tried again after the delay", requests)
+	}
+	if acc.loginErr != nil {
+		t.Errorf("Proxy error %v cached as a permanent login error", acc.loginErr)
+	}
+}
+
+func TestCredentialsRejected(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{beatport.ErrInvalidSessionCookie, true},
+		{fmt.Errorf("%w: login failed", beatport.ErrReloginFailed), true},
+		{&beatport.StatusError{StatusCode: 401}, true},
+		{&beatport.StatusError{StatusCode: 429}, false},
+		{&beatport.StatusError{StatusCode: 503}, false},
+		{fmt.Errorf("login of user %w after 1s", errLoginTimedOut), false},
+		{errors.New("connection refused"), false},
+	}
+	for _, test := range tests {
+		if got := credentialsRejected(test.err); got != test.expected {
+			t.Errorf("credentialsRejected(%v) = %v, expected %v", test.err, got, test.expected)
+		}
+	}
+}
+
+func TestLoginAtDeadline(t *testing.T) {
+	// A cached token logs the account in without any request, even when the
+	// deadline has already passed.
+	hash := fnv.New64a()
+	hash.Write([]byte("user:pass"))
+	cachePath := filepath.Join(t.TempDir(), "beatportdl-credentials.json")
+	token := fmt.Sprintf(
+		`{"access_token":"token","expires_in":3600,"login_id":%q,"issued_at":%d}`,
+		hex.EncodeToString(hash.Sum(nil)), time.Now().Unix(),
+	)
+	if err := os.WriteFile(cachePath, []byte(token), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.AppConfig{Username: "user", Password: "pass", LoginTimeout: 1}
+	acc := newAccount("a.yml", cfg, cachePath)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if err := acc.login(ctx); err != nil {
+		t.Errorf("login() = %v, expected the cached token to log in", err)
+	}
+}
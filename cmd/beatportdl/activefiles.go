@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// activeFile is a track file claimed by a worker of the current batch.
+type activeFile struct {
+	trackID int64
+	ready   chan struct{}
+	err     error
+}
+
+// errDownloadedInBatch is returned by claimFile when another worker already
+// downloaded the same track to the file during this batch.
+var errDownloadedInBatch = errors.New("already downloaded in this batch")
+
+// claimFile claims path for downloading trackID. While another worker of the
+// batch downloads the same track to path, claimFile waits for it to finish:
+// it returns errDownloadedInBatch if the download succeeded, and tries to
+// claim path again otherwise. If a different track claimed path, both the
+// returned file and error are nil.
+func (app *application) claimFile(path string, trackID int64) (*activeFile, error) {
+	for {
+		app.activeFilesMutex.Lock()
+		file, ok := app.activeFiles[path]
+		if !ok {
+			file = &activeFile{
+				trackID: trackID,
+				ready:   make(chan struct{}),
+			}
+			app.activeFiles[path] = file
+			app.activeFilesMutex.Unlock()
+			return file, nil
+		}
+		app.activeFilesMutex.Unlock()
+
+		if file.trackID != trackID {
+			return nil, nil
+		}
+		select {
+		case <-file.ready:
+		case <-app.ctx.Done():
+			return nil, app.ctx.Err()
+		}
+		if file.err == nil {
+			return nil, errDownloadedInBatch
+		}
+	}
+}
+
+// releaseFile marks the download of a claimed file finished. A successful
+// download keeps the claim for the rest of the batch, so the same track
+// isn't downloaded again; a failed one gives the path up for another worker.
+func (app *application) releaseFile(path string, file *activeFile, err error) {
+	if err != nil {
+		app.activeFilesMutex.Lock()
+		delete(app.activeFiles, path)
+		app.activeFilesMutex.Unlock()
+	}
+	file.err = err
+	close(file.ready)
+}
+
+// fileActive reports whether a worker of the batch claimed path.
+func (app *application) fileActive(path string) bool {
+	app.activeFilesMutex.RLock()
+	defer app.activeFilesMutex.RUnlock()
+	_, ok := app.activeFiles[path]
+	return ok
+}
+
+// claimTrackFile claims the file of a track in directory. If a different
+// track of the batch already claimed the file name, the first numbered name
+// that isn't claimed and doesn't exist yet is used instead.
+func (app *application) claimTrackFile(directory, fileName, extension string, trackID int64) (string, *activeFile, error) {
+	path := fmt.Sprintf("%s/%s%s", directory, fileName, extension)
+	file, err := app.claimFile(path, trackID)
+	for i := 1; file == nil && err == nil; i++ {
+		path = fmt.Sprintf("%s/%s (%d)%s", directory, fileName, i, extension)
+		file, err = app.claimFile(path, trackID)
+		if file != nil {
+			if _, statErr := os.Stat(path); statErr == nil {
+				app.releaseFile(path, file, ErrTrackFileExists)
+				file = nil
+			}
+		}
+	}
+	return path, file, err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClaimFile(t *testing.T) {
+	newApp := func() *application {
+		return &application{
+			ctx:         context.Background(),
+			activeFiles: make(map[string]*activeFile),
+		}
+	}
+
+	t.Run("Only one worker downloads the same track", func(t *testing.T) {
+		app := newApp()
+		var downloads, skipped atomic.Int32
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				file, err := app.claimFile("track.flac", 1)
+				if errors.Is(err, errDownloadedInBatch) {
+					skipped.Add(1)
+					return
+				}
+				if err != nil || file == nil {
+					t.Errorf("claimFile() = %v, %v", file, err)
+					return
+				}
+				downloads.Add(1)
+				app.releaseFile("track.flac", file, nil)
+			}()
+		}
+		wg.Wait()
+		if downloads.Load() != 1 || skipped.Load() != 19 {
+			t.Errorf("%d downloads and %d skipped, expected 1 and 19", downloads.Load(), skipped.Load())
+		}
+	})
+
+	t.Run("Retry after a failed download", func(t *testing.T) {
+		app := newApp()
+		first, _ := app.claimFile("track.flac", 1)
+		claimed := make(chan *activeFile)
+		go func() {
+			file, err := app.claimFile("track.flac", 1)
+			if err != nil {
+				t.Errorf("claimFile() error = %v", err)
+			}
+			claimed <- file
+		}()
+		app.releaseFile("track.flac", first, errors.New("download failed"))
+		if file := <-claimed; file == nil || file == first {
+			t.Error("Waiting worker didn't claim the file after the first download failed")
+		}
+	})
+
+	t.Run("Stop waiting when the batch is canceled", func(t *testing.T) {
+		app := newApp()
+		ctx, cancel := context.WithCancel(context.Background())
+		app.ctx = ctx
+		app.claimFile("track.flac", 1)
+		cancel()
+		if _, err := app.claimFile("track.flac", 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("claimFile() error = %v, expected %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("Number files of different tracks with the same name", func(t *testing.T) {
+		app := newApp()
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "track (1).flac"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		first, _, _ := app.claimTrackFile(dir, "track", ".flac", 1)
+		second, _, err := app.claimTrackFile(dir, "track", ".flac", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first != dir+"/track.flac" || second != dir+"/track (2).flac" {
+			t.Errorf("Claimed %q and %q, expected track.flac and track (2).flac", first, second)
+		}
+	})
+}
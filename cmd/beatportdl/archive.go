@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// downloadArchive is an append-only record of downloaded tracks and releases,
+// one "<store> <type> <id>" entry per line. A nil archive contains nothing.
+type downloadArchive struct {
+	file    *os.File
+	entries map[string]struct{}
+	mutex   sync.Mutex
+}
+
+func openDownloadArchive(path string) (*downloadArchive, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open download archive: %w", err)
+	}
+
+	archive := &downloadArchive{
+		file:    file,
+		entries: make(map[string]struct{}),
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if entry := strings.TrimSpace(scanner.Text()); entry != "" {
+			archive.entries[entry] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("read download archive: %w", err)
+	}
+
+	return archive, nil
+}
+
+func archiveEntry(store beatport.Store, entity string, id int64) string {
+	return fmt.Sprintf("%s %s %d", store, entity, id)
+}
+
+func (a *downloadArchive) Contains(entry string) bool {
+	if a == nil {
+		return false
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, exists := a.entries[entry]
+	return exists
+}
+
+func (a *downloadArchive) Add(entry string) error {
+	if a == nil {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, exists := a.entries[entry]; exists {
+		return nil
+	}
+	if _, err := a.file.WriteString(entry + "\n"); err != nil {
+		return fmt.Errorf("write download archive: %w", err)
+	}
+	a.entries[entry] = struct{}{}
+	return nil
+}
+
+func (a *downloadArchive) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+// recordsDownloads reports whether downloads are added to the download
+// archive and the ISRC index. A dry run downloads nothing and previews
+// aren't the purchased files, so the next real run must not skip what they
+// covered.
+func (app *application) recordsDownloads() bool {
+	return !app.dryRun && !app.previews
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+var supportedArtistTypes = []string{"all", "original", "remix"}
+
+// artistTrackWanted applies the --artist-type, --since and --until filters
+// to a track of an artist catalog.
+func (app *application) artistTrackWanted(track *beatport.Track) bool {
+	if !app.dateWanted(track.PublishDate) {
+		return false
+	}
+	remix := len(track.Remixers) > 0 || strings.Contains(strings.ToLower(track.MixName.String()), "remix")
+	switch app.artistType {
+	case "original":
+		return !remix
+	case "remix":
+		return remix
+	default:
+		return true
+	}
+}
+
+// withPageProgress wraps fetchPage to log each page it fetches, with the
+// number of pages once the first page told it, as artist catalogs can take
+// dozens of pages.
+func withPageProgress[T any](
+	app *application,
+	url string,
+	fetchPage func(ctx context.Context, id int64, page int, params string) (*beatport.Paginated[T], error),
+) func(ctx context.Context, id int64, page int, params string) (*beatport.Paginated[T], error) {
+	var pages int
+	return func(ctx context.Context, id int64, page int, params string) (*beatport.Paginated[T], error) {
+		if pages > 1 {
+			app.infoLogWrapper(url, fmt.Sprintf("fetching page %d/%d", page, pages))
+		}
+		paginated, err := fetchPage(ctx, id, page, params)
+		if err == nil && paginated.PerPage > 0 {
+			pages = (paginated.Count + paginated.PerPage - 1) / paginated.PerPage
+		}
+		return paginated, err
+	}
+}
+
+// artistReleaseList returns the releases of an artist catalog after the
+// --since and --until filters, for --artist-releases.
+func (app *application) artistReleaseList(inst *beatport.Beatport, link *beatport.Link) ([]beatport.Release, error) {
+	var releases []beatport.Release
+	seen := make(map[int64]bool)
+	fetchPage := withPageProgress(app, link.Original, inst.GetArtistReleases)
+	err := ForPaginated[beatport.Release](app.ctx, link.ID, app.dateRangeParams(link.Params, "new_release_date"), fetchPage, func(release beatport.Release, i int) error {
+		if seen[release.ID] || !app.dateWanted(release.Date) {
+			return nil
+		}
+		seen[release.ID] = true
+		releases = append(releases, release)
+		return nil
+	})
+	return releases, err
+}
+
+// artistTrackCount returns the number of tracks of an artist catalog left
+// after filtering.
+func (app *application) artistTrackCount(inst *beatport.Beatport, link *beatport.Link) (int, error) {
+	if app.artistReleases {
+		releases, err := app.artistReleaseList(inst, link)
+		if err != nil {
+			return 0, err
+		}
+		var count int
+		for _, release := range releases {
+			count += release.TrackCount
+		}
+		return count, nil
+	}
+
+	if app.since == "" && app.until == "" && (app.artistType == "" || app.artistType == "all") {
+		page, err := inst.GetArtistTracks(app.ctx, link.ID, 1, link.Params)
+		if err != nil {
+			return 0, err
+		}
+		return page.Count, nil
+	}
+
+	var count int
+	err := ForPaginated[beatport.Track](app.ctx, link.ID, app.dateRangeParams(link.Params, "publish_date"), inst.GetArtistTracks, func(track beatport.Track, i int) error {
+		if app.artistTrackWanted(&track) {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// confirmArtistDownloads asks before downloading each queued artist catalog
+// and drops the declined ones from the queue.
+func (app *application) confirmArtistDownloads() {
+	if !stdinInteractive() {
+		return
+	}
+
+	urls := app.urls[:0:0]
+	for _, entry := range app.urls {
+		url, _ := splitUrlEntry(entry)
+		link, err := app.bp.ParseUrl(url)
+		if err != nil || link.Type != beatport.ArtistLink {
+			urls = append(urls, entry)
+			continue
+		}
+		inst := app.bp
+		if link.Store == beatport.StoreBeatsource {
+			inst = app.bs
+		}
+
+		artist, err := inst.GetArtist(app.ctx, link.ID)
+		if err != nil {
+			urls = append(urls, entry)
+			continue
+		}
+		count, err := app.artistTrackCount(inst, link)
+		if err != nil {
+			urls = append(urls, entry)
+			continue
+		}
+		fmt.Printf("Download %d tracks by %s? [Y/n]: ", count, artist.Name)
+		if answer := strings.TrimSpace(GetLine()); answer == "" || strings.EqualFold(answer, "y") {
+			urls = append(urls, entry)
+		}
+	}
+	app.urls = urls
+}
+
+// listArtistTracks prints the tracks of an artist catalog that would be
+// downloaded, for --list-only.
+func (app *application) listArtistTracks(inst *beatport.Beatport, link *beatport.Link, artist *beatport.Artist, opts urlOptions) {
+	if app.artistReleases {
+		releases, err := app.artistReleaseList(inst, link)
+		if err != nil {
+			app.failureLogWrapper(link.Original, "list artist releases", err, opts)
+			return
+		}
+		app.listReleases(artist.Name, releases)
+		return
+	}
+
+	var count int
+	err := ForPaginated[beatport.Track](app.ctx, link.ID, app.dateRangeParams(link.Params, "publish_date"), inst.GetArtistTracks, func(track beatport.Track, i int) error {
+		if !app.artistTrackWanted(&track) {
+			return nil
+		}
+		count++
+		app.LogInfo(fmt.Sprintf(
+			"%s  %s - %s (%s) [%s]",
+			track.StoreUrl(), track.Artists.Display(0, ""), track.Name, track.MixName, track.PublishDate,
+		))
+		return nil
+	})
+	if err != nil {
+		app.failureLogWrapper(link.Original, "list artist tracks", err, opts)
+		return
+	}
+	app.LogInfo(fmt.Sprintf("%s: %d tracks", artist.Name, count))
+}
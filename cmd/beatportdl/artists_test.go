@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestWithPageProgress(t *testing.T) {
+	var log bytes.Buffer
+	app := &application{ctx: context.Background(), logWriter: &log}
+
+	fetchPage := withPageProgress(app, "artist", func(ctx context.Context, id int64, page int, params string) (*beatport.Paginated[beatport.Track], error) {
+		paginated := &beatport.Paginated[beatport.Track]{Count: 5, PerPage: 2, Results: make([]beatport.Track, 2)}
+		if page < 3 {
+			next := "next"
+			paginated.Next = &next
+		}
+		return paginated, nil
+	})
+	var tracks int
+	err := ForPaginated[beatport.Track](app.ctx, 1, "", fetchPage, func(track beatport.Track, i int) error {
+		tracks++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[artist] fetching page 2/3\n[artist] fetching page 3/3\n"
+	if log.String() != expected {
+		t.Errorf("Logged %q, expected %q", log.String(), expected)
+	}
+	if tracks != 6 {
+		t.Errorf("Processed %d tracks, expected 6", tracks)
+	}
+}
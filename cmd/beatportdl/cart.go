@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// cartURL is the URL queued by the cart command and --cart, which downloads
+// the tracks in the carts of the active account.
+const cartURL = "https://www.beatport.com/cart"
+
+// handleCartLink downloads the tracks in every cart of the account, like
+// the main cart and the hold bin. Tracks that the account can't download
+// are reported as not available, like elsewhere. With --clear-cart, the
+// items of the downloaded tracks are removed from their cart.
+func (app *application) handleCartLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	carts, err := inst.GetCarts(app.ctx)
+	if err != nil {
+		app.failureLogWrapper(link.Original, "fetch carts", err, opts)
+		return
+	}
+
+	for _, cart := range carts {
+		// The items are listed before any is removed, which would shift
+		// the following pages.
+		var items []beatport.CartItem
+		err := ForPaginated[beatport.CartItem](app.ctx, cart.ID, "", inst.GetCartItems, func(item beatport.CartItem, i int) error {
+			if item.Track == nil {
+				app.infoLogWrapper(link.Original, fmt.Sprintf("skipping item %d of cart %s, not a track", item.ID, cart.Name))
+				return nil
+			}
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			app.failureLogWrapper(link.Original, "fetch cart items", err, opts)
+			continue
+		}
+		app.infoLogWrapper(link.Original, fmt.Sprintf("cart %s resolved to %d tracks", cart.Name, len(items)))
+
+		wg := sync.WaitGroup{}
+		for _, item := range items {
+			app.downloadWorker(&wg, func() {
+				location := app.handleLibraryTrack(inst, item.Track, opts)
+				if location == "" || !app.clearCart {
+					return
+				}
+				if err := inst.RemoveCartItem(app.ctx, cart.ID, item.ID); err != nil {
+					app.errorLogWrapper(item.Track.StoreUrl(), "remove cart item", err)
+				}
+			})
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// loginProblem names the kind of problem that made a login fail, for the
+// check subcommand.
+func loginProblem(err error) string {
+	var statusErr *beatport.StatusError
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, errLoginTimedOut):
+		return "timed out"
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests:
+		return "rate-limited"
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500,
+		errors.Is(err, beatport.ErrInvalidSessionCookie):
+		return "bad credentials"
+	case errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || strings.HasPrefix(opErr.Op, "socks")):
+		return "proxy error"
+	}
+	return "error"
+}
+
+// checkAccounts logs every account in, prints the status and subscription
+// of each one, and reports whether all of them logged in.
+func checkAccounts(accounts []*account) bool {
+	fmt.Printf("Checking %d accounts\n", len(accounts))
+	errs := loginAccounts(context.Background(), accounts)
+	ok := true
+	for i, acc := range accounts {
+		name := fmt.Sprintf("%s (%s)", filepath.Base(acc.configPath), acc.username)
+		err := errs[i]
+		var info *beatport.AccountInfo
+		if err == nil {
+			// A cached token is only rejected once it is used, and then
+			// the password is tried again, so the account info is part of
+			// the check, like a proxy that fails after the login.
+			if info, err = acc.accountInfo(); err != nil {
+				err = fmt.Errorf("account info: %w", err)
+			}
+		}
+		if err != nil {
+			ok = false
+			fmt.Printf("❌ %s: %s\n  - %v\n", name, loginProblem(err), err)
+			continue
+		}
+
+		subscription := info.Subscription
+		if subscription == "" {
+			subscription = "none"
+		}
+		fmt.Printf("✅ %s: OK, subscription: %s\n", name, subscription)
+	}
+	return ok
+}
+
+// accountInfo returns the subscription of a logged in account, within
+// login_timeout.
+func (acc *account) accountInfo() (*beatport.AccountInfo, error) {
+	timeout := time.Duration(acc.config.LoginTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return acc.bp.AccountInfo(ctx)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestLoginProblem(t *testing.T) {
+	proxyErr := &url.Error{Op: "Post", URL: "https://api.beatport.com/v4/auth/login/", Err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: errors.New("connection refused")}}
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"Wrong password", fmt.Errorf("login: %w", &beatport.StatusError{StatusCode: 400, Detail: "Invalid credentials"}), "bad credentials"},
+		{"No session cookie", fmt.Errorf("login: %w", beatport.ErrInvalidSessionCookie), "bad credentials"},
+		{"Rejected token", fmt.Errorf("%w: %w", beatport.ErrReloginFailed, fmt.Errorf("login: %w", &beatport.StatusError{StatusCode: 401})), "bad credentials"},
+		{"Rate limited", fmt.Errorf("login: %w", &beatport.StatusError{StatusCode: 429}), "rate-limited"},
+		{"Proxy down", fmt.Errorf("login: request failed: %w", proxyErr), "proxy error"},
+		{"Timeout", fmt.Errorf("login of user %w after 30s", errLoginTimedOut), "timed out"},
+		{"Server error", fmt.Errorf("login: %w", &beatport.StatusError{StatusCode: 502}), "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loginProblem(tt.err); got != tt.expected {
+				t.Errorf("loginProblem(%v) = %q, expected %q", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckAccountsInfoError(t *testing.T) {
+	// The account logged in from its cached token, but the proxy fails
+	// the account info request.
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	cfg := &config.AppConfig{Username: "user", Password: "pass", Proxy: proxy.URL, LoginTimeout: 5}
+	acc := newAccount("a.yml", cfg, "")
+	acc.loggedIn = true
+	if checkAccounts([]*account{acc}) {
+		t.Error("checkAccounts() passed an account whose account info request failed")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+
+	"github.com/google/uuid"
+)
+
+// coverCache keeps a single downloaded copy of each cover image for the
+// duration of a batch, so tracks sharing a release reuse the same file.
+// The zero value is ready to use.
+type coverCache struct {
+	dir     string
+	entries map[string]*cachedCover
+	mutex   sync.Mutex
+}
+
+type cachedCover struct {
+	ready chan struct{}
+	path  string
+	err   error
+}
+
+// get returns the cached file for key, calling fetch to download it into the
+// cache the first time the key is requested. Concurrent callers for the same
+// key wait for the first download instead of starting their own.
+func (c *coverCache) get(key string, fetch func(destination string) error) (string, error) {
+	c.mutex.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*cachedCover)
+	}
+	if entry, ok := c.entries[key]; ok {
+		c.mutex.Unlock()
+		<-entry.ready
+		return entry.path, entry.err
+	}
+	if c.dir == "" {
+		dir, err := os.MkdirTemp("", "beatportdl-covers-")
+		if err != nil {
+			c.mutex.Unlock()
+			return "", fmt.Errorf("create cover cache: %w", err)
+		}
+		c.dir = dir
+	}
+	entry := &cachedCover{
+		ready: make(chan struct{}),
+		path:  filepath.Join(c.dir, uuid.New().String()),
+	}
+	c.entries[key] = entry
+	c.mutex.Unlock()
+
+	entry.err = fetch(entry.path)
+	if entry.err != nil {
+		os.Remove(entry.path)
+		c.mutex.Lock()
+		delete(c.entries, key)
+		c.mutex.Unlock()
+	}
+	close(entry.ready)
+	return entry.path, entry.err
+}
+
+// clear removes all cached covers.
+func (c *coverCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.dir != "" {
+		os.RemoveAll(c.dir)
+	}
+	c.dir = ""
+	c.entries = nil
+}
+
+// fetchCover downloads the cover in the configured size, falling back to the
+// largest size if the requested one isn't available.
+func (app *application) fetchCover(image beatport.Image, destination string) error {
+	err := app.downloadFile(image.FormattedUrl(app.config.CoverSize), destination, "")
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound &&
+		app.config.CoverSize != config.DefaultCoverSize {
+		app.LogInfo(fmt.Sprintf(
+			"cover %s: size %s not available, using %s",
+			image.DynamicURI, app.config.CoverSize, config.DefaultCoverSize,
+		))
+		err = app.downloadFile(image.FormattedUrl(config.DefaultCoverSize), destination, "")
+	}
+	return err
+}
+
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestCoverCacheDedupe(t *testing.T) {
+	var cache coverCache
+	defer cache.clear()
+
+	var fetches atomic.Int32
+	fetch := func(destination string) error {
+		fetches.Add(1)
+		return os.WriteFile(destination, []byte("cover"), 0644)
+	}
+
+	wg := sync.WaitGroup{}
+	paths := make([]string, 8)
+	for i := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := cache.get("release", fetch)
+			if err != nil {
+				t.Error(err)
+			}
+			paths[i] = path
+		}()
+	}
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("fetched %d times, want 1", n)
+	}
+	for _, path := range paths[1:] {
+		if path != paths[0] {
+			t.Fatalf("got different paths %q and %q", path, paths[0])
+		}
+	}
+
+	dir := cache.dir
+	cache.clear()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("cache directory not removed: %v", err)
+	}
+}
+
+func TestHandleCoverFile(t *testing.T) {
+	app := &application{
+		config:      &config.AppConfig{KeepCover: true, SortByContext: true, CoverFilename: "folder.jpg"},
+		ctx:         context.Background(),
+		activeFiles: make(map[string]*activeFile),
+	}
+	dir := t.TempDir()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		copyPath := filepath.Join(dir, fmt.Sprintf("copy%d", i))
+		os.WriteFile(copyPath, []byte("cover"), 0644)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := app.handleCoverFile(copyPath); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "folder.jpg" {
+		t.Errorf("Directory has %v, expected only folder.jpg", entries)
+	}
+}
+
+func TestDirectoryTemplateCovers(t *testing.T) {
+	downloadsDir := t.TempDir()
+	app := &application{
+		config: &config.AppConfig{
+			DownloadsDirectory: downloadsDir,
+			DirectoryTemplate:  "{label}/{release}",
+			SortByContext:      true,
+			KeepCover:          true,
+			CoverFilename:      "cover.jpg",
+		},
+		ctx:         context.Background(),
+		activeFiles: make(map[string]*activeFile),
+	}
+
+	release := &beatport.Release{Name: "Release", Label: beatport.Label{Name: "Label"}}
+	dir, err := app.setupDownloadsDirectory(downloadsDir, release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != downloadsDir {
+		t.Errorf("Got release directory %q, expected %q", dir, downloadsDir)
+	}
+
+	cover := filepath.Join(dir, "release-cover")
+	os.WriteFile(cover, []byte("cover"), 0644)
+	trackDir := filepath.Join(downloadsDir, "Label", "Release")
+	os.MkdirAll(trackDir, 0755)
+	if err := app.keepTrackCover(cover, trackDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.handleCoverFile(cover); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(downloadsDir)
+	if len(entries) != 1 || entries[0].Name() != "Label" {
+		t.Errorf("Downloads directory has %v, expected only Label", entries)
+	}
+	entries, _ = os.ReadDir(trackDir)
+	if len(entries) != 1 || entries[0].Name() != "cover.jpg" {
+		t.Errorf("Track directory has %v, expected only cover.jpg", entries)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// dateWanted applies the --since and --until filters to a YYYY-MM-DD date,
+// or a timestamp starting with one.
+func (app *application) dateWanted(date string) bool {
+	date = date[:min(len(date), len("2006-01-02"))]
+	return (app.since == "" || date >= app.since) && (app.until == "" || date <= app.until)
+}
+
+// dateRangeParams adds the --since and --until range on field to the query
+// params of a catalog listing, so the API leaves out what's outside of it
+// instead of paging through the whole catalog. Results are still checked
+// with dateWanted, for endpoints that ignore the filter.
+func (app *application) dateRangeParams(params, field string) string {
+	if app.since == "" && app.until == "" {
+		return params
+	}
+	dateRange := fmt.Sprintf("%s=%s:%s", field, cmp.Or(app.since, "1900-01-01"), cmp.Or(app.until, "9999-12-31"))
+	if params == "" {
+		return dateRange
+	}
+	return params + "&" + dateRange
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestDateRange(t *testing.T) {
+	app := &application{since: "2024-01-01", until: "2024-06-30"}
+	for date, wanted := range map[string]bool{
+		"2023-12-31":          false,
+		"2024-01-01":          true,
+		"2024-06-30T18:12:00": true,
+		"2024-07-01":          false,
+	} {
+		if app.dateWanted(date) != wanted {
+			t.Errorf("dateWanted(%s) = %v", date, !wanted)
+		}
+	}
+
+	if params := app.dateRangeParams("order_by=-publish_date", "publish_date"); params != "order_by=-publish_date&publish_date=2024-01-01:2024-06-30" {
+		t.Errorf("dateRangeParams() = %q", params)
+	}
+	app.until = ""
+	if params := app.dateRangeParams("", "new_release_date"); params != "new_release_date=2024-01-01:9999-12-31" {
+		t.Errorf("dateRangeParams() = %q", params)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// estimatedTrackSizes are rough averages for a 7 minute track in each quality,
+// used when estimated_track_size is not set.
+var estimatedTrackSizes = map[string]int64{
+	"lossless":   50_000_000,
+	"high":       14_000_000,
+	"medium":     7_000_000,
+	"medium-hls": 7_000_000,
+}
+
+// checkDiskSpace compares the estimated size of the queued URLs against the
+// free space of the downloads directory. It returns false if the batch should
+// be aborted.
+func (app *application) checkDiskSpace() bool {
+	if !app.config.CheckDiskSpace {
+		return true
+	}
+
+	estimatedSize, _ := config.ParseByteSize(app.config.EstimatedTrackSize)
+
+	var tracks int
+	var required int64
+	for _, entry := range app.urls {
+		url, options := splitUrlEntry(entry)
+		count, err := app.estimateTrackCount(url)
+		if err != nil {
+			app.LogError("estimate track count", fmt.Errorf("%s: %w", url, err))
+			continue
+		}
+		// The size depends on the quality of each URL.
+		trackSize := estimatedSize
+		if trackSize == 0 {
+			trackSize = estimatedTrackSizes[newUrlOptions(app.config, options).quality]
+		}
+		tracks += count
+		required += int64(count) * trackSize
+	}
+	if tracks == 0 {
+		return true
+	}
+
+	free, err := freeDiskSpace(existingParent(app.config.DownloadsDirectory))
+	if err != nil {
+		app.LogError("check disk space", err)
+		return true
+	}
+	if uint64(required) <= free {
+		return true
+	}
+
+	fmt.Printf(
+		"Not enough disk space: %d tracks need about %s, only %s available\n",
+		tracks, formatByteSize(uint64(required)), formatByteSize(free),
+	)
+	if !stdinInteractive() {
+		fmt.Println("Aborting")
+		return false
+	}
+	fmt.Print("Continue anyway? [y/N]: ")
+	return strings.EqualFold(strings.TrimSpace(GetLine()), "y")
+}
+
+// estimateTrackCount returns the number of tracks a URL would download
+// without fetching any of the tracks themselves.
+func (app *application) estimateTrackCount(url string) (int, error) {
+	link, err := app.bp.ParseUrl(url)
+	if err != nil {
+		return 0, err
+	}
+
+	var inst *beatport.Beatport
+	switch link.Store {
+	case beatport.StoreBeatport:
+		inst = app.bp
+	case beatport.StoreBeatsource:
+		inst = app.bs
+	default:
+		return 0, ErrUnsupportedLinkStore
+	}
+
+	switch link.Type {
+	case beatport.TrackLink, beatport.ISRCLink:
+		return 1, nil
+	case beatport.ReleaseLink:
+		release, err := inst.GetRelease(app.ctx, link.ID)
+		if err != nil {
+			return 0, err
+		}
+		return release.TrackCount, nil
+	case beatport.PlaylistLink:
+		playlist, err := inst.GetPlaylist(app.ctx, link.ID)
+		if err != nil {
+			return 0, err
+		}
+		return playlist.TrackCount, nil
+	case beatport.ChartLink:
+		chart, err := inst.GetChart(app.ctx, link.ID)
+		if err != nil {
+			return 0, err
+		}
+		return app.limitCount(chart.TrackCount), nil
+	case beatport.Top100Link:
+		return app.limitCount(100), nil
+	case beatport.LabelLink:
+		return app.labelTrackCount(inst, link)
+	case beatport.ArtistLink:
+		return app.artistTrackCount(inst, link)
+	case beatport.LibraryLink:
+		// Most purchases are usually downloaded already, which can't be
+		// told without listing all of them.
+		return 0, nil
+	case beatport.CartLink:
+		carts, err := inst.GetCarts(app.ctx)
+		if err != nil {
+			return 0, err
+		}
+		var count int
+		for _, cart := range carts {
+			count += cart.ItemCount
+		}
+		return count, nil
+	default:
+		return 0, ErrUnsupportedLinkType
+	}
+}
+
+// existingParent returns the closest existing ancestor of path, since the
+// downloads directory may not have been created yet.
+func existingParent(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	for {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+func stdinInteractive() bool {
+	return isTerminal(os.Stdin)
+}
+
+func stdoutInteractive() bool {
+	return isTerminal(os.Stdout)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func formatByteSize(size uint64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
+
+func (app *application) limitCount(count int) int {
+	if app.limit > 0 && app.limit < count {
+		return app.limit
+	}
+	return count
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unspok3n/beatportdl/config"
 	"unspok3n/beatportdl/internal/beatport"
 	"unspok3n/beatportdl/internal/taglib"
+
+	"github.com/vbauerster/mpb/v8"
 )
 
 func (app *application) errorLogWrapper(url, step string, err error) {
@@ -22,6 +30,10 @@ func (app *application) infoLogWrapper(url, message string) {
 	app.LogInfo(fmt.Sprintf("[%s] %s", url, message))
 }
 
+func (app *application) debugLogWrapper(url, message string) {
+	app.LogDebug(fmt.Sprintf("[%s] %s", url, message))
+}
+
 func (app *application) createDirectory(baseDir string, subDir ...string) (string, error) {
 	fullPath := filepath.Join(baseDir, filepath.Join(subDir...))
 	err := CreateDirectory(fullPath)
@@ -33,6 +45,11 @@ type DownloadsDirectoryEntity interface {
 }
 
 func (app *application) setupDownloadsDirectory(baseDir string, entity DownloadsDirectoryEntity) (string, error) {
+	// directory_template replaces the context directories, handleTrack puts
+	// every track in its own directory.
+	if app.config.DirectoryTemplate != "" {
+		return baseDir, nil
+	}
 	if app.config.SortByContext {
 		var subDir string
 		switch castedEntity := entity.(type) {
@@ -58,9 +75,13 @@ func (app *application) setupDownloadsDirectory(baseDir string, entity Downloads
 				},
 			)
 		case *beatport.Chart:
+			template := app.config.ChartDirectoryTemplate
+			if castedEntity.Top100 {
+				template = app.config.Top100DirectoryTemplate
+			}
 			subDir = castedEntity.DirectoryName(
 				beatport.NamingPreferences{
-					Template:           app.config.ChartDirectoryTemplate,
+					Template:           template,
 					Whitespace:         app.config.WhitespaceCharacter,
 					TrackNumberPadding: app.config.TrackNumberPadding,
 				},
@@ -82,64 +103,172 @@ func (app *application) setupDownloadsDirectory(baseDir string, entity Downloads
 		}
 		baseDir = filepath.Join(baseDir, subDir)
 	}
+	if app.dryRun {
+		return baseDir, nil
+	}
 	return app.createDirectory(baseDir)
 }
 
-func (app *application) requireCover(respectFixTags, respectKeepCover bool) bool {
-	fixTags := respectFixTags && app.config.FixTags &&
-		(app.config.CoverSize != config.DefaultCoverSize || app.config.Quality != "lossless")
-	keepCover := respectKeepCover && app.config.SortByContext && app.config.KeepCover
+// trackDirectory returns the directory of a track under downloadsDir from
+// directory_template, and creates it.
+func (app *application) trackDirectory(downloadsDir string, track *beatport.Track) (string, error) {
+	dir := filepath.Join(downloadsDir, track.Directory(
+		beatport.NamingPreferences{
+			Template:           app.config.DirectoryTemplate,
+			Whitespace:         app.config.WhitespaceCharacter,
+			ArtistsLimit:       app.config.ArtistsLimit,
+			ArtistsShortForm:   app.config.ArtistsShortForm,
+			TrackNumberPadding: app.config.TrackNumberPadding,
+		},
+	))
+	if app.dryRun {
+		return dir, nil
+	}
+	return app.createDirectory(dir)
+}
+
+func (app *application) requireCover(quality string, respectFixTags, respectKeepCover bool) bool {
+	if app.dryRun {
+		return false
+	}
+	fixTags := respectFixTags && app.config.FixTags && app.config.EmbedArtwork &&
+		(app.config.CoverSize != config.DefaultCoverSize || quality != "lossless")
+	keepCover := respectKeepCover && app.config.KeepCover &&
+		(app.config.SortByContext || app.config.DirectoryTemplate != "")
 	return fixTags || keepCover
 }
 
 func (app *application) downloadCover(image beatport.Image, downloadsDir string) (string, error) {
-	coverUrl := image.FormattedUrl(app.config.CoverSize)
-	coverPath := filepath.Join(downloadsDir, uuid.New().String())
-	err := app.downloadFile(coverUrl, coverPath, "")
+	cached, err := app.covers.get(image.DynamicURI, func(destination string) error {
+		return app.fetchCover(image, destination)
+	})
 	if err != nil {
+		return "", err
+	}
+	coverPath := filepath.Join(downloadsDir, uuid.New().String())
+	if err := copyFile(cached, coverPath); err != nil {
 		os.Remove(coverPath)
 		return "", err
 	}
 	return coverPath, nil
 }
 
+// coverFileID claims the kept cover files in activeFiles, apart from the
+// tracks, which all have positive IDs.
+const coverFileID = -1
+
+// handleCoverFile moves the cover copy at path to the cover_filename of its
+// context directory with keep_cover, and removes it otherwise. With
+// directory_template, handleTrack keeps the covers next to the tracks
+// instead.
 func (app *application) handleCoverFile(path string) error {
 	if path == "" {
 		return nil
 	}
-	if app.config.KeepCover && app.config.SortByContext {
-		newPath := filepath.Dir(path) + "/cover.jpg"
-		if err := os.Rename(path, newPath); err != nil {
-			return err
+	if !app.config.KeepCover || !app.config.SortByContext || app.config.DirectoryTemplate != "" {
+		os.Remove(path)
+		return nil
+	}
+	return app.keepCoverFile(path)
+}
+
+// keepTrackCover keeps a copy of the cover at coverPath as the
+// cover_filename of the track directory dir.
+func (app *application) keepTrackCover(coverPath, dir string) error {
+	copyPath := filepath.Join(dir, uuid.New().String())
+	if err := copyFile(coverPath, copyPath); err != nil {
+		os.Remove(copyPath)
+		return err
+	}
+	return app.keepCoverFile(copyPath)
+}
+
+// keepCoverFile moves the cover copy at path to the cover_filename of its
+// directory. The first worker of the batch to finish a track in a directory
+// writes its cover; the copies of the other workers are removed.
+func (app *application) keepCoverFile(path string) error {
+	newPath := filepath.Join(filepath.Dir(path), app.config.CoverFilename)
+	file, err := app.claimFile(newPath, coverFileID)
+	if file == nil {
+		os.Remove(path)
+		if errors.Is(err, errDownloadedInBatch) {
+			return nil
 		}
-	} else {
+		return err
+	}
+	err = os.Rename(path, newPath)
+	if err != nil {
 		os.Remove(path)
 	}
-	return nil
+	app.releaseFile(newPath, file, err)
+	return err
 }
 
 var (
 	ErrTrackFileExists = errors.New("file already exists")
 )
 
-func (app *application) saveTrack(inst *beatport.Beatport, track *beatport.Track, directory string, quality string) (string, error) {
+func (app *application) saveTrack(inst *beatport.Beatport, track *beatport.Track, directory string, quality string) (location string, err error) {
 	var fileExtension string
 	var displayQuality string
 
 	var stream *beatport.TrackStream
 	var download *beatport.TrackDownload
+	var servedBy *account
+	defer func() {
+		if err != nil && servedBy != nil {
+			servedBy.failed.Add(1)
+		}
+	}()
+
+	fileName := app.trackFileName(track)
+
+	// Without size verification, an existing file can be skipped before
+	// asking the API for a download URL, if it has the extension of the
+	// quality.
+	if app.config.SkipExisting && !app.forceDownload && !app.config.VerifyExistingSize {
+		filePath := fmt.Sprintf("%s/%s%s", directory, fileName, qualityExtension(quality))
+		if !app.fileActive(filePath) && app.existingFileComplete(filePath, nil, app.downloadClient()) {
+			app.infoLogWrapper(track.StoreUrl(), "skipping, already exists")
+			app.skippedCount.Add(1)
+			return "", nil
+		}
+	}
+
+	if app.dryRun {
+		return "", app.dryRunTrack(track, directory, fileName, quality)
+	}
 
-	switch app.config.Quality {
+	switch quality {
 	case "medium-hls":
-		trackStream, err := inst.StreamTrack(track.ID)
+		var trackStream *beatport.TrackStream
+		acc, err := app.withAccount(track.StoreUrl(), func(acc *account) (err error) {
+			trackStream, err = acc.client(inst).StreamTrack(app.ctx, track.ID)
+			return err
+		})
+		servedBy = acc
+		if trackUnavailable(err) {
+			app.trackUnavailable(track, err)
+			return "", nil
+		}
 		if err != nil {
 			return "", err
 		}
 		fileExtension = ".m4a"
 		displayQuality = "AAC 128kbps - HLS"
 		stream = trackStream
+		app.debugLogWrapper(track.StoreUrl(), "stream URL "+stream.Url)
 	default:
-		trackDownload, err := inst.DownloadTrack(track.ID, quality)
+		var trackDownload *beatport.TrackDownload
+		acc, err := app.withAccount(track.StoreUrl(), func(acc *account) (err error) {
+			trackDownload, err = app.downloadTrackWithFallback(acc.client(inst), track, quality)
+			return err
+		})
+		servedBy = acc
+		if trackUnavailable(err) {
+			app.trackUnavailable(track, err)
+			return "", nil
+		}
 		if err != nil {
 			return "", err
 		}
@@ -157,49 +286,58 @@ func (app *application) saveTrack(inst *beatport.Beatport, track *beatport.Track
 			return "", fmt.Errorf("invalid stream quality: %s", trackDownload.StreamQuality)
 		}
 		download = trackDownload
+		app.debugLogWrapper(track.StoreUrl(), fmt.Sprintf("download URL %s (%s)", download.Location, displayQuality))
 	}
 
-	fileName := track.Filename(
-		beatport.NamingPreferences{
-			Template:           app.config.TrackFileTemplate,
-			Whitespace:         app.config.WhitespaceCharacter,
-			ArtistsLimit:       app.config.ArtistsLimit,
-			ArtistsShortForm:   app.config.ArtistsShortForm,
-			TrackNumberPadding: app.config.TrackNumberPadding,
-			KeySystem:          app.config.KeySystem,
-		},
-	)
-	filePath := fmt.Sprintf("%s/%s%s", directory, fileName, fileExtension)
+	// The file is downloaded through the proxy of the account that served
+	// it, which may not be the active one with the roundrobin strategy.
+	client, err := servedBy.downloadClient()
+	if err != nil {
+		return "", err
+	}
+
+	// The same track can be queued twice in a batch, e.g. from two charts.
+	// Only the first worker downloads it, the others wait and skip it.
+	filePath, file, err := app.claimTrackFile(directory, fileName, fileExtension, track.ID)
+	if errors.Is(err, errDownloadedInBatch) {
+		app.infoLogWrapper(track.StoreUrl(), "skipping, "+err.Error())
+		app.skippedCount.Add(1)
+		servedBy.skipped.Add(1)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		app.releaseFile(filePath, file, err)
+	}()
+
 	if _, err := os.Stat(filePath); err == nil {
-		app.activeFilesMutex.RLock()
-		_, exists := app.activeFiles[filePath]
-		app.activeFilesMutex.RUnlock()
-
-		if exists {
-			i := 1
-			for {
-				filePath = fmt.Sprintf("%s/%s (%d)%s", directory, fileName, i, fileExtension)
-				if _, err := os.Stat(filePath); os.IsNotExist(err) {
-					break
-				}
-				i++
-			}
-		} else {
-			switch app.config.TrackExists {
-			case "skip":
+		trackExists := app.config.TrackExists
+		if app.forceDownload {
+			trackExists = "overwrite"
+		} else if app.config.SkipExisting {
+			if app.existingFileComplete(filePath, download, client) {
+				app.infoLogWrapper(track.StoreUrl(), "skipping, already exists")
+				app.skippedCount.Add(1)
+				servedBy.skipped.Add(1)
 				return "", nil
-			case "update":
-				app.infoLogWrapper(track.StoreUrl(), "updating tags")
-				return filePath, nil
-			case "error":
-				return "", ErrTrackFileExists
 			}
+			trackExists = "overwrite"
 		}
-	}
 
-	app.activeFilesMutex.Lock()
-	app.activeFiles[filePath] = struct{}{}
-	app.activeFilesMutex.Unlock()
+		switch trackExists {
+		case "skip":
+			app.skippedCount.Add(1)
+			servedBy.skipped.Add(1)
+			return "", nil
+		case "update":
+			app.infoLogWrapper(track.StoreUrl(), "updating tags")
+			return filePath, nil
+		case "error":
+			return "", ErrTrackFileExists
+		}
+	}
 
 	var prefix string
 	infoDisplay := fmt.Sprintf("%s (%s) [%s]", track.Name.String(), track.MixName.String(), displayQuality)
@@ -209,17 +347,27 @@ func (app *application) saveTrack(inst *beatport.Beatport, track *beatport.Track
 		fmt.Println("Downloading " + infoDisplay)
 	}
 
+	app.activeDownloads.Add(1)
+	defer app.activeDownloads.Add(-1)
+
+	var resumedSize int64
 	if download != nil {
-		if err := app.downloadFile(download.Location, filePath, prefix); err != nil {
-			os.Remove(filePath)
+		partPath := filePath + partFileSuffix
+		if info, err := os.Stat(partPath); err == nil {
+			resumedSize = info.Size()
+		}
+		if err := app.downloadFileWithClient(client, download.Location, partPath, prefix); err != nil {
 			return "", err
 		}
+		if err := os.Rename(partPath, filePath); err != nil {
+			return "", fmt.Errorf("rename partial file: %w", err)
+		}
 	} else if stream != nil {
-		segments, key, err := getStreamSegments(stream.Url)
+		segments, key, err := app.getStreamSegments(client, stream.Url)
 		if err != nil {
 			return "", fmt.Errorf("get stream segments: %v", err)
 		}
-		segmentsFile, err := app.downloadSegments(directory, *segments, *key, prefix)
+		segmentsFile, err := app.downloadSegments(client, directory, *segments, *key, prefix)
 		defer os.Remove(segmentsFile)
 		if err != nil {
 			return "", fmt.Errorf("download segments: %v", err)
@@ -233,12 +381,159 @@ func (app *application) saveTrack(inst *beatport.Beatport, track *beatport.Track
 	if !app.config.ShowProgress {
 		fmt.Printf("Finished downloading %s\n", infoDisplay)
 	}
+	if len(app.accounts) > 1 {
+		app.infoLogWrapper(track.StoreUrl(), "downloaded with account "+servedBy.username)
+	}
+
+	servedBy.downloaded.Add(1)
+	if err := app.usage.Add(servedBy.username); err != nil {
+		app.LogError("update account usage", err)
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		servedBy.bytes.Add(max(info.Size()-resumedSize, 0))
+	}
+
+	app.downloadedCount.Add(1)
 
 	return filePath, nil
 }
 
+func (app *application) trackFileName(track *beatport.Track) string {
+	return track.Filename(
+		beatport.NamingPreferences{
+			Template:           app.config.TrackFileTemplate,
+			Whitespace:         app.config.WhitespaceCharacter,
+			ArtistsLimit:       app.config.ArtistsLimit,
+			ArtistsShortForm:   app.config.ArtistsShortForm,
+			TrackNumberPadding: app.config.TrackNumberPadding,
+			KeySystem:          app.config.KeySystem,
+		},
+	)
+}
+
+// qualityExtension returns the file extension of tracks downloaded in
+// quality, unless the download falls back to another quality.
+func qualityExtension(quality string) string {
+	if quality == "lossless" {
+		return ".flac"
+	}
+	return ".m4a"
+}
+
+// qualityFallbacks lists the qualities tried, in order, when a track isn't
+// available in the requested one.
+var qualityFallbacks = map[string][]string{
+	"lossless": {"high", "medium"},
+	"high":     {"medium"},
+}
+
+func (app *application) downloadTrackWithFallback(inst *beatport.Beatport, track *beatport.Track, quality string) (*beatport.TrackDownload, error) {
+	download, err := inst.DownloadTrack(app.ctx, track.ID, quality)
+	for _, fallback := range qualityFallbacks[quality] {
+		if !qualityUnavailable(err) {
+			break
+		}
+		app.infoLogWrapper(track.StoreUrl(), fmt.Sprintf("%s not available (%v), downgrading to %s", quality, err, fallback))
+		quality = fallback
+		download, err = inst.DownloadTrack(app.ctx, track.ID, quality)
+	}
+	return download, err
+}
+
+// qualityUnavailable reports whether err says the track can't be downloaded
+// in the requested quality. Other errors, like a rejected session or a used
+// up quota, are left to the account switching.
+func qualityUnavailable(err error) bool {
+	var statusErr *beatport.StatusError
+	if !errors.As(err, &statusErr) || quotaExceeded(err) {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+	default:
+		return false
+	}
+	detail := strings.ToLower(statusErr.Detail)
+	return strings.Contains(detail, "quality") || strings.Contains(detail, "format")
+}
+
+// trackUnavailable reports whether the API refused to provide a track, which
+// happens for tracks that aren't available in the account's region.
+func trackUnavailable(err error) bool {
+	var statusErr *beatport.StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusForbidden || statusErr.StatusCode == http.StatusNotFound)
+}
+
+func (app *application) trackUnavailable(track *beatport.Track, err error) {
+	if track.Store == beatport.StoreBeatsource && beatsourceDenied(err) {
+		err = fmt.Errorf("%w, the account may not have a Beatsource subscription", err)
+	}
+	app.infoLogWrapper(track.StoreUrl(), fmt.Sprintf("skipping, not available (%v)", err))
+	app.unavailableCount.Add(1)
+}
+
+// linkFetchError rewords the errors of fetching the entity a link points to:
+// a 404 usually means the link has a wrong ID, and a 401 or 403 from
+// Beatsource that the account has no Beatsource subscription.
+func linkFetchError(link *beatport.Link, err error) error {
+	var statusErr *beatport.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %d not found", strings.TrimSuffix(string(link.Type), "s"), link.ID)
+	}
+	if link.Store == beatport.StoreBeatsource && beatsourceDenied(err) {
+		return fmt.Errorf("%w (the account may not have a Beatsource subscription)", err)
+	}
+	return err
+}
+
+func beatsourceDenied(err error) bool {
+	var statusErr *beatport.StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden)
+}
+
+// trackFetchFailed logs a chart or playlist track whose details couldn't be
+// fetched. Tracks that were taken off the store are skipped like unavailable
+// tracks, instead of counting as failures to retry.
+func (app *application) trackFetchFailed(track *beatport.Track, step string, err error, opts urlOptions) {
+	if trackUnavailable(err) {
+		app.trackUnavailable(track, err)
+		return
+	}
+	app.failureLogWrapper(track.StoreUrl(), step, err, opts)
+}
+
+// existingFileComplete reports whether an existing track file can be kept:
+// it must be non-empty and, with verify_existing_size enabled, match the
+// size of the remote file, requested with client.
+func (app *application) existingFileComplete(filePath string, download *beatport.TrackDownload, client proxiedClient) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+	if !app.config.VerifyExistingSize || download == nil {
+		return true
+	}
+
+	req, err := http.NewRequestWithContext(app.ctx, "HEAD", download.Location, nil)
+	if err != nil {
+		return true
+	}
+	resp, err := app.doDownloadRequest(client, req)
+	if err != nil {
+		return true
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return true
+	}
+	return info.Size() == resp.ContentLength
+}
+
 const (
-	rawTagSuffix = "_raw"
+	rawTagSuffix   = "_raw"
+	partFileSuffix = ".part"
 )
 
 func (app *application) tagTrack(location string, track *beatport.Track, coverPath string) error {
@@ -252,65 +547,8 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 	}
 	defer file.Close()
 
-	subgenre := ""
-	if track.Subgenre != nil {
-		subgenre = track.Subgenre.Name
-	}
-	mappingValues := map[string]string{
-		"track_id":       strconv.Itoa(int(track.ID)),
-		"track_url":      track.StoreUrl(),
-		"track_name":     fmt.Sprintf("%s (%s)", track.Name.String(), track.MixName.String()),
-		"track_artists":  track.Artists.Display(0, ""),
-		"track_remixers": track.Remixers.Display(0, ""),
-		"track_artists_limited": track.Artists.Display(
-			app.config.ArtistsLimit,
-			app.config.ArtistsShortForm,
-		),
-		"track_remixers_limited": track.Remixers.Display(
-			app.config.ArtistsLimit,
-			app.config.ArtistsShortForm,
-		),
-		"track_number":              strconv.Itoa(track.Number),
-		"track_number_with_padding": beatport.NumberWithPadding(track.Number, track.Release.TrackCount, app.config.TrackNumberPadding),
-		"track_number_with_total":   fmt.Sprintf("%d/%d", track.Number, track.Release.TrackCount),
-		"track_genre":               track.Genre.Name,
-		"track_subgenre":            subgenre,
-		"track_genre_with_subgenre": track.GenreWithSubgenre("|"),
-		"track_subgenre_or_genre":   track.SubgenreOrGenre(),
-		"track_key":                 track.Key.Display(app.config.KeySystem),
-		"track_bpm":                 strconv.Itoa(track.BPM),
-		"track_isrc":                track.ISRC,
-
-		"release_id":   strconv.Itoa(int(track.Release.ID)),
-		"release_url":  track.Release.StoreUrl(),
-		"release_name": track.Release.Name.String(),
-		"release_artists": track.Release.Artists.Display(
-			0,
-			"",
-		),
-		"release_remixers": track.Release.Remixers.Display(
-			0,
-			"",
-		),
-		"release_artists_limited": track.Release.Artists.Display(
-			app.config.ArtistsLimit,
-			app.config.ArtistsShortForm,
-		),
-		"release_remixers_limited": track.Release.Remixers.Display(
-			app.config.ArtistsLimit,
-			app.config.ArtistsShortForm,
-		),
-		"release_date":        track.Release.Date,
-		"release_year":        track.Release.Year(),
-		"release_track_count": strconv.Itoa(track.Release.TrackCount),
-		"release_track_count_with_padding": beatport.NumberWithPadding(
-			track.Release.TrackCount, track.Release.TrackCount, app.config.TrackNumberPadding,
-		),
-		"release_catalog_number": track.Release.CatalogNumber.String(),
-		"release_upc":            track.Release.UPC,
-		"release_label":          track.Release.Label.Name,
-		"release_label_url":      track.Release.Label.StoreUrl(),
-	}
+	mappingValues, multiValues := app.tagValues(track)
+	templateValues := app.tagTemplateValues(track)
 
 	if fileExt == ".m4a" {
 		if err = file.StripMp4(); err != nil {
@@ -329,10 +567,10 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 
 	if fileExt == ".flac" {
 		for field, property := range app.config.TagMappings["flac"] {
-			value := mappingValues[field]
-			if value != "" {
-				file.SetProperty(property, &value)
-			}
+			setTagValues(file, property, mappingValues[field], multiValues[field])
+		}
+		for tag, value := range templateValues {
+			file.SetProperty(tag, &value)
 		}
 	} else if fileExt == ".m4a" {
 		rawTags := make(map[string]string)
@@ -344,10 +582,14 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 					rawTags[property] = mappingValues[field]
 				}
 			} else {
-				value := mappingValues[field]
-				if value != "" {
-					file.SetProperty(property, &value)
-				}
+				setTagValues(file, property, mappingValues[field], multiValues[field])
+			}
+		}
+		for tag, value := range templateValues {
+			if strings.HasSuffix(tag, rawTagSuffix) {
+				rawTags[strings.TrimSuffix(tag, rawTagSuffix)] = value
+			} else {
+				file.SetProperty(tag, &value)
 			}
 		}
 
@@ -356,7 +598,7 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 		}
 	}
 
-	if coverPath != "" && (app.config.CoverSize != config.DefaultCoverSize || fileExt == ".m4a") {
+	if coverPath != "" && app.config.EmbedArtwork && (app.config.CoverSize != config.DefaultCoverSize || fileExt == ".m4a") {
 		data, err := os.ReadFile(coverPath)
 		if err != nil {
 			return err
@@ -380,15 +622,86 @@ func (app *application) tagTrack(location string, track *beatport.Track, coverPa
 	return nil
 }
 
-func (app *application) handleTrack(inst *beatport.Beatport, track *beatport.Track, downloadsDir string, coverPath string) error {
-	location, err := app.saveTrack(inst, track, downloadsDir, app.config.Quality)
+// formatBPM formats the BPM tag as an integer, or with one decimal with the
+// decimal bpm_format.
+func formatBPM(bpm int, format string) string {
+	if format == "decimal" {
+		return fmt.Sprintf("%.1f", float64(bpm))
+	}
+	return strconv.Itoa(bpm)
+}
+
+// handleTrack downloads and tags a track of a release, playlist, chart,
+// label, artist or the library, leaving out the tracks outside the --bpm and
+// --key filters, and returns the location of the file, or an empty string if
+// the track was skipped.
+func (app *application) handleTrack(inst *beatport.Beatport, track *beatport.Track, downloadsDir string, coverPath string, opts urlOptions) (string, error) {
+	if !app.trackFilterWanted(track) {
+		return "", nil
+	}
+	return app.downloadTrack(inst, track, downloadsDir, coverPath, opts)
+}
+
+// downloadTrack downloads and tags a track, and returns the location of the
+// file, or an empty string if the track was skipped. Tracks linked on their
+// own are downloaded with it directly, as the filters only select tracks of
+// collections.
+func (app *application) downloadTrack(inst *beatport.Beatport, track *beatport.Track, downloadsDir string, coverPath string, opts urlOptions) (string, error) {
+	entry := archiveEntry(track.Store, "track", track.ID)
+	if app.archive.Contains(entry) {
+		app.infoLogWrapper(track.StoreUrl(), "skipping, already in download archive")
+		app.skippedCount.Add(1)
+		return "", nil
+	}
+	if path, ok := app.isrcIndex.Lookup(track.ISRC); ok {
+		app.infoLogWrapper(track.StoreUrl(), fmt.Sprintf("skipping, ISRC %s already downloaded to %s", track.ISRC, path))
+		app.skippedCount.Add(1)
+		return "", nil
+	}
+
+	if app.config.DirectoryTemplate != "" {
+		dir, err := app.trackDirectory(opts.downloadsDirectory, track)
+		if err != nil {
+			return "", fmt.Errorf("setup track directory: %v", err)
+		}
+		downloadsDir = dir
+	}
+
+	// Previews are kept out of the archive and the ISRC index, so the
+	// purchased files are still downloaded later.
+	if app.previews {
+		location, err := app.savePreview(track, downloadsDir)
+		if err != nil {
+			return "", fmt.Errorf("save preview: %v", err)
+		}
+		app.runPostDownloadHook(track, location)
+		return location, nil
+	}
+
+	location, err := app.saveTrack(inst, track, downloadsDir, opts.quality)
 	if err != nil {
-		return fmt.Errorf("save track: %v", err)
+		return "", fmt.Errorf("save track: %v", err)
 	}
 	if err = app.tagTrack(location, track, coverPath); err != nil && location != "" {
-		return fmt.Errorf("tag track: %v", err)
+		return "", fmt.Errorf("tag track: %v", err)
 	}
-	return nil
+	if location != "" && coverPath != "" && app.config.KeepCover && app.config.DirectoryTemplate != "" {
+		if err := app.keepTrackCover(coverPath, downloadsDir); err != nil {
+			app.errorLogWrapper(track.StoreUrl(), "keep track cover", err)
+		}
+	}
+	if location != "" && app.recordsDownloads() {
+		if err := app.archive.Add(entry); err != nil {
+			app.errorLogWrapper(track.StoreUrl(), "update download archive", err)
+		}
+		if err := app.isrcIndex.Add(track.ISRC, location); err != nil {
+			app.errorLogWrapper(track.StoreUrl(), "update isrc index", err)
+		}
+	}
+	if location != "" {
+		app.runPostDownloadHook(track, location)
+	}
+	return location, nil
 }
 
 func (app *application) cleanup(downloadsDir string) {
@@ -398,14 +711,15 @@ func (app *application) cleanup(downloadsDir string) {
 }
 
 func ForPaginated[T any](
+	ctx context.Context,
 	entityId int64,
 	params string,
-	fetchPage func(id int64, page int, params string) (results *beatport.Paginated[T], err error),
+	fetchPage func(ctx context.Context, id int64, page int, params string) (results *beatport.Paginated[T], err error),
 	processItem func(item T, i int) error,
 ) error {
 	page := 1
 	for {
-		paginated, err := fetchPage(entityId, page, params)
+		paginated, err := fetchPage(ctx, entityId, page, params)
 		if err != nil {
 			return fmt.Errorf("fetch page: %w", err)
 		}
@@ -424,12 +738,34 @@ func ForPaginated[T any](
 	return nil
 }
 
-func (app *application) handleUrl(url string) {
-	link, err := app.bp.ParseUrl(url)
+// handleUrl downloads a queued URL, which normalizeUrls cleaned up already,
+// with the options that follow it.
+func (app *application) handleUrl(entry string) {
+	url, options := splitUrlEntry(entry)
+	opts := newUrlOptions(app.config, options)
+	if err := app.urlErrors[url]; err != nil {
+		app.failureLogWrapper(url, "parse url", err, opts)
+		return
+	}
+	linkUrl, positions, err := splitPositions(url)
+	if err != nil {
+		app.failureLogWrapper(url, "parse url", err, opts)
+		return
+	}
+	link, err := app.bp.ParseUrl(linkUrl)
 	if err != nil {
-		app.errorLogWrapper(url, "parse url", err)
+		app.failureLogWrapper(url, "parse url", err, opts)
 		return
 	}
+	link.Original = url
+	link.Positions = positions
+	switch link.Type {
+	case beatport.ReleaseLink, beatport.PlaylistLink, beatport.ChartLink, beatport.Top100Link:
+	default:
+		if positions != nil {
+			app.errorLogWrapper(url, "select positions", fmt.Errorf("not supported for %s links, downloading all tracks", link.Type))
+		}
+	}
 
 	var inst *beatport.Beatport
 	switch link.Store {
@@ -442,56 +778,72 @@ func (app *application) handleUrl(url string) {
 		return
 	}
 
+	if link.Type == beatport.TrackLink || link.Type == beatport.ReleaseLink {
+		entity := strings.TrimSuffix(string(link.Type), "s")
+		if app.archive.Contains(archiveEntry(link.Store, entity, link.ID)) {
+			app.infoLogWrapper(url, "skipping, already in download archive")
+			return
+		}
+	}
+
 	switch link.Type {
 	case beatport.TrackLink:
-		app.handleTrackLink(inst, link)
+		app.handleTrackLink(inst, link, opts)
 	case beatport.ReleaseLink:
-		app.handleReleaseLink(inst, link)
+		app.handleReleaseLink(inst, link, opts)
 	case beatport.PlaylistLink:
-		app.handlePlaylistLink(inst, link)
+		app.handlePlaylistLink(inst, link, opts)
 	case beatport.ChartLink:
-		app.handleChartLink(inst, link)
+		app.handleChartLink(inst, link, opts)
+	case beatport.Top100Link:
+		app.handleTop100Link(inst, link, opts)
 	case beatport.LabelLink:
-		app.handleLabelLink(inst, link)
+		app.handleLabelLink(inst, link, opts)
 	case beatport.ArtistLink:
-		app.handleArtistLink(inst, link)
+		app.handleArtistLink(inst, link, opts)
+	case beatport.LibraryLink:
+		app.handleLibraryLink(inst, link, opts)
+	case beatport.CartLink:
+		app.handleCartLink(inst, link, opts)
+	case beatport.ISRCLink:
+		app.handleISRCLink(inst, link, opts)
 	default:
 		app.LogError("handle URL", ErrUnsupportedLinkType)
 	}
 }
 
-func (app *application) handleTrackLink(inst *beatport.Beatport, link *beatport.Link) {
-	track, err := inst.GetTrack(link.ID)
+func (app *application) handleTrackLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	track, err := inst.GetTrack(app.ctx, link.ID)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "fetch track", err)
+		app.failureLogWrapper(link.Original, "fetch track", linkFetchError(link, err), opts)
 		return
 	}
 
-	release, err := inst.GetRelease(track.Release.ID)
+	release, err := inst.GetRelease(app.ctx, track.Release.ID)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "fetch track release", err)
+		app.failureLogWrapper(link.Original, "fetch track release", err, opts)
 		return
 	}
 	track.Release = *release
 
-	downloadsDir, err := app.setupDownloadsDirectory(app.config.DownloadsDirectory, release)
+	downloadsDir, err := app.setupDownloadsDirectory(opts.downloadsDirectory, release)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "setup downloads directory", err)
+		app.failureLogWrapper(link.Original, "setup downloads directory", err, opts)
 		return
 	}
 
 	wg := sync.WaitGroup{}
 	app.downloadWorker(&wg, func() {
 		var cover string
-		if app.requireCover(true, true) {
+		if app.requireCover(opts.quality, true, true) {
 			cover, err = app.downloadCover(track.Release.Image, downloadsDir)
 			if err != nil {
 				app.errorLogWrapper(link.Original, "download track release cover", err)
 			}
 		}
 
-		if err := app.handleTrack(inst, track, downloadsDir, cover); err != nil {
-			app.errorLogWrapper(link.Original, "handle track", err)
+		if _, err := app.downloadTrack(inst, track, downloadsDir, cover, opts); err != nil {
+			app.failureLogWrapper(link.Original, "handle track", err, opts)
 			os.Remove(cover)
 			return
 		}
@@ -506,21 +858,58 @@ func (app *application) handleTrackLink(inst *beatport.Beatport, link *beatport.
 	app.cleanup(downloadsDir)
 }
 
-func (app *application) handleReleaseLink(inst *beatport.Beatport, link *beatport.Link) {
-	release, err := inst.GetRelease(link.ID)
+// handleISRCLink downloads the track with the ISRC of the link. Of the tracks
+// released again with the same ISRC, the earliest is downloaded, or the
+// latest with --isrc-latest.
+func (app *application) handleISRCLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	tracks, err := inst.GetTracksByISRC(app.ctx, link.ISRC)
+	if err != nil {
+		app.failureLogWrapper(link.Original, "fetch isrc tracks", err, opts)
+		return
+	}
+	// The API also matches ISRCs partially.
+	tracks = slices.DeleteFunc(tracks, func(t beatport.Track) bool {
+		return !strings.EqualFold(t.ISRC, link.ISRC)
+	})
+	if len(tracks) == 0 {
+		app.infoLogWrapper(link.Original, "not found on Beatport")
+		return
+	}
+
+	comparePublishDate := func(a, b beatport.Track) int {
+		return strings.Compare(a.PublishDate, b.PublishDate)
+	}
+	track := slices.MinFunc(tracks, comparePublishDate)
+	if app.isrcLatest {
+		track = slices.MaxFunc(tracks, comparePublishDate)
+	}
+	if len(tracks) > 1 {
+		app.infoLogWrapper(link.Original, fmt.Sprintf("%d tracks share the isrc, downloading %s", len(tracks), track.StoreUrl()))
+	}
+
+	app.handleTrackLink(inst, &beatport.Link{
+		Original: link.Original,
+		Type:     beatport.TrackLink,
+		ID:       track.ID,
+		Store:    link.Store,
+	}, opts)
+}
+
+func (app *application) handleReleaseLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	release, err := inst.GetRelease(app.ctx, link.ID)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "fetch release", err)
+		app.failureLogWrapper(link.Original, "fetch release", linkFetchError(link, err), opts)
 		return
 	}
 
-	downloadsDir, err := app.setupDownloadsDirectory(app.config.DownloadsDirectory, release)
+	downloadsDir, err := app.setupDownloadsDirectory(opts.downloadsDirectory, release)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "setup downloads directory", err)
+		app.failureLogWrapper(link.Original, "setup downloads directory", err, opts)
 		return
 	}
 
 	var cover string
-	if app.requireCover(true, true) {
+	if app.requireCover(opts.quality, true, true) {
 		app.semAcquire(app.downloadSem)
 		cover, err = app.downloadCover(release.Image, downloadsDir)
 		if err != nil {
@@ -530,29 +919,44 @@ func (app *application) handleReleaseLink(inst *beatport.Beatport, link *beatpor
 	}
 
 	wg := sync.WaitGroup{}
-	for _, trackUrl := range release.TrackUrls {
+	var failed atomic.Bool
+	for i, trackUrl := range release.TrackUrls {
+		if link.Positions != nil && !link.Positions[i+1] {
+			continue
+		}
 		app.downloadWorker(&wg, func() {
 			trackLink, err := inst.ParseUrl(trackUrl)
 			if err != nil {
-				app.errorLogWrapper(link.Original, "parse track url", err)
+				app.failureLogWrapper(link.Original, "parse track url", err, opts)
+				failed.Store(true)
 				return
 			}
 
-			track, err := inst.GetTrack(trackLink.ID)
+			track, err := inst.GetTrack(app.ctx, trackLink.ID)
 			if err != nil {
-				app.errorLogWrapper(trackUrl, "fetch release track", err)
+				app.failureLogWrapper(trackUrl, "fetch release track", err, opts)
+				failed.Store(true)
 				return
 			}
 			trackStoreUrl := track.StoreUrl()
 			track.Release = *release
 
-			if err := app.handleTrack(inst, track, downloadsDir, cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle track", err)
+			if _, err := app.handleTrack(inst, track, downloadsDir, cover, opts); err != nil {
+				app.failureLogWrapper(trackStoreUrl, "handle track", err, opts)
+				failed.Store(true)
 				return
 			}
 		})
 	}
 	wg.Wait()
+	app.warnPositionsOutOfRange(link.Original, link.Positions, len(release.TrackUrls))
+
+	// A release is only archived when all of its tracks were selected.
+	if !failed.Load() && app.ctx.Err() == nil && link.Positions == nil && app.recordsDownloads() {
+		if err := app.archive.Add(archiveEntry(release.Store, "release", release.ID)); err != nil {
+			app.errorLogWrapper(link.Original, "update download archive", err)
+		}
+	}
 
 	if err := app.handleCoverFile(cover); err != nil {
 		app.errorLogWrapper(link.Original, "handle cover file", err)
@@ -562,48 +966,65 @@ func (app *application) handleReleaseLink(inst *beatport.Beatport, link *beatpor
 	app.cleanup(downloadsDir)
 }
 
-func (app *application) handlePlaylistLink(inst *beatport.Beatport, link *beatport.Link) {
-	playlist, err := inst.GetPlaylist(link.ID)
+func (app *application) handlePlaylistLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	playlist, err := inst.GetPlaylist(app.ctx, link.ID)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "fetch playlist", err)
+		app.failureLogWrapper(link.Original, "fetch playlist", linkFetchError(link, err), opts)
 		return
 	}
 
-	downloadsDir, err := app.setupDownloadsDirectory(app.config.DownloadsDirectory, playlist)
+	downloadsDir, err := app.setupDownloadsDirectory(opts.downloadsDirectory, playlist)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "setup downloads directory", err)
+		app.failureLogWrapper(link.Original, "setup downloads directory", err, opts)
 		return
 	}
 
 	wg := sync.WaitGroup{}
-	err = ForPaginated[beatport.PlaylistItem](link.ID, "", inst.GetPlaylistItems, func(item beatport.PlaylistItem, i int) error {
+	playlistFile := app.newPlaylistWriter()
+	var count int
+	err = ForPaginated[beatport.PlaylistItem](app.ctx, link.ID, "", inst.GetPlaylistItems, func(item beatport.PlaylistItem, i int) error {
+		if link.Positions != nil && count >= lastPosition(link.Positions) {
+			return errLimitReached
+		}
+		count++
+		item.Track.Position = cmp.Or(item.Position, count)
+		item.Track.PositionCount = max(playlist.TrackCount, count)
+		if link.Positions != nil && !link.Positions[item.Track.Position] {
+			return nil
+		}
+		if item.Track.ID == 0 {
+			// entries of tracks removed from the store come without a track
+			app.infoLogWrapper(link.Original, fmt.Sprintf("skipping entry %d, track not available", item.Track.Position))
+			app.unavailableCount.Add(1)
+			return nil
+		}
 		app.downloadWorker(&wg, func() {
 			trackStoreUrl := item.Track.StoreUrl()
 
-			release, err := inst.GetRelease(item.Track.Release.ID)
+			release, err := inst.GetRelease(app.ctx, item.Track.Release.ID)
 			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch track release", err)
+				app.trackFetchFailed(&item.Track, "fetch track release", err, opts)
 				return
 			}
 			item.Track.Release = *release
 
 			trackDownloadsDir := downloadsDir
-			trackFull, err := inst.GetTrack(item.Track.ID)
+			trackFull, err := inst.GetTrack(app.ctx, item.Track.ID)
 			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
+				app.trackFetchFailed(&item.Track, "fetch full track", err, opts)
 				return
 			}
 			item.Track.Number = trackFull.Number
 			if app.config.SortByContext && app.config.ForceReleaseDirectories {
 				trackDownloadsDir, err = app.setupDownloadsDirectory(downloadsDir, release)
 				if err != nil {
-					app.errorLogWrapper(trackStoreUrl, "setup track release directory", err)
+					app.failureLogWrapper(trackStoreUrl, "setup track release directory", err, opts)
 					return
 				}
 			}
 
 			var cover string
-			if app.requireCover(true, app.config.ForceReleaseDirectories) {
+			if app.requireCover(opts.quality, true, app.config.ForceReleaseDirectories || app.config.DirectoryTemplate != "") {
 				cover, err = app.downloadCover(item.Track.Release.Image, trackDownloadsDir)
 				if err != nil {
 					app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
@@ -612,12 +1033,14 @@ func (app *application) handlePlaylistLink(inst *beatport.Beatport, link *beatpo
 				}
 			}
 
-			if err := app.handleTrack(inst, &item.Track, trackDownloadsDir, cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle track", err)
+			location, err := app.handleTrack(inst, &item.Track, trackDownloadsDir, cover, opts)
+			if err != nil {
+				app.failureLogWrapper(trackStoreUrl, "handle track", err, opts)
 				os.Remove(cover)
 				app.cleanup(trackDownloadsDir)
 				return
 			}
+			playlistFile.add(item.Track.Position, location, &item.Track)
 
 			if app.config.ForceReleaseDirectories {
 				if err := app.handleCoverFile(cover); err != nil {
@@ -631,30 +1054,89 @@ func (app *application) handlePlaylistLink(inst *beatport.Beatport, link *beatpo
 		return nil
 	})
 
-	if err != nil {
-		app.errorLogWrapper(link.Original, "handle playlist items", err)
+	if err != nil && !errors.Is(err, errLimitReached) {
+		app.failureLogWrapper(link.Original, "handle playlist items", err, opts)
 		return
 	}
 
 	wg.Wait()
+	if err == nil {
+		app.warnPositionsOutOfRange(link.Original, link.Positions, count)
+	}
+
+	if _, err := playlistFile.write(downloadsDir, playlist.Name); err != nil {
+		app.errorLogWrapper(link.Original, "write playlist file", err)
+	}
 }
 
-func (app *application) handleChartLink(inst *beatport.Beatport, link *beatport.Link) {
-	chart, err := inst.GetChart(link.ID)
+func (app *application) handleChartLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	chart, err := inst.GetChart(app.ctx, link.ID)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "fetch chart", err)
+		app.failureLogWrapper(link.Original, "fetch chart", linkFetchError(link, err), opts)
 		return
 	}
 
-	downloadsDir, err := app.setupDownloadsDirectory(app.config.DownloadsDirectory, chart)
+	app.handleChartTracks(inst, link, chart, inst.GetChartTracks, opts)
+}
+
+func (app *application) handleTop100Link(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	name := "Top 100"
+	chart := &beatport.Chart{
+		ID:          link.ID,
+		TrackCount:  100,
+		PublishDate: time.Now(),
+		Top100:      true,
+	}
+	if link.ID != 0 {
+		genre, err := inst.GetGenre(app.ctx, link.ID)
+		if err != nil {
+			app.failureLogWrapper(link.Original, "fetch genre", err, opts)
+			return
+		}
+		name = genre.Name + " " + name
+		chart.Genres = []beatport.Genre{*genre}
+	}
+	chart.Name = name
+	chart.Slug = strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+	chart.AddDate, chart.ChangeDate = chart.PublishDate, chart.PublishDate
+
+	app.handleChartTracks(inst, link, chart, inst.GetTopTracks, opts)
+}
+
+var errLimitReached = errors.New("limit reached")
+
+// handleChartTracks downloads the tracks of a chart, up to the --limit flag,
+// with an overall progress bar counting the finished tracks.
+func (app *application) handleChartTracks(
+	inst *beatport.Beatport,
+	link *beatport.Link,
+	chart *beatport.Chart,
+	fetchPage func(ctx context.Context, id int64, page int, params string) (*beatport.Paginated[beatport.Track], error),
+	opts urlOptions,
+) {
+	downloadsDir, err := app.setupDownloadsDirectory(opts.downloadsDirectory, chart)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "setup downloads directory", err)
+		app.failureLogWrapper(link.Original, "setup downloads directory", err, opts)
 		return
 	}
 	wg := sync.WaitGroup{}
 
-	if app.requireCover(false, true) {
-		app.downloadWorker(&wg, func() {
+	total := chart.TrackCount
+	if app.limit > 0 && (total == 0 || app.limit < total) {
+		total = app.limit
+	}
+	progressTotal := total
+	if link.Positions != nil && (total == 0 || len(link.Positions) < total) {
+		progressTotal = len(link.Positions)
+	}
+	var progress *mpb.Bar
+	if app.config.ShowProgress && progressTotal > 0 {
+		progress = app.pbp.AddBar(int64(progressTotal), ChartProgressBarOptions(chart.Name)...)
+		defer progress.Abort(false)
+	}
+
+	if app.requireCover(opts.quality, false, app.config.DirectoryTemplate == "") {
+		app.downloadJob(&wg, func() {
 			cover, err := app.downloadCover(chart.Image, downloadsDir)
 			if err != nil {
 				app.errorLogWrapper(link.Original, "download chart cover", err)
@@ -666,34 +1148,55 @@ func (app *application) handleChartLink(inst *beatport.Beatport, link *beatport.
 		})
 	}
 
-	err = ForPaginated[beatport.Track](link.ID, "", inst.GetChartTracks, func(track beatport.Track, i int) error {
+	playlistFile := app.newPlaylistWriter()
+	var count int
+	err = ForPaginated[beatport.Track](app.ctx, link.ID, "", fetchPage, func(track beatport.Track, i int) error {
+		if app.limit > 0 && count >= app.limit {
+			return errLimitReached
+		}
+		if link.Positions != nil && count >= lastPosition(link.Positions) {
+			return errLimitReached
+		}
+		count++
+		if link.Positions != nil && !link.Positions[count] {
+			return nil
+		}
+		track.Position = count
+		track.PositionCount = max(total, count)
+		track.NumberByPosition = chart.Top100
 		app.downloadWorker(&wg, func() {
+			if progress != nil {
+				defer progress.Increment()
+			}
+			if !app.ownedWanted(inst, &track) {
+				return
+			}
 			trackStoreUrl := track.StoreUrl()
 
-			release, err := inst.GetRelease(track.Release.ID)
+			release, err := inst.GetRelease(app.ctx, track.Release.ID)
 			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch track release", err)
+				app.trackFetchFailed(&track, "fetch track release", err, opts)
 				return
 			}
 			track.Release = *release
 
 			trackDownloadsDir := downloadsDir
-			trackFull, err := inst.GetTrack(track.ID)
+			trackFull, err := inst.GetTrack(app.ctx, track.ID)
 			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
+				app.trackFetchFailed(&track, "fetch full track", err, opts)
 				return
 			}
 			track.Number = trackFull.Number
 			if app.config.SortByContext && app.config.ForceReleaseDirectories {
 				trackDownloadsDir, err = app.setupDownloadsDirectory(downloadsDir, release)
 				if err != nil {
-					app.errorLogWrapper(trackStoreUrl, "setup track release directory", err)
+					app.failureLogWrapper(trackStoreUrl, "setup track release directory", err, opts)
 					return
 				}
 			}
 
 			var cover string
-			if app.requireCover(true, app.config.ForceReleaseDirectories) {
+			if app.requireCover(opts.quality, true, app.config.ForceReleaseDirectories || app.config.DirectoryTemplate != "") {
 				cover, err = app.downloadCover(track.Release.Image, trackDownloadsDir)
 				if err != nil {
 					app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
@@ -702,12 +1205,14 @@ func (app *application) handleChartLink(inst *beatport.Beatport, link *beatport.
 				}
 			}
 
-			if err := app.handleTrack(inst, &track, trackDownloadsDir, cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle track", err)
+			location, err := app.handleTrack(inst, &track, trackDownloadsDir, cover, opts)
+			if err != nil {
+				app.failureLogWrapper(trackStoreUrl, "handle track", err, opts)
 				os.Remove(cover)
 				app.cleanup(trackDownloadsDir)
 				return
 			}
+			playlistFile.add(track.Position, location, &track)
 
 			if app.config.ForceReleaseDirectories {
 				if err := app.handleCoverFile(cover); err != nil {
@@ -721,38 +1226,68 @@ func (app *application) handleChartLink(inst *beatport.Beatport, link *beatport.
 		return nil
 	})
 
-	if err != nil {
-		app.errorLogWrapper(link.Original, "handle playlist items", err)
+	if err != nil && !errors.Is(err, errLimitReached) {
+		app.failureLogWrapper(link.Original, "handle chart tracks", err, opts)
 		return
 	}
 
 	wg.Wait()
+	if err == nil {
+		app.warnPositionsOutOfRange(link.Original, link.Positions, count)
+	}
+
+	if _, err := playlistFile.write(downloadsDir, chart.Name); err != nil {
+		app.errorLogWrapper(link.Original, "write playlist file", err)
+	}
 }
 
-func (app *application) handleLabelLink(inst *beatport.Beatport, link *beatport.Link) {
-	label, err := inst.GetLabel(link.ID)
+func (app *application) handleLabelLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	label, err := inst.GetLabel(app.ctx, link.ID)
+	if err != nil {
+		app.failureLogWrapper(link.Original, "fetch label", linkFetchError(link, err), opts)
+		return
+	}
+
+	if app.listOnly {
+		app.listLabelReleases(inst, link, label, opts)
+		return
+	}
+
+	downloadsDir, err := app.setupDownloadsDirectory(opts.downloadsDirectory, label)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "fetch label", err)
+		app.failureLogWrapper(link.Original, "setup downloads directory", err, opts)
 		return
 	}
 
-	downloadsDir, err := app.setupDownloadsDirectory(app.config.DownloadsDirectory, label)
+	// All releases are fetched before any download starts, so the count
+	// is logged while the label can still be canceled.
+	releases, err := app.labelReleases(inst, link)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "setup downloads directory", err)
+		app.failureLogWrapper(link.Original, "fetch label releases", err, opts)
 		return
 	}
+	app.infoLogWrapper(link.Original, fmt.Sprintf("label %s resolved to %d releases", label.Name, len(releases)))
+
+	app.downloadReleases(inst, releases, downloadsDir, opts)
+}
 
-	err = ForPaginated[beatport.Release](link.ID, link.Params, inst.GetLabelReleases, func(release beatport.Release, i int) error {
+// downloadReleases downloads the releases of a label or artist catalog into
+// release directories of downloadsDir, one global worker per release.
+func (app *application) downloadReleases(inst *beatport.Beatport, releases []beatport.Release, downloadsDir string, opts urlOptions) {
+	releasesWg := sync.WaitGroup{}
+	for _, release := range releases {
+		releasesWg.Add(1)
 		app.globalWorker(func() {
+			defer releasesWg.Done()
 			releaseStoreUrl := release.StoreUrl()
 			releaseDir, err := app.setupDownloadsDirectory(downloadsDir, &release)
 			if err != nil {
-				app.errorLogWrapper(releaseStoreUrl, "setup release downloads directory", err)
+				app.failureLogWrapper(releaseStoreUrl, "setup release downloads directory", err, opts)
 				return
 			}
 
 			var cover string
-			if app.requireCover(true, true) {
+			if app.requireCover(opts.quality, true, true) {
 				app.semAcquire(app.downloadSem)
 				cover, err = app.downloadCover(release.Image, releaseDir)
 				if err != nil {
@@ -762,25 +1297,28 @@ func (app *application) handleLabelLink(inst *beatport.Beatport, link *beatport.
 			}
 
 			wg := sync.WaitGroup{}
-			err = ForPaginated[beatport.Track](release.ID, "", inst.GetReleaseTracks, func(track beatport.Track, i int) error {
+			err = ForPaginated[beatport.Track](app.ctx, release.ID, "", inst.GetReleaseTracks, func(track beatport.Track, i int) error {
+				if !app.genreWanted(&track) || !app.ownedWanted(inst, &track) {
+					return nil
+				}
 				app.downloadWorker(&wg, func() {
 					trackStoreUrl := track.StoreUrl()
-					t, err := inst.GetTrack(track.ID)
+					t, err := inst.GetTrack(app.ctx, track.ID)
 					if err != nil {
-						app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
+						app.failureLogWrapper(trackStoreUrl, "fetch full track", err, opts)
 						return
 					}
 					t.Release = release
 
-					if err := app.handleTrack(inst, t, releaseDir, cover); err != nil {
-						app.errorLogWrapper(trackStoreUrl, "handle track", err)
+					if _, err := app.handleTrack(inst, t, releaseDir, cover, opts); err != nil {
+						app.failureLogWrapper(trackStoreUrl, "handle track", err, opts)
 						return
 					}
 				})
 				return nil
 			})
 			if err != nil {
-				app.errorLogWrapper(releaseStoreUrl, "handle release tracks", err)
+				app.failureLogWrapper(releaseStoreUrl, "handle release tracks", err, opts)
 				os.Remove(cover)
 				app.cleanup(releaseDir)
 				return
@@ -794,61 +1332,88 @@ func (app *application) handleLabelLink(inst *beatport.Beatport, link *beatport.
 				return
 			}
 		})
-		return nil
-	})
+	}
+
+	// Wait for the release workers without holding a global worker slot,
+	// so the link only counts as done once all of its releases are.
+	app.semRelease(app.globalSem)
+	releasesWg.Wait()
+	app.semAcquire(app.globalSem)
+}
 
+func (app *application) handleArtistLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	artist, err := inst.GetArtist(app.ctx, link.ID)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "handle label releases", err)
+		app.failureLogWrapper(link.Original, "fetch artist", linkFetchError(link, err), opts)
 		return
 	}
-}
 
-func (app *application) handleArtistLink(inst *beatport.Beatport, link *beatport.Link) {
-	artist, err := inst.GetArtist(link.ID)
-	if err != nil {
-		app.errorLogWrapper(link.Original, "fetch artist", err)
+	if app.listOnly {
+		app.listArtistTracks(inst, link, artist, opts)
 		return
 	}
 
-	downloadsDir, err := app.setupDownloadsDirectory(app.config.DownloadsDirectory, artist)
+	downloadsDir, err := app.setupDownloadsDirectory(opts.downloadsDirectory, artist)
 	if err != nil {
-		app.errorLogWrapper(link.Original, "setup downloads directory", err)
+		app.failureLogWrapper(link.Original, "setup downloads directory", err, opts)
 		return
 	}
 
+	if app.artistReleases {
+		releases, err := app.artistReleaseList(inst, link)
+		if err != nil {
+			app.failureLogWrapper(link.Original, "fetch artist releases", err, opts)
+			return
+		}
+		app.infoLogWrapper(link.Original, fmt.Sprintf("artist %s resolved to %d releases", artist.Name, len(releases)))
+		app.downloadReleases(inst, releases, downloadsDir, opts)
+		return
+	}
+
+	// A track can be listed more than once, e.g. when the artist is both
+	// the original artist and the remixer.
+	seen := make(map[int64]bool)
+	fetchPage := withPageProgress(app, link.Original, inst.GetArtistTracks)
 	wg := sync.WaitGroup{}
-	err = ForPaginated[beatport.Track](link.ID, link.Params, inst.GetArtistTracks, func(track beatport.Track, i int) error {
+	err = ForPaginated[beatport.Track](app.ctx, link.ID, app.dateRangeParams(link.Params, "publish_date"), fetchPage, func(track beatport.Track, i int) error {
+		if seen[track.ID] || !app.artistTrackWanted(&track) {
+			return nil
+		}
+		seen[track.ID] = true
+		if !app.genreWanted(&track) {
+			return nil
+		}
 		app.downloadWorker(&wg, func() {
 			trackStoreUrl := track.StoreUrl()
-			t, err := inst.GetTrack(track.ID)
+			t, err := inst.GetTrack(app.ctx, track.ID)
 			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch full track", err)
+				app.failureLogWrapper(trackStoreUrl, "fetch full track", err, opts)
 				return
 			}
 
-			release, err := inst.GetRelease(track.Release.ID)
+			release, err := inst.GetRelease(app.ctx, track.Release.ID)
 			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "fetch track release", err)
+				app.failureLogWrapper(trackStoreUrl, "fetch track release", err, opts)
 				return
 			}
 			t.Release = *release
 
 			releaseDir, err := app.setupDownloadsDirectory(downloadsDir, release)
 			if err != nil {
-				app.errorLogWrapper(trackStoreUrl, "setup track release downloads directory", err)
+				app.failureLogWrapper(trackStoreUrl, "setup track release downloads directory", err, opts)
 				return
 			}
 
 			var cover string
-			if app.requireCover(true, true) {
+			if app.requireCover(opts.quality, true, true) {
 				cover, err = app.downloadCover(release.Image, releaseDir)
 				if err != nil {
 					app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
 				}
 			}
 
-			if err := app.handleTrack(inst, t, releaseDir, cover); err != nil {
-				app.errorLogWrapper(trackStoreUrl, "handle track", err)
+			if _, err := app.handleTrack(inst, t, releaseDir, cover, opts); err != nil {
+				app.failureLogWrapper(trackStoreUrl, "handle track", err, opts)
 				os.Remove(cover)
 				app.cleanup(releaseDir)
 				return
@@ -864,7 +1429,7 @@ func (app *application) handleArtistLink(inst *beatport.Beatport, link *beatport
 		return nil
 	})
 	if err != nil {
-		app.errorLogWrapper(link.Original, "handle artist tracks", err)
+		app.failureLogWrapper(link.Original, "handle artist tracks", err, opts)
 		return
 	}
 
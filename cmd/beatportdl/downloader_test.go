@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestQualityUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Quality refused", &beatport.StatusError{StatusCode: 403, Detail: "Requested quality is not available"}, true},
+		{"Wrapped", fmt.Errorf("download: %w", &beatport.StatusError{StatusCode: 400, Detail: "Invalid format"}), true},
+		{"Rejected session", &beatport.StatusError{StatusCode: 401, Detail: "Invalid quality token"}, false},
+		{"Plain 403", &beatport.StatusError{StatusCode: 403}, false},
+		{"Download limit", &beatport.StatusError{StatusCode: 403, Detail: "Download limit reached for this quality"}, false},
+		{"Rate limited", &beatport.StatusError{StatusCode: 429}, false},
+		{"Network error", errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := qualityUnavailable(tt.err); got != tt.expected {
+				t.Errorf("qualityUnavailable(%v) = %v, expected %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLinkFetchError(t *testing.T) {
+	notFound := &beatport.StatusError{StatusCode: 404}
+	link := &beatport.Link{Type: beatport.PlaylistLink, ID: 42, Store: beatport.StoreBeatport}
+	if err := linkFetchError(link, notFound); err.Error() != "playlist 42 not found" {
+		t.Errorf("linkFetchError() = %q, expected playlist 42 not found", err)
+	}
+
+	link = &beatport.Link{Type: beatport.ReleaseLink, ID: 7, Store: beatport.StoreBeatsource}
+	err := linkFetchError(link, &beatport.StatusError{StatusCode: 403})
+	if !strings.Contains(err.Error(), "Beatsource subscription") {
+		t.Errorf("linkFetchError() = %q, expected a hint about the Beatsource subscription", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// dryRunTrack prints the file a track would be downloaded to and its
+// estimated size, after the same checks for an existing file as a download.
+// Nothing is requested from the download API, so no quota is used.
+func (app *application) dryRunTrack(track *beatport.Track, directory, fileName, quality string) error {
+	filePath := filepath.Join(directory, fileName+qualityExtension(quality))
+	action := "Would download"
+	if _, err := os.Stat(filePath); err == nil && !app.forceDownload {
+		trackExists := app.config.TrackExists
+		if app.config.SkipExisting {
+			if app.existingFileComplete(filePath, nil, app.downloadClient()) {
+				trackExists = "skip"
+			} else {
+				trackExists = "overwrite"
+			}
+		}
+		switch trackExists {
+		case "skip":
+			app.infoLogWrapper(track.StoreUrl(), "skipping, already exists")
+			app.skippedCount.Add(1)
+			return nil
+		case "update":
+			app.infoLogWrapper(track.StoreUrl(), "would update tags of "+filePath)
+			return nil
+		case "error":
+			return ErrTrackFileExists
+		}
+		action = "Would overwrite"
+	}
+
+	fmt.Printf("%s %s (~%s)\n", action, filePath, formatByteSize(uint64(app.estimateTrackSize(track, quality))))
+	app.downloadedCount.Add(1)
+	return nil
+}
+
+// estimateTrackSize scales the estimated size of a 7 minute track in
+// quality to the length of track.
+func (app *application) estimateTrackSize(track *beatport.Track, quality string) int64 {
+	size, _ := config.ParseByteSize(app.config.EstimatedTrackSize)
+	if size == 0 {
+		size = estimatedTrackSizes[quality]
+	}
+	if track.LengthMs <= 0 {
+		return size
+	}
+	return size * int64(track.LengthMs) / (7 * 60 * 1000)
+}
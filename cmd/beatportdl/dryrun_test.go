@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestDryRunTrack(t *testing.T) {
+	dir := t.TempDir()
+	app := &application{
+		config:    &config.AppConfig{Quality: "lossless", TrackExists: "skip"},
+		logWriter: io.Discard,
+		dryRun:    true,
+	}
+	track := &beatport.Track{LengthMs: 210000}
+
+	if err := app.dryRunTrack(track, dir, "new", "lossless"); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "existing.flac"), nil, 0644)
+	if err := app.dryRunTrack(track, dir, "existing", "lossless"); err != nil {
+		t.Fatal(err)
+	}
+	if app.downloadedCount.Load() != 1 || app.skippedCount.Load() != 1 {
+		t.Errorf("Expected 1 track to download and 1 skipped, got %d and %d", app.downloadedCount.Load(), app.skippedCount.Load())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Error("Dry run created files")
+	}
+	if app.recordsDownloads() {
+		t.Error("Dry run adds downloads to the download archive")
+	}
+
+	if size := app.estimateTrackSize(track, "lossless"); size != estimatedTrackSizes["lossless"]/2 {
+		t.Errorf("estimateTrackSize() = %d, expected half of a 7 minute track", size)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// errorLog is the error log file. Once it would grow past maxSize, it is
+// moved to <path>.1, replacing the previous one, and a new file is started.
+type errorLog struct {
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+	mutex   sync.Mutex
+}
+
+func openErrorLog(path string, maxSize int64) (*errorLog, error) {
+	l := &errorLog{
+		path:    path,
+		maxSize: maxSize,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *errorLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *errorLog) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, fmt.Errorf("rotate error log: %w", err)
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *errorLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		// Keep appending to the full file rather than losing messages.
+		if openErr := l.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	return l.open()
+}
+
+// writeLine writes message as one line with a timestamp and level, e.g.
+// "2024-05-01T12:00:00Z [ERROR] fetch track: request failed".
+func (l *errorLog) writeLine(level string, message string) {
+	l.Write([]byte(fmt.Sprintf("%s [%s] %s\n", time.Now().UTC().Format(time.RFC3339), level, message)))
+}
+
+func (l *errorLog) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.file.Close()
+}
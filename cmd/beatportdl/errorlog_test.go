@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestErrorLogRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-err.log")
+	l, err := openErrorLog(path, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	l.writeLine("ERROR", "first: "+strings.Repeat("x", 40))
+	l.writeLine("ERROR", "second: "+strings.Repeat("x", 40))
+
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("Log not rotated: %v", err)
+	}
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rotated), "first: ") || !strings.Contains(string(current), "second: ") {
+		t.Errorf("Rotated log %q and current log %q, expected the first and second line", rotated, current)
+	}
+
+	line := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z \[ERROR\] second: x+\n$`)
+	if !line.Match(current) {
+		t.Errorf("Unexpected log line %q", current)
+	}
+}
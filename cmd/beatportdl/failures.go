@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// failureLogWrapper logs an error that caused url to fail and records url
+// with the options of the queued URL it came from, so it ends up in the
+// failed URLs file at the end of the batch and is retried with the same
+// options. Errors after a shutdown signal are not recorded.
+func (app *application) failureLogWrapper(url, step string, err error, opts urlOptions) {
+	app.errorLogWrapper(url, step, err)
+	if app.ctx.Err() != nil {
+		return
+	}
+	app.failedUrlsMutex.Lock()
+	defer app.failedUrlsMutex.Unlock()
+	entry := opts.entry(url)
+	if !slices.Contains(app.failedUrls, entry) {
+		app.failedUrls = append(app.failedUrls, entry)
+	}
+}
+
+// writeFailedUrls overwrites the failed URLs file with the failures of the
+// last batch, one URL per line, and returns the number of URLs written. The
+// file is removed after a batch without failures, so retrying from it
+// doesn't download the URLs of an earlier batch again. A dry run leaves the
+// file alone.
+func (app *application) writeFailedUrls() (int, error) {
+	app.failedUrlsMutex.Lock()
+	defer app.failedUrlsMutex.Unlock()
+	if app.failedFilePath == "" || app.dryRun {
+		return 0, nil
+	}
+	if len(app.failedUrls) == 0 {
+		if err := os.Remove(app.failedFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, fmt.Errorf("remove failed urls: %w", err)
+		}
+		return 0, nil
+	}
+	data := strings.Join(app.failedUrls, "\n") + "\n"
+	if err := os.WriteFile(app.failedFilePath, []byte(data), 0644); err != nil {
+		return 0, fmt.Errorf("write failed urls: %w", err)
+	}
+	return len(app.failedUrls), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"unspok3n/beatportdl/config"
+)
+
+func TestFailureLogWrapperOptions(t *testing.T) {
+	app := &application{ctx: context.Background(), logWriter: io.Discard}
+	opts := newUrlOptions(&config.AppConfig{}, []string{"quality=high"})
+	app.failureLogWrapper("https://www.beatport.com/track/a/1", "handle track", errors.New("failed"), opts)
+	app.failureLogWrapper("https://www.beatport.com/track/b/2", "handle track", errors.New("failed"), urlOptions{})
+	expected := []string{"https://www.beatport.com/track/a/1 quality=high", "https://www.beatport.com/track/b/2"}
+	if !slices.Equal(app.failedUrls, expected) {
+		t.Errorf("failedUrls = %q, expected %q", app.failedUrls, expected)
+	}
+}
+
+func TestWriteFailedUrls(t *testing.T) {
+	app := &application{failedFilePath: filepath.Join(t.TempDir(), failedFilename)}
+
+	app.failedUrls = []string{"https://www.beatport.com/track/a/1", "https://www.beatport.com/release/b/2"}
+	if n, err := app.writeFailedUrls(); err != nil || n != 2 {
+		t.Fatalf("writeFailedUrls() = %d, %v, expected 2 URLs", n, err)
+	}
+	data, _ := os.ReadFile(app.failedFilePath)
+	if string(data) != "https://www.beatport.com/track/a/1\nhttps://www.beatport.com/release/b/2\n" {
+		t.Errorf("Failed URLs file contains %q", data)
+	}
+
+	app.dryRun = true
+	app.failedUrls = nil
+	if n, err := app.writeFailedUrls(); err != nil || n != 0 {
+		t.Fatalf("writeFailedUrls() in a dry run = %d, %v, expected no URLs", n, err)
+	}
+	if _, err := os.Stat(app.failedFilePath); err != nil {
+		t.Errorf("Dry run removed the failed URLs file: %v", err)
+	}
+
+	app.dryRun = false
+	if n, err := app.writeFailedUrls(); err != nil || n != 0 {
+		t.Fatalf("writeFailedUrls() = %d, %v, expected no URLs", n, err)
+	}
+	if _, err := os.Stat(app.failedFilePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Failed URLs file of the earlier batch kept after a batch without failures")
+	}
+	if _, err := app.writeFailedUrls(); err != nil {
+		t.Errorf("writeFailedUrls() without a file failed: %v", err)
+	}
+}
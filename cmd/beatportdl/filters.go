@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// trackFilter holds the --bpm and --key filters. The zero value matches
+// every track.
+type trackFilter struct {
+	minBPM int
+	maxBPM int
+	// keys are in Camelot notation.
+	keys map[string]bool
+}
+
+// parseTrackFilter parses a BPM range like 120-128 (or a single BPM) and a
+// comma-separated list of keys in any notation accepted by
+// beatport.CamelotKey.
+func parseTrackFilter(bpm, keys string) (trackFilter, error) {
+	var filter trackFilter
+	if bpm != "" {
+		first, last, isRange := strings.Cut(bpm, "-")
+		minBPM, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return filter, fmt.Errorf("invalid bpm range: %s", bpm)
+		}
+		maxBPM := minBPM
+		if isRange {
+			if maxBPM, err = strconv.Atoi(strings.TrimSpace(last)); err != nil || maxBPM < minBPM {
+				return filter, fmt.Errorf("invalid bpm range: %s", bpm)
+			}
+		}
+		filter.minBPM, filter.maxBPM = minBPM, maxBPM
+	}
+	if keys != "" {
+		filter.keys = make(map[string]bool)
+		for _, key := range strings.Split(keys, ",") {
+			camelot, err := beatport.CamelotKey(key)
+			if err != nil {
+				return filter, err
+			}
+			filter.keys[camelot] = true
+		}
+	}
+	return filter, nil
+}
+
+func (f trackFilter) matches(track *beatport.Track) bool {
+	if f.maxBPM > 0 && (track.BPM < f.minBPM || track.BPM > f.maxBPM) {
+		return false
+	}
+	return len(f.keys) == 0 || f.keys[track.Key.Display("camelot")]
+}
+
+// trackFilterWanted applies the --bpm and --key filters to a track, counting
+// the tracks left out for the summary. Dry runs list them, so the filters
+// can be checked before downloading.
+func (app *application) trackFilterWanted(track *beatport.Track) bool {
+	if app.trackFilter.matches(track) {
+		return true
+	}
+	app.filterSkipCount.Add(1)
+	details := fmt.Sprintf("%d BPM, %s", track.BPM, track.Key.Display("camelot"))
+	if app.dryRun {
+		fmt.Printf("Filtered out %s - %s (%s) [%s]\n", track.Artists.Display(0, ""), track.Name, track.MixName, details)
+		return false
+	}
+	app.infoLogWrapper(track.StoreUrl(), "skipping, outside bpm/key filter ("+details+")")
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestTrackFilter(t *testing.T) {
+	filter, err := parseTrackFilter("120-128", "8A, Em")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(number int, letter string) beatport.Key {
+		return beatport.Key{CamelotNumber: number, CamelotLetter: letter}
+	}
+	tests := []struct {
+		track   beatport.Track
+		matches bool
+	}{
+		{beatport.Track{BPM: 124, Key: key(8, "A")}, true},
+		{beatport.Track{BPM: 128, Key: key(9, "A")}, true},
+		{beatport.Track{BPM: 130, Key: key(8, "A")}, false},
+		{beatport.Track{BPM: 124, Key: key(8, "B")}, false},
+	}
+	for _, tt := range tests {
+		if filter.matches(&tt.track) != tt.matches {
+			t.Errorf("matches(%d BPM, %s) = %v", tt.track.BPM, tt.track.Key.Display("camelot"), !tt.matches)
+		}
+	}
+
+	for _, bpm := range []string{"fast", "128-120"} {
+		if _, err := parseTrackFilter(bpm, ""); err == nil {
+			t.Errorf("parseTrackFilter(%q) accepted an invalid range", bpm)
+		}
+	}
+}
+
+func TestTrackFilterCollectionsOnly(t *testing.T) {
+	filter, err := parseTrackFilter("120-128", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := &beatport.Track{ID: 1, BPM: 140}
+	app := &application{
+		config:      &config.AppConfig{},
+		logWriter:   io.Discard,
+		trackFilter: filter,
+		archive: &downloadArchive{entries: map[string]struct{}{
+			archiveEntry(track.Store, "track", track.ID): {},
+		}},
+	}
+
+	if _, err := app.handleTrack(nil, track, "", "", urlOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if app.filterSkipCount.Load() != 1 || app.skippedCount.Load() != 0 {
+		t.Errorf("Collection track wasn't filtered out")
+	}
+
+	if _, err := app.downloadTrack(nil, track, "", "", urlOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if app.filterSkipCount.Load() != 1 || app.skippedCount.Load() != 1 {
+		t.Errorf("Linked track was filtered out instead of reaching the download archive")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// genreFilter holds the values of the repeatable --genre flag, genre or
+// sub-genre names or IDs.
+type genreFilter []string
+
+func (f *genreFilter) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *genreFilter) Set(value string) error {
+	*f = append(*f, strings.TrimSpace(value))
+	return nil
+}
+
+// matches reports whether the genre or sub-genre of track is in the filter,
+// by case-insensitive name or by ID. An empty filter matches every track.
+func (f genreFilter) matches(track *beatport.Track) bool {
+	if len(f) == 0 {
+		return true
+	}
+	genres := []beatport.Genre{track.Genre}
+	if track.Subgenre != nil {
+		genres = append(genres, *track.Subgenre)
+	}
+	return slices.ContainsFunc(f, func(value string) bool {
+		id, err := strconv.ParseInt(value, 10, 64)
+		return slices.ContainsFunc(genres, func(genre beatport.Genre) bool {
+			if err == nil {
+				return genre.ID == id
+			}
+			return strings.EqualFold(genre.Name, value)
+		})
+	})
+}
+
+// genreWanted applies the --genre filter to a track of a label or artist
+// catalog, counting the tracks left out for the summary.
+func (app *application) genreWanted(track *beatport.Track) bool {
+	if app.genres.matches(track) {
+		return true
+	}
+	app.debugLogWrapper(track.StoreUrl(), "skipping, outside genre filter ("+track.GenreWithSubgenre("|")+")")
+	app.genreSkipCount.Add(1)
+	return false
+}
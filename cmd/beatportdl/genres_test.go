@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestGenreFilter(t *testing.T) {
+	track := &beatport.Track{
+		Genre:    beatport.Genre{ID: 6, Name: "Techno (Peak Time / Driving)"},
+		Subgenre: &beatport.Genre{ID: 196, Name: "Driving"},
+	}
+	tests := []struct {
+		filter  genreFilter
+		matches bool
+	}{
+		{nil, true},
+		{genreFilter{"techno (peak time / driving)"}, true},
+		{genreFilter{"Hard Techno", "driving"}, true},
+		{genreFilter{"6"}, true},
+		{genreFilter{"196"}, true},
+		{genreFilter{"Hard Techno", "2"}, false},
+	}
+	for _, tt := range tests {
+		if tt.filter.matches(track) != tt.matches {
+			t.Errorf("%v matches() = %v", tt.filter, !tt.matches)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// hookCommand returns the command that runs the post_download_hook command
+// line through the system shell, so it can use pipes and quoting.
+func (app *application) hookCommand(commandLine string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(app.downloadCtx, "cmd", "/C", commandLine)
+	}
+	return exec.CommandContext(app.downloadCtx, "sh", "-c", commandLine)
+}
+
+// hookEnv returns the environment of the post-download hook of a track
+// downloaded to location. The values are passed as variables instead of
+// being substituted into the command line, so names with quotes or shell
+// characters can't break it.
+func hookEnv(track *beatport.Track, location string) []string {
+	return append(os.Environ(),
+		"FILE="+location,
+		"ARTIST="+track.Artists.Display(0, ""),
+		"TITLE="+fmt.Sprintf("%s (%s)", track.Name.String(), track.MixName.String()),
+		"RELEASE="+track.Release.Name.String(),
+		"LABEL="+track.Release.Label.Name,
+		"ISRC="+track.ISRC,
+		"TRACK_URL="+track.StoreUrl(),
+	)
+}
+
+// runPostDownloadHook runs post_download_hook for a track downloaded to
+// location. Hooks run in the background, at most max_hook_workers at a
+// time, and the batch waits for them before it ends. Their output goes to
+// the log; a failing hook is logged and doesn't stop the batch.
+func (app *application) runPostDownloadHook(track *beatport.Track, location string) {
+	commandLine := app.config.PostDownloadHook
+	if commandLine == "" || location == "" {
+		return
+	}
+	url := track.StoreUrl()
+	env := hookEnv(track, location)
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+		app.semAcquire(app.hookSem)
+		defer app.semRelease(app.hookSem)
+
+		cmd := app.hookCommand(commandLine)
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		output = []byte(strings.TrimSpace(string(output)))
+		if err != nil {
+			if len(output) > 0 {
+				err = fmt.Errorf("%w\n%s", err, output)
+			}
+			app.errorLogWrapper(url, "run post-download hook", err)
+			return
+		}
+		if len(output) > 0 {
+			app.infoLogWrapper(url, fmt.Sprintf("post-download hook:\n%s", output))
+		}
+	}()
+}
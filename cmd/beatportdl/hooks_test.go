@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestRunPostDownloadHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook command uses sh syntax")
+	}
+	var log bytes.Buffer
+	app := &application{
+		config:      &config.AppConfig{PostDownloadHook: `printf '%s|%s' "$ARTIST" "$TITLE" > "$FILE.txt"; echo "$RELEASE"`},
+		logWriter:   &log,
+		logLevel:    logLevelInfo,
+		downloadCtx: context.Background(),
+		hookSem:     newSemaphore(1),
+	}
+	location := t.TempDir() + "/track.flac"
+	track := &beatport.Track{
+		ID:      1,
+		Name:    "Strobe",
+		MixName: "Club Edit",
+		Artists: beatport.Artists{{Name: "deadmau5"}},
+		Release: beatport.Release{Name: "For Lack Of A Better Name"},
+	}
+
+	app.runPostDownloadHook(track, location)
+	app.wg.Wait()
+	if data, _ := os.ReadFile(location + ".txt"); string(data) != "deadmau5|Strobe (Club Edit)" {
+		t.Errorf("Hook wrote %q", data)
+	}
+	if !strings.Contains(log.String(), "For Lack Of A Better Name") {
+		t.Errorf("Hook output missing from the log:\n%s", log.String())
+	}
+
+	log.Reset()
+	app.config.PostDownloadHook = "echo broken >&2; exit 3"
+	app.runPostDownloadHook(track, location)
+	app.wg.Wait()
+	if !strings.Contains(log.String(), "run post-download hook") || !strings.Contains(log.String(), "broken") {
+		t.Errorf("Failing hook not logged with its output:\n%s", log.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
@@ -20,49 +21,25 @@ func Setup() (cfg *config.AppConfig, cachePath string, err error) {
 
 	if !exists {
 		fmt.Println("Config file not found, creating a new one:", configFilePath)
-
-		fmt.Print("Username: ")
-		username := GetLine()
-		fmt.Print("Password: ")
-		password := GetLine()
-		fmt.Print("Downloads directory: ")
-		downloadsDir := GetLine()
-
-		cfg := &config.AppConfig{
-			Username:           username,
-			Password:           password,
-			DownloadsDirectory: downloadsDir,
-		}
-
-		fmt.Println("1. Lossless (44.1 khz FLAC)\n2. High (256 kbps AAC)\n3. Medium (128 kbps AAC)\n4. Medium HLS (128 kbps AAC)")
-		for {
-			fmt.Print("Quality: ")
-			qualityNumber := GetLine()
-			switch qualityNumber {
-			case "1":
-				cfg.Quality = "lossless"
-			case "2":
-				cfg.Quality = "high"
-			case "3":
-				cfg.Quality = "medium"
-			case "4":
-				cfg.Quality = "medium-hls"
-			default:
-				fmt.Println("Invalid quality")
-				continue
-			}
-			break
+		cfg, err := newConfig(stdinInteractive())
+		if err != nil {
+			return nil, configFilePath, err
 		}
-
 		if err := cfg.Save(configFilePath); err != nil {
 			return nil, configFilePath, fmt.Errorf("save config: %w", err)
 		}
 	}
 
 	parsedConfig, err := config.Parse(configFilePath)
+	if err == nil {
+		err = parsedConfig.ApplyEnv()
+	}
 	if err != nil {
 		return nil, configFilePath, fmt.Errorf("load config: %w", err)
 	}
+	if err := parsedConfig.Validate(); err != nil {
+		return nil, configFilePath, fmt.Errorf("invalid config: %w", err)
+	}
 
 	cacheFilePath, exists, err := FindCacheFile()
 	if err != nil {
@@ -72,17 +49,159 @@ func Setup() (cfg *config.AppConfig, cachePath string, err error) {
 	return parsedConfig, cacheFilePath, nil
 }
 
+// newConfig asks for the settings of a new config file. The username and
+// password aren't asked for when BEATPORT_USERNAME and BEATPORT_PASSWORD
+// are set, and aren't saved either, as ApplyEnv supplies them on every run.
+// Without a terminal on stdin nothing is asked: the downloads directory is
+// the working directory and the quality is left to the default.
+func newConfig(interactive bool) (*config.AppConfig, error) {
+	cfg := &config.AppConfig{}
+	if os.Getenv("BEATPORT_USERNAME") == "" || os.Getenv("BEATPORT_PASSWORD") == "" {
+		if !interactive {
+			return nil, errors.New("no config file, and BEATPORT_USERNAME and BEATPORT_PASSWORD aren't set")
+		}
+		fmt.Print("Username: ")
+		cfg.Username = GetLine()
+		fmt.Print("Password: ")
+		cfg.Password = GetLine()
+	}
+
+	if !interactive {
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("get working directory: %w", err)
+		}
+		cfg.DownloadsDirectory = dir
+		return cfg, nil
+	}
+	fmt.Print("Downloads directory: ")
+	cfg.DownloadsDirectory = GetLine()
+
+	fmt.Println("1. Lossless (44.1 khz FLAC)\n2. High (256 kbps AAC)\n3. Medium (128 kbps AAC)\n4. Medium HLS (128 kbps AAC)")
+	for {
+		fmt.Print("Quality: ")
+		qualityNumber := GetLine()
+		switch qualityNumber {
+		case "1":
+			cfg.Quality = "lossless"
+		case "2":
+			cfg.Quality = "high"
+		case "3":
+			cfg.Quality = "medium"
+		case "4":
+			cfg.Quality = "medium-hls"
+		default:
+			fmt.Println("Invalid quality")
+			continue
+		}
+		break
+	}
+	return cfg, nil
+}
+
+// encryptPassword prints the encrypted form of a password, given as an
+// argument or entered, for the password option of the config file. Without
+// BEATPORTDL_KEY, a new key is generated and printed too.
+func encryptPassword(args []string) {
+	key, err := config.PasswordKey()
+	if errors.Is(err, config.ErrPasswordKeyMissing) {
+		encodedKey, err := config.NewPasswordKey()
+		if err != nil {
+			fmt.Println("generate key:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Generated a new key, set it in the environment to decrypt the password:\n%s=%s\n", config.PasswordKeyEnv, encodedKey)
+		os.Setenv(config.PasswordKeyEnv, encodedKey)
+		key, err = config.PasswordKey()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	password := strings.Join(args, " ")
+	if password == "" {
+		fmt.Print("Password: ")
+		password = GetLine()
+	}
+	encrypted, err := config.EncryptPassword(password, key)
+	if err != nil {
+		fmt.Println("encrypt password:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("password: %s\n", encrypted)
+}
+
 func (app *application) mainPrompt() {
 	fmt.Print("Enter url or search query: ")
 	input := GetLine()
-	if strings.HasPrefix(input, "https://www.beatport.com") || strings.HasPrefix(input, "https://www.beatsource.com") {
+	if input == "accounts" {
+		app.printAccounts()
+	} else if input == "library" {
+		app.urls = append(app.urls, libraryURL)
+	} else if input == "cart" {
+		app.urls = append(app.urls, cartURL)
+	} else if searchType, query, ok := parseSearchCommand(input); ok {
+		app.search(query, searchType)
+	} else if strings.HasPrefix(input, "https://") || strings.HasPrefix(input, "http://") || beatport.IsIDLink(input) {
 		app.urls = append(app.urls, input)
 	} else {
-		app.search(input)
+		app.search(input, "")
 	}
 }
 
-func (app *application) search(input string) {
+// searchTypes maps the values of the search --type flag to the result types
+// of the API.
+var searchTypes = map[string]string{
+	"track":   "tracks",
+	"release": "releases",
+	"artist":  "artists",
+}
+
+const searchPageSize = 10
+
+// parseSearchCommand parses the search command of the prompt, "search
+// <query>" for tracks or "search:<type> <query>" for another result type.
+func parseSearchCommand(input string) (searchType, query string, ok bool) {
+	command, query, _ := strings.Cut(strings.TrimSpace(input), " ")
+	query = strings.TrimSpace(query)
+	if command == "search" && query != "" {
+		return "track", query, true
+	}
+	searchType, found := strings.CutPrefix(command, "search:")
+	if !found || searchTypes[searchType] == "" || query == "" {
+		return "", "", false
+	}
+	return searchType, query, true
+}
+
+// parseSelection parses the result numbers entered after a search, separated
+// by commas or spaces, with ranges like 1-4.
+func parseSelection(input string, count int) ([]int, error) {
+	var selection []int
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		first, last, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result number: %s", field)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid result range: %s", field)
+			}
+		}
+		if start < 1 || end > count {
+			return nil, fmt.Errorf("invalid result number: %s", field)
+		}
+		for n := start; n <= end; n++ {
+			selection = append(selection, n)
+		}
+	}
+	return selection, nil
+}
+
+func (app *application) search(input string, searchType string) {
 	var storeTag string
 	var inst *beatport.Beatport
 	storeTag, input = extractStoreTag(input)
@@ -93,37 +212,87 @@ func (app *application) search(input string) {
 		inst = app.bs
 	}
 
-	results, err := inst.Search(input)
-	if err != nil {
-		app.FatalError("beatport", err)
+	if err := app.loginActiveAccount(); err != nil {
+		app.FatalError("log in", err)
+	}
+	params := beatport.SearchParams{
+		Type:    searchTypes[searchType],
+		Page:    1,
+		PerPage: searchPageSize,
 	}
-	trackResultsLen := len(results.Tracks)
-	releasesResultsLen := len(results.Releases)
+	for {
+		results, err := inst.Search(app.ctx, input, params)
+		if err != nil {
+			app.FatalError("beatport", err)
+		}
+		urls := app.printSearchResults(results, params.Page)
+		if len(urls) == 0 {
+			if params.Page == 1 {
+				fmt.Println("No results found")
+				return
+			}
+			fmt.Println("No more results")
+			params.Page--
+			continue
+		}
 
-	if trackResultsLen+releasesResultsLen == 0 {
-		fmt.Println("No results found")
+		fmt.Print("Enter the result numbers (like 1,3 or 1-4), n for the next page or p for the previous one: ")
+		input := strings.TrimSpace(GetLine())
+		switch input {
+		case "n":
+			params.Page++
+			continue
+		case "p":
+			params.Page = max(params.Page-1, 1)
+			continue
+		}
+		selection, err := parseSelection(input, len(urls))
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		for _, n := range selection {
+			app.urls = append(app.urls, urls[n-1])
+		}
 		return
 	}
+}
 
-	fmt.Println("Search results:")
-	fmt.Println("[ Tracks ]")
-	for i, track := range results.Tracks {
+// printSearchResults prints a page of search results, numbered across the
+// result types, and returns their URLs in the same order.
+func (app *application) printSearchResults(results *beatport.SearchResults, page int) []string {
+	if len(results.Tracks)+len(results.Releases)+len(results.Artists) == 0 {
+		return nil
+	}
+	var urls []string
+	fmt.Printf("Search results (page %d):\n", page)
+	if len(results.Tracks) > 0 {
+		fmt.Println("[ Tracks ]")
+	}
+	for _, track := range results.Tracks {
+		urls = append(urls, track.StoreUrl())
 		fmt.Printf(
-			"%2d. %s - %s (%s) [%s]\n", i+1,
+			"%2d. %s - %s (%s) - %s - %s - %s - %s - %d BPM\n", len(urls),
 			track.Artists.Display(
 				app.config.ArtistsLimit,
 				app.config.ArtistsShortForm,
 			),
 			track.Name.String(),
 			track.MixName.String(),
-			track.Length,
+			track.Release.Label.Name,
+			track.Year(),
+			track.LengthMs.Display(),
+			track.Key.Display(app.config.KeySystem),
+			track.BPM,
 		)
 	}
-	fmt.Println("\n[ Releases ]")
-	indexOffset := trackResultsLen + 1
-	for i, release := range results.Releases {
+	if len(results.Releases) > 0 {
+		fmt.Println("[ Releases ]")
+	}
+	for _, release := range results.Releases {
+		urls = append(urls, release.StoreUrl())
 		fmt.Printf(
-			"%2d. %s - %s [%s]\n", i+indexOffset,
+			"%2d. %s - %s - %s\n", len(urls),
 			release.Artists.Display(
 				app.config.ArtistsLimit,
 				app.config.ArtistsShortForm,
@@ -132,27 +301,14 @@ func (app *application) search(input string) {
 			release.Label.Name,
 		)
 	}
-	fmt.Print("Enter the result number(s): ")
-	input = GetLine()
-	requestedResults := strings.Split(input, " ")
-	for _, result := range requestedResults {
-		resultInt, err := strconv.Atoi(result)
-		if err != nil {
-			fmt.Printf("invalid result number: %s\n", result)
-			continue
-		}
-
-		if resultInt > releasesResultsLen+trackResultsLen || resultInt == 0 {
-			fmt.Printf("invalid result number: %d\n", resultInt)
-			continue
-		}
-
-		if resultInt >= indexOffset {
-			app.urls = append(app.urls, results.Releases[resultInt-indexOffset].URL)
-		} else {
-			app.urls = append(app.urls, results.Tracks[resultInt-1].URL)
-		}
+	if len(results.Artists) > 0 {
+		fmt.Println("[ Artists ]")
+	}
+	for _, artist := range results.Artists {
+		urls = append(urls, artist.StoreUrl())
+		fmt.Printf("%2d. %s\n", len(urls), artist.Name)
 	}
+	return urls
 }
 
 func extractStoreTag(query string) (store, trimmedQuery string) {
@@ -168,17 +324,96 @@ func extractStoreTag(query string) (store, trimmedQuery string) {
 	return store, trimmedQuery
 }
 
+// parseTextFile queues the URLs in the text file at path, one per line.
+// Lines are trimmed, blank lines and comments starting with # are skipped,
+// and URLs that are already queued are queued once. Options can follow a
+// URL, like "quality=lossless dir=Techno/2024"; malformed ones are logged
+// and left out.
 func (app *application) parseTextFile(path string) {
 	file, err := os.Open(path)
+	if err != nil {
+		app.FatalError("read input text file", err)
+	}
 	defer file.Close()
+	urls, err := readURLs(file, app.urls, func(line int, err error) {
+		app.LogError("parse url options", fmt.Errorf("%s line %d: %w", path, line, err))
+	})
 	if err != nil {
 		app.FatalError("read input text file", err)
 	}
+	app.urls = append(app.urls, urls...)
+}
+
+// parseStdin queues the URLs piped to stdin, like parseTextFile.
+func (app *application) parseStdin() {
+	urls, err := readURLs(os.Stdin, app.urls, func(line int, err error) {
+		app.LogError("parse url options", fmt.Errorf("stdin line %d: %w", line, err))
+	})
+	if err != nil {
+		app.FatalError("read urls from stdin", err)
+	}
+	app.urls = append(app.urls, urls...)
+}
+
+var inlineCommentRegex = regexp.MustCompile(`\s+#.*$`)
+
+// readURLs returns the URLs of r, with their options, that aren't in
+// queued, each only once. Text after a # preceded by whitespace is a
+// comment. Malformed options are passed to warn with their line number and
+// left out.
+func readURLs(r io.Reader, queued []string, warn func(line int, err error)) ([]string, error) {
+	seen := make(map[string]bool, len(queued))
+	for _, url := range queued {
+		seen[url] = true
+	}
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(inlineCommentRegex.ReplaceAllString(scanner.Text(), ""))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		url, tokens := splitUrlEntry(line)
+		entry := []string{url}
+		for _, token := range tokens {
+			option, err := parseUrlOption(token)
+			if err != nil {
+				warn(n, err)
+				continue
+			}
+			entry = append(entry, option)
+		}
+		line = strings.Join(entry, " ")
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// parseISRCFile queues an isrc: link for each ISRC in the text file at path,
+// one per line.
+func (app *application) parseISRCFile(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		app.FatalError("read isrc file", err)
+	}
+	defer file.Close()
 	scanner := bufio.NewScanner(file)
 	scanner.Split(bufio.ScanLines)
 
 	for scanner.Scan() {
-		app.urls = append(app.urls, scanner.Text())
+		isrc := strings.TrimSpace(scanner.Text())
+		if isrc == "" {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(isrc), "isrc:") {
+			isrc = "isrc:" + isrc
+		}
+		app.urls = append(app.urls, isrc)
 	}
 }
 
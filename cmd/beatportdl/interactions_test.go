@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unspok3n/beatportdl/config"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []int
+		fail     bool
+	}{
+		{"", nil, false},
+		{"1,3,5", []int{1, 3, 5}, false},
+		{"1-4", []int{1, 2, 3, 4}, false},
+		{"2 7-8, 10", []int{2, 7, 8, 10}, false},
+		{"0", nil, true},
+		{"9-11", nil, true},
+		{"4-2", nil, true},
+		{"a", nil, true},
+	}
+	for _, tt := range tests {
+		selection, err := parseSelection(tt.input, 10)
+		if (err != nil) != tt.fail || !slices.Equal(selection, tt.expected) {
+			t.Errorf("parseSelection(%q) = %v, %v, expected %v", tt.input, selection, err, tt.expected)
+		}
+	}
+}
+
+func TestParseSearchCommand(t *testing.T) {
+	tests := []struct {
+		input, searchType, query string
+		ok                       bool
+	}{
+		{"search deadmau5 strobe", "track", "deadmau5 strobe", true},
+		{"search:release  for lack of a better name", "release", "for lack of a better name", true},
+		{"search:remix strobe", "", "", false},
+		{"search", "", "", false},
+		{"deadmau5 strobe", "", "", false},
+	}
+	for _, tt := range tests {
+		searchType, query, ok := parseSearchCommand(tt.input)
+		if searchType != tt.searchType || query != tt.query || ok != tt.ok {
+			t.Errorf("parseSearchCommand(%q) = %q, %q, %v", tt.input, searchType, query, ok)
+		}
+	}
+}
+
+func TestParseTextFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	input := "# New releases\r\n" +
+		"  https://www.beatport.com/release/a/1  \r\n" +
+		"\r\n" +
+		"https://www.beatport.com/chart/b/2#1-20\t# first page\n" +
+		"\t\n" +
+		"https://www.beatport.com/release/a/1\n" +
+		"https://www.beatport.com/track/c/3 # already queued\n" +
+		"   # indented comment\n" +
+		"https://www.beatport.com/label/d/4\n" +
+		"https://www.beatport.com/release/e/5 quality=FLAC  dir=Techno/2024 speed=fast\n" +
+		"https://www.beatport.com/release/e/5 quality=lossless dir=Techno/2024"
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var log strings.Builder
+	app := &application{urls: []string{"https://www.beatport.com/track/c/3"}, logWriter: &log}
+	app.parseTextFile(path)
+	expected := []string{
+		"https://www.beatport.com/track/c/3",
+		"https://www.beatport.com/release/a/1",
+		"https://www.beatport.com/chart/b/2#1-20",
+		"https://www.beatport.com/label/d/4",
+		"https://www.beatport.com/release/e/5 quality=lossless dir=Techno/2024",
+	}
+	if !slices.Equal(app.urls, expected) {
+		t.Errorf("urls = %q, expected %q", app.urls, expected)
+	}
+	if !strings.Contains(log.String(), "line 10: unknown option") {
+		t.Errorf("Expected a warning for the unknown option on line 10, got %q", log.String())
+	}
+}
+
+func TestNewUrlOptions(t *testing.T) {
+	cfg := &config.AppConfig{Quality: "medium", DownloadsDirectory: "/music"}
+	if opts := newUrlOptions(cfg, nil); opts.quality != "medium" || opts.downloadsDirectory != "/music" {
+		t.Errorf("newUrlOptions() without options = %s in %s, expected the config's", opts.quality, opts.downloadsDirectory)
+	}
+	opts := newUrlOptions(cfg, []string{"quality=lossless", "dir=Techno/2024"})
+	if opts.quality != "lossless" || opts.downloadsDirectory != filepath.Join("/music", "Techno/2024") {
+		t.Errorf("newUrlOptions() = %s in %s", opts.quality, opts.downloadsDirectory)
+	}
+	if cfg.Quality != "medium" {
+		t.Error("newUrlOptions() changed the global config")
+	}
+	if entry := opts.entry("https://www.beatport.com/track/a/1"); entry != "https://www.beatport.com/track/a/1 quality=lossless dir=Techno/2024" {
+		t.Errorf("entry() = %q", entry)
+	}
+}
+
+func TestNewConfigFromEnv(t *testing.T) {
+	t.Setenv("BEATPORT_USERNAME", "user")
+	t.Setenv("BEATPORT_PASSWORD", "secret")
+	cfg, err := newConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		t.Errorf("newConfig() saved the credentials %q, %q from the environment", cfg.Username, cfg.Password)
+	}
+	if wd, _ := os.Getwd(); cfg.DownloadsDirectory != wd {
+		t.Errorf("newConfig() downloads directory = %q, expected the working directory", cfg.DownloadsDirectory)
+	}
+
+	t.Setenv("BEATPORT_PASSWORD", "")
+	if _, err := newConfig(false); err == nil {
+		t.Error("newConfig() without a terminal or credentials didn't fail")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unspok3n/beatportdl/internal/taglib"
+)
+
+const isrcIndexFilename = ".beatportdl-isrc.json"
+
+// isrcIndex maps the ISRCs of the tracks in the downloads directory to their
+// files, relative to the directory, so a track downloaded from one chart
+// isn't downloaded again from another. A nil index contains nothing.
+type isrcIndex struct {
+	root  string
+	files map[string]string
+	mutex sync.Mutex
+}
+
+// loadISRCIndex reads the index file of the downloads directory root.
+func loadISRCIndex(root string) (*isrcIndex, error) {
+	index := &isrcIndex{
+		root:  root,
+		files: make(map[string]string),
+	}
+	data, err := os.ReadFile(index.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read isrc index: %w", err)
+	}
+	if err := json.Unmarshal(data, &index.files); err != nil {
+		return nil, fmt.Errorf("decode isrc index: %w", err)
+	}
+	return index, nil
+}
+
+func (i *isrcIndex) path() string {
+	return filepath.Join(i.root, isrcIndexFilename)
+}
+
+// Lookup returns the file of a track with isrc, if it still exists.
+func (i *isrcIndex) Lookup(isrc string) (string, bool) {
+	if i == nil || isrc == "" {
+		return "", false
+	}
+	i.mutex.Lock()
+	file, ok := i.files[strings.ToUpper(isrc)]
+	i.mutex.Unlock()
+	if !ok {
+		return "", false
+	}
+	path := filepath.Join(i.root, file)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Add records the file at path for isrc and writes the index.
+func (i *isrcIndex) Add(isrc, path string) error {
+	if i == nil || isrc == "" {
+		return nil
+	}
+	file, err := filepath.Rel(i.root, path)
+	if err != nil {
+		return fmt.Errorf("update isrc index: %w", err)
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.files[strings.ToUpper(isrc)] = filepath.ToSlash(file)
+	return i.write()
+}
+
+// write replaces the index file through a rename, so it never contains a
+// partially written index.
+func (i *isrcIndex) write() error {
+	data, err := json.MarshalIndent(i.files, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode isrc index: %w", err)
+	}
+	tmpPath := i.path() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("write isrc index: %w", err)
+	}
+	if err := os.Rename(tmpPath, i.path()); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write isrc index: %w", err)
+	}
+	return nil
+}
+
+// reindex replaces the index with the ISRCs read by readISRC from the audio
+// files in the downloads directory, and returns the number of files indexed.
+// Files that can't be read or have no ISRC are left out.
+func (i *isrcIndex) reindex(readISRC func(path string) (string, error)) (int, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(i.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !slices.Contains([]string{".flac", ".m4a"}, filepath.Ext(path)) {
+			return nil
+		}
+		isrc, err := readISRC(path)
+		if err != nil || isrc == "" {
+			return nil
+		}
+		file, err := filepath.Rel(i.root, path)
+		if err != nil {
+			return err
+		}
+		files[strings.ToUpper(isrc)] = filepath.ToSlash(file)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan downloads directory: %w", err)
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.files = files
+	return len(files), i.write()
+}
+
+// readISRCTag reads the ISRC tag of an audio file.
+func readISRCTag(path string) (string, error) {
+	file, err := taglib.Read(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	keys, err := file.PropertyKeys()
+	if err != nil || !slices.Contains(keys, "ISRC") {
+		return "", err
+	}
+	return file.GetProperty("ISRC"), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestISRCIndex(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.flac", "Chart/b.m4a", "cover.jpg"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tags := map[string]string{"a.flac": "gbduw0000059", "b.m4a": "USUG11200001"}
+
+	index := &isrcIndex{root: root}
+	n, err := index.reindex(func(path string) (string, error) {
+		return tags[filepath.Base(path)], nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("reindex() = %d, %v, expected 2 files", n, err)
+	}
+
+	index, err = loadISRCIndex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path, ok := index.Lookup("GBDUW0000059"); !ok || path != filepath.Join(root, "a.flac") {
+		t.Errorf("Lookup() = %q, %v, expected a.flac", path, ok)
+	}
+	if _, ok := index.Lookup("USUG11200002"); ok {
+		t.Error("Lookup() found an unknown ISRC")
+	}
+
+	c := filepath.Join(root, "c.flac")
+	if err := index.Add("NLA320000001", c); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index.Lookup("NLA320000001"); ok {
+		t.Error("Lookup() found an ISRC whose file doesn't exist")
+	}
+	os.WriteFile(c, nil, 0644)
+	if index, _ = loadISRCIndex(root); index.files["NLA320000001"] != "c.flac" {
+		t.Errorf("Added file not written to the index: %v", index.files)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// labelReleaseCache keeps the release lists of the label catalogs of a
+// batch, so the releases listed for the disk space estimate aren't listed
+// again for the download. The zero value is ready to use.
+type labelReleaseCache struct {
+	entries map[string][]beatport.Release
+	mutex   sync.Mutex
+}
+
+func labelReleaseKey(link *beatport.Link) string {
+	return fmt.Sprintf("%s:%d?%s", link.Store, link.ID, link.Params)
+}
+
+func (c *labelReleaseCache) get(link *beatport.Link) ([]beatport.Release, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	releases, ok := c.entries[labelReleaseKey(link)]
+	return releases, ok
+}
+
+func (c *labelReleaseCache) set(link *beatport.Link, releases []beatport.Release) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]beatport.Release)
+	}
+	c.entries[labelReleaseKey(link)] = releases
+}
+
+// clear removes all cached release lists.
+func (c *labelReleaseCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = nil
+}
+
+// releaseLimit returns the maximum number of label releases to download,
+// from --max-releases or else --limit.
+func (app *application) releaseLimit() int {
+	if app.maxReleases > 0 {
+		return app.maxReleases
+	}
+	return app.limit
+}
+
+// labelReleases returns the releases of a label catalog that would be
+// downloaded, after the --since and --until filters and the release limit.
+// The list is fetched once per batch.
+func (app *application) labelReleases(inst *beatport.Beatport, link *beatport.Link) ([]beatport.Release, error) {
+	if releases, ok := app.labelReleaseLists.get(link); ok {
+		return releases, nil
+	}
+	var releases []beatport.Release
+	limit := app.releaseLimit()
+	err := ForPaginated[beatport.Release](app.ctx, link.ID, app.dateRangeParams(link.Params, "new_release_date"), inst.GetLabelReleases, func(release beatport.Release, i int) error {
+		if !app.dateWanted(release.Date) {
+			return nil
+		}
+		if limit > 0 && len(releases) >= limit {
+			return errLimitReached
+		}
+		releases = append(releases, release)
+		return nil
+	})
+	if errors.Is(err, errLimitReached) {
+		err = nil
+	}
+	if err == nil {
+		app.labelReleaseLists.set(link, releases)
+	}
+	return releases, err
+}
+
+// labelTrackCount returns the number of tracks in the releases of a label
+// catalog that would be downloaded.
+func (app *application) labelTrackCount(inst *beatport.Beatport, link *beatport.Link) (int, error) {
+	releases, err := app.labelReleases(inst, link)
+	if err != nil {
+		return 0, err
+	}
+	var tracks int
+	for _, release := range releases {
+		tracks += release.TrackCount
+	}
+	return tracks, nil
+}
+
+// listLabelReleases prints the releases of a label catalog that would be
+// downloaded, for --list-only.
+func (app *application) listLabelReleases(inst *beatport.Beatport, link *beatport.Link, label *beatport.Label, opts urlOptions) {
+	releases, err := app.labelReleases(inst, link)
+	if err != nil {
+		app.failureLogWrapper(link.Original, "list label releases", err, opts)
+		return
+	}
+	app.listReleases(label.Name, releases)
+}
+
+// listReleases prints releases and their total number of tracks.
+func (app *application) listReleases(name string, releases []beatport.Release) {
+	var tracks int
+	for _, release := range releases {
+		tracks += release.TrackCount
+		app.LogInfo(fmt.Sprintf(
+			"%s  [%s] %s - %s (%d tracks) [%s]",
+			release.StoreUrl(), release.CatalogNumber, release.Artists.Display(0, ""), release.Name,
+			release.TrackCount, release.Date,
+		))
+	}
+	app.LogInfo(fmt.Sprintf("%s: %d releases, %d tracks", name, len(releases), tracks))
+}
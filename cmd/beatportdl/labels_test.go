@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestLabelReleasesCached(t *testing.T) {
+	app := &application{ctx: context.Background()}
+	link := &beatport.Link{Type: beatport.LabelLink, ID: 1, Store: beatport.StoreBeatport}
+	cached := []beatport.Release{{ID: 10, TrackCount: 3}, {ID: 11, TrackCount: 2}}
+	app.labelReleaseLists.set(link, cached)
+
+	// The cached list is returned without a request, so no instance is
+	// needed.
+	releases, err := app.labelReleases(nil, link)
+	if err != nil || len(releases) != 2 {
+		t.Fatalf("labelReleases() = %v, %v, expected the cached releases", releases, err)
+	}
+	if count, err := app.labelTrackCount(nil, link); err != nil || count != 5 {
+		t.Errorf("labelTrackCount() = %d, %v, expected 5", count, err)
+	}
+
+	other := &beatport.Link{Type: beatport.LabelLink, ID: 1, Store: beatport.StoreBeatport, Params: "per_page=10"}
+	if _, ok := app.labelReleaseLists.get(other); ok {
+		t.Error("Release list of the label cached for other params")
+	}
+	app.labelReleaseLists.clear()
+	if _, ok := app.labelReleaseLists.get(link); ok {
+		t.Error("Release list still cached after clear()")
+	}
+}
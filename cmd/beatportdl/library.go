@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// libraryURL is the URL queued by the library command and --library, which
+// downloads the tracks purchased with the active account.
+const libraryURL = "https://www.beatport.com/library/downloads"
+
+// handleLibraryLink downloads the purchased tracks that aren't downloaded
+// yet. The tracks of each page start downloading while the next page is
+// listed. Since purchases are listed newest first, purchases after --until
+// are passed over and listing stops at the first purchase before --since.
+func (app *application) handleLibraryLink(inst *beatport.Beatport, link *beatport.Link, opts urlOptions) {
+	// Tracks bought again are listed once for each purchase.
+	seen := make(map[int64]bool)
+	var count int
+	fetchPage := withPageProgress(app, link.Original, inst.GetPurchases)
+	wg := sync.WaitGroup{}
+	err := ForPaginated[beatport.Purchase](app.ctx, 0, link.Params, fetchPage, func(purchase beatport.Purchase, i int) error {
+		if app.since != "" && purchase.PurchaseDate < app.since {
+			return errLimitReached
+		}
+		if seen[purchase.ID] || !app.dateWanted(purchase.PurchaseDate) {
+			return nil
+		}
+		seen[purchase.ID] = true
+		count++
+		track := purchase.Track
+		app.downloadWorker(&wg, func() {
+			app.handleLibraryTrack(inst, &track, opts)
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimitReached) {
+		app.failureLogWrapper(link.Original, "handle library", err, opts)
+	}
+	wg.Wait()
+	app.infoLogWrapper(link.Original, fmt.Sprintf("checked %d purchased tracks", count))
+}
+
+// handleLibraryTrack downloads a track into the directory of its release,
+// and returns the location of the file, or an empty string if the track was
+// skipped or failed.
+func (app *application) handleLibraryTrack(inst *beatport.Beatport, track *beatport.Track, opts urlOptions) string {
+	trackStoreUrl := track.StoreUrl()
+	t, err := inst.GetTrack(app.ctx, track.ID)
+	if err != nil {
+		app.trackFetchFailed(track, "fetch full track", err, opts)
+		return ""
+	}
+
+	release, err := inst.GetRelease(app.ctx, track.Release.ID)
+	if err != nil {
+		app.trackFetchFailed(track, "fetch track release", err, opts)
+		return ""
+	}
+	t.Release = *release
+
+	releaseDir, err := app.setupDownloadsDirectory(opts.downloadsDirectory, release)
+	if err != nil {
+		app.failureLogWrapper(trackStoreUrl, "setup track release downloads directory", err, opts)
+		return ""
+	}
+
+	var cover string
+	if app.requireCover(opts.quality, true, true) {
+		cover, err = app.downloadCover(release.Image, releaseDir)
+		if err != nil {
+			app.errorLogWrapper(trackStoreUrl, "download track release cover", err)
+		}
+	}
+
+	location, err := app.handleTrack(inst, t, releaseDir, cover, opts)
+	if err != nil {
+		app.failureLogWrapper(trackStoreUrl, "handle track", err, opts)
+		os.Remove(cover)
+		app.cleanup(releaseDir)
+		return ""
+	}
+
+	if err := app.handleCoverFile(cover); err != nil {
+		app.errorLogWrapper(trackStoreUrl, "handle cover file", err)
+		return location
+	}
+
+	app.cleanup(releaseDir)
+	return location
+}
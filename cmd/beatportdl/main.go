@@ -7,116 +7,446 @@ import (
 	"github.com/fatih/color"
 	"github.com/vbauerster/mpb/v8"
 	"io"
+	"math"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 	"unspok3n/beatportdl/config"
 	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/ratelimit"
+	"unspok3n/beatportdl/internal/validator"
 )
 
 const (
-	// REQUIRED by utils.go – do not remove
-	configFilename = "beatportdl-config.yml"
-
-	// Cache completely disabled (no json created)
-	cacheFilename = ""
-
-	errorFilename = "beatportdl-err.log"
+	configFilename   = "beatportdl-config.yml"
+	cacheFilename    = "beatportdl-credentials.json"
+	tokenCacheSuffix = ".token.json"
+	errorFilename    = "beatportdl-err.log"
+	failedFilename   = "beatportdl-failed.txt"
 )
 
 type application struct {
 	config      *config.AppConfig
-	logFile     *os.File
+	logFile     *errorLog
 	logWriter   io.Writer
 	ctx         context.Context
 	wg          sync.WaitGroup
-	downloadSem chan struct{}
-	globalSem   chan struct{}
+	downloadSem *semaphore
+	globalSem   *semaphore
+	hookSem     *semaphore
 	pbp         *mpb.Progress
 
+	// batchProgress counts the finished tracks of the batch on a bar
+	// above the others.
+	batchProgress *batchProgress
+
+	// downloadCtx is the context of file transfers. It outlives ctx, so
+	// the downloads in flight can finish after the first interrupt.
+	downloadCtx     context.Context
+	activeDownloads atomic.Int64
+
+	// jsonLogWriter receives the log messages as JSON lines instead of
+	// logWriter with log_format: json.
+	jsonLogWriter io.Writer
+	logLevel      logLevel
+
+	// httpClient is the download client of the active account, and
+	// httpProxy the proxy it was built for.
+	httpClient      *http.Client
+	httpProxy       string
+	httpClientMutex sync.RWMutex
+
 	urls             []string
-	activeFiles      map[string]struct{}
+	urlErrors        map[string]error
+	activeFiles      map[string]*activeFile
 	activeFilesMutex sync.RWMutex
+	forceDownload    bool
+	limit            int
+	maxReleases      int
+	listOnly         bool
+	artistType       string
+	artistReleases   bool
+	since            string
+	until            string
+	clearCart        bool
+	isrcLatest       bool
+	includeOwned     bool
+	previews         bool
+	genres           genreFilter
+	trackFilter      trackFilter
+	dryRun           bool
+	archive          *downloadArchive
+	isrcIndex        *isrcIndex
+	queue            *urlQueue
+	usage            *accountUsage
+	downloadLimiter  *ratelimit.Limiter
+	requestLimiter   *ratelimit.Limiter
+	covers           coverCache
+
+	// labelReleaseLists keeps the label release lists of the batch, so
+	// they're listed once for the disk space estimate and the download.
+	labelReleaseLists labelReleaseCache
+
+	failedUrls      []string
+	failedUrlsMutex sync.Mutex
+	failedFilePath  string
+
+	downloadedCount  atomic.Int64
+	skippedCount     atomic.Int64
+	unavailableCount atomic.Int64
+	genreSkipCount   atomic.Int64
+	filterSkipCount  atomic.Int64
+	ownedSkipCount   atomic.Int64
 
 	bp *beatport.Beatport
 	bs *beatport.Beatport
+
+	accounts      []*account
+	accountIndex  int
+	nextAccount   int
+	accountsMutex sync.Mutex
 }
 
 func main() {
 
+	// === CLI ARGUMENTS ===
+	quitFlag := flag.Bool("q", false, "Quit after finishing")
+	noCacheFlag := flag.Bool("no-cache", false, "Ignore cached tokens and log in with the password")
+	forceFlag := flag.Bool("force", false, "Download tracks even if they already exist")
+	noArchiveFlag := flag.Bool("no-archive", false, "Ignore the download archive for this run")
+	reindexFlag := flag.Bool("reindex", false, "Rebuild the ISRC index from the tags of the files in the downloads directory and exit")
+	dryRunFlag := flag.Bool("dry-run", false, "Print the files that would be downloaded and their estimated sizes without downloading anything")
+	noResumeFlag := flag.Bool("no-resume", false, "Don't offer to resume URLs left over from an interrupted run")
+	limitFlag := flag.Int("limit", 0, "Maximum number of chart and Top 100 tracks or label releases to download (0 for all)")
+	maxReleasesFlag := flag.Int("max-releases", 0, "Maximum number of label releases to download, overriding --limit for labels (0 for --limit)")
+	listOnlyFlag := flag.Bool("list-only", false, "List the releases of labels and the tracks of artists without downloading them")
+	artistTypeFlag := flag.String("artist-type", "all", "Tracks to download from artist catalogs: all, original or remix")
+	artistReleasesFlag := flag.Bool("artist-releases", false, "Download the full releases of artist catalogs instead of only the artist's tracks")
+	sinceFlag := flag.String("since", "", "Only download artist tracks and label releases published, or library tracks purchased, on or after this date (YYYY-MM-DD)")
+	var genres genreFilter
+	flag.Var(&genres, "genre", "Only download label and artist tracks of this genre or sub-genre, by name or ID (repeatable)")
+	untilFlag := flag.String("until", "", "Only download artist tracks and label releases published, or library tracks purchased, on or before this date (YYYY-MM-DD)")
+	bpmFlag := flag.String("bpm", "", "Only download tracks of releases, playlists, charts, labels and artists in this BPM range, like 120-128")
+	keyFlag := flag.String("key", "", "Only download tracks of releases, playlists, charts, labels and artists in these keys, like 8A,9A (Camelot, Open Key or key names)")
+	includeOwnedFlag := flag.Bool("include-owned", false, "Download chart and label tracks that were already purchased with the account")
+	libraryFlag := flag.Bool("library", false, "Download the tracks purchased with the account that aren't downloaded yet")
+	cartFlag := flag.Bool("cart", false, "Download the tracks in the carts of the account")
+	clearCartFlag := flag.Bool("clear-cart", false, "Remove the downloaded tracks from their cart")
+	searchFlag := flag.String("search", "", "Search for a track, like \"artist - title\", and download the best match")
+	searchFileFlag := flag.String("search-file", "", "Text file of URLs and search queries, one per line, downloading the best match of each query")
+	isrcFileFlag := flag.String("isrc-file", "", "Text file with an ISRC on each line to download the tracks of")
+	isrcLatestFlag := flag.Bool("isrc-latest", false, "Download the latest release of tracks released again with the same ISRC, instead of the earliest")
+	var outputDir string
+	flag.StringVar(&outputDir, "o", "", "Download directory for this run, overriding downloads_directory")
+	flag.StringVar(&outputDir, "output", "", "Same as -o")
+	var verbose bool
+	flag.BoolVar(&verbose, "v", false, "Log debug details such as API requests and download URLs, same as log_level: debug")
+	flag.BoolVar(&verbose, "verbose", false, "Same as -v")
+	downloadWorkersFlag := flag.Int("download-workers", 0, "Concurrent download jobs for this run, overriding max_download_workers of every account")
+	globalWorkersFlag := flag.Int("global-workers", 0, "Concurrent global jobs for this run, overriding max_global_workers of every account")
+	previewsFlag := flag.Bool("previews", false, "Download the preview clips of the tracks into the previews subdirectory instead of the full files")
+	noProgressFlag := flag.Bool("no-progress", false, "Print a line for each download instead of progress bars, the default when the output isn't a terminal")
+	proxyFlag := flag.String("proxy", "", "Proxy URL for this run, overriding proxy of every account (http, https or socks5)")
+	accountsFlag := flag.String("accounts", "", "Comma-separated config file names in the config directory to load, like a.yml,b.yml (default all)")
+	excludeFlag := flag.String("exclude", "", "Comma-separated config file names in the config directory not to load")
+	formatFlag := flag.String("format", "", "Download quality for this run: lossless, high, medium, medium-hls (aliases: flac, aac-256, aac-128)")
+	flag.Parse()
+	inputArgs := flag.Args()
+
+	// Deferred first, so it runs after the other deferred cleanups.
+	var exitCode int
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	if len(inputArgs) > 0 && inputArgs[0] == "encrypt-password" {
+		encryptPassword(inputArgs[1:])
+		return
+	}
+
+	// "beatportdl search [--type=TYPE] [query]" searches the store instead
+	// of taking URLs.
+	var searchMode bool
+	var searchQuery, searchType string
+	if len(inputArgs) > 0 && inputArgs[0] == "search" {
+		searchFlags := flag.NewFlagSet("search", flag.ExitOnError)
+		searchFlags.StringVar(&searchType, "type", "", "Only search for one type of results: track, release or artist")
+		searchFlags.Parse(inputArgs[1:])
+		if _, ok := searchTypes[searchType]; searchType != "" && !ok {
+			fmt.Println("invalid search type:", searchType)
+			os.Exit(2)
+		}
+		searchMode = true
+		searchQuery = strings.Join(searchFlags.Args(), " ")
+		inputArgs = nil
+	}
+
+	// "beatportdl check" logs every account in, reports its status and
+	// exits without downloading.
+	checkMode := len(inputArgs) > 0 && inputArgs[0] == "check"
+	if checkMode {
+		inputArgs = nil
+	}
+
+	if !validator.PermittedValue(*artistTypeFlag, supportedArtistTypes...) {
+		fmt.Println("invalid artist type:", *artistTypeFlag)
+		os.Exit(2)
+	}
+	for name, date := range map[string]string{"since": *sinceFlag, "until": *untilFlag} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			fmt.Printf("invalid %s date: %s\n", name, date)
+			os.Exit(2)
+		}
+	}
+	trackFilter, err := parseTrackFilter(*bpmFlag, *keyFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if *sinceFlag != "" && *untilFlag != "" && *untilFlag < *sinceFlag {
+		fmt.Printf("invalid date range: %s is before %s\n", *untilFlag, *sinceFlag)
+		os.Exit(2)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "download-workers", "global-workers":
+			if workers := f.Value.(flag.Getter).Get().(int); workers < 1 {
+				fmt.Printf("invalid %s: %d (must be at least 1)\n", f.Name, workers)
+				os.Exit(2)
+			}
+		}
+	})
+
+	var format string
+	if *formatFlag != "" {
+		quality, err := config.NormalizeQuality(*formatFlag)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(2)
+		}
+		if quality == "medium-hls" && !config.FFMPEGInstalled() {
+			fmt.Println("ffmpeg not found")
+			os.Exit(2)
+		}
+		format = quality
+	}
+
+	if *proxyFlag != "" {
+		if _, err := config.ParseProxyUrl(*proxyFlag); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(2)
+		}
+	}
+
 	// === MULTIPLE ACCOUNT YAML FILES ===
-	configFiles := []string{
-		"/home/ubuntu/.config/beatportdl/config1.yml",
-		"/home/ubuntu/.config/beatportdl/config2.yml",
-		"/home/ubuntu/.config/beatportdl/config3.yml",
+	configFiles, err := FindConfigFiles()
+	if err != nil {
+		fmt.Println(err.Error())
+		Pause()
+	}
+	if *accountsFlag != "" || *excludeFlag != "" {
+		configFiles, err = selectConfigFiles(configFiles, splitList(*accountsFlag), splitList(*excludeFlag))
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(2)
+		}
 	}
 
 	var (
-		cfg          *config.AppConfig
-		auth         *beatport.Auth
-		bp, bs       *beatport.Beatport
-		loginSuccess bool
+		cfg      *config.AppConfig
+		cfgPath  string
+		accounts []*account
 	)
 
-	// === TRY LOGIN WITH EACH CONFIG ===
-	for _, cfgPath := range configFiles {
+	// === LOAD ACCOUNTS ===
+	// The BEATPORT_* variables describe a single account, so with several
+	// config files they would turn every account into the same one.
+	applyEnv := len(configFiles) == 1
+	if !applyEnv && slices.ContainsFunc(config.EnvVariables, func(name string) bool { return os.Getenv(name) != "" }) {
+		fmt.Printf("Ignoring %s with %d account configs\n", strings.Join(config.EnvVariables, ", "), len(configFiles))
+	}
+	var candidates []*account
+	var invalidConfigs int
+	for _, accountCfgPath := range configFiles {
 
-		var err error
-		cfg, err = config.Parse(cfgPath)
+		accountCfg, err := config.Parse(accountCfgPath)
+		if err == nil && applyEnv {
+			err = accountCfg.ApplyEnv()
+		}
 		if err != nil {
-			fmt.Println("Config error:", cfgPath, err)
+			fmt.Println("Config error:", accountCfgPath, err)
+			invalidConfigs++
+			continue
+		}
+		if outputDir != "" {
+			accountCfg.DownloadsDirectory = outputDir
+		}
+		if *previewsFlag {
+			accountCfg.DownloadsDirectory = filepath.Join(accountCfg.DownloadsDirectory, previewsDirectory)
+		}
+		if format != "" {
+			accountCfg.Quality = format
+		}
+		if *downloadWorkersFlag > 0 {
+			accountCfg.MaxDownloadWorkers = *downloadWorkersFlag
+		}
+		if *globalWorkersFlag > 0 {
+			accountCfg.MaxGlobalWorkers = *globalWorkersFlag
+		}
+		if *proxyFlag != "" {
+			accountCfg.Proxy = *proxyFlag
+		}
+		// Progress bars only render on a terminal, redirected output would
+		// be full of escape codes.
+		if *noProgressFlag || !stdoutInteractive() {
+			accountCfg.ShowProgress = false
+		}
+		if err := accountCfg.Validate(); err != nil {
+			fmt.Println("Skipping invalid config:", accountCfgPath)
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Println("  -", line)
+			}
+			invalidConfigs++
 			continue
 		}
 
-		// Empty cache path = NO json file
-		auth = beatport.NewAuth(cfg.Username, cfg.Password, "")
-
-		bp = beatport.New(beatport.StoreBeatport, cfg.Proxy, auth)
-		bs = beatport.New(beatport.StoreBeatsource, cfg.Proxy, auth)
-
-		fmt.Println("Logging in:", cfgPath)
+		var cachePath string
+		if !*noCacheFlag {
+			cachePath = AccountCacheFilePath(accountCfgPath)
+			migrateAccountCacheFile(accountCfgPath, accountCfg.Username)
+		}
+		candidates = append(candidates, newAccount(accountCfgPath, accountCfg, cachePath))
+	}
 
-		// Force live login
-		if err := auth.Init(bp); err != nil {
-			fmt.Println("Login failed:", cfgPath)
-			continue
+	if checkMode {
+		if !checkAccounts(candidates) || invalidConfigs > 0 || len(candidates) == 0 {
+			os.Exit(1)
 		}
+		return
+	}
 
-		fmt.Println("Login successful:", cfgPath)
-		loginSuccess = true
-		break
+	// === LOG IN ===
+	// All accounts log in concurrently. The first one that succeeds, in
+	// config file order, provides the settings; the others are kept to
+	// switch to when it is rejected. With lazy_login in the first config,
+	// every account is kept and logs in the first time it is used.
+	if len(candidates) > 0 && candidates[0].config.LazyLogin {
+		fmt.Printf("Registered %d accounts, logging in on first use\n", len(candidates))
+		accounts = candidates
+	} else {
+		fmt.Printf("Logging in %d accounts\n", len(candidates))
+		loginErrs := loginAccounts(context.Background(), candidates)
+		var failures []string
+		for i, acc := range candidates {
+			if err := loginErrs[i]; err != nil {
+				failures = append(failures, fmt.Sprintf("  - %s: %v", acc.configPath, err))
+				continue
+			}
+			accounts = append(accounts, acc)
+		}
+		if len(accounts) > 0 {
+			fmt.Printf("✅ Logged in %d of %d accounts\n", len(accounts), len(candidates))
+		}
+		if len(failures) > 0 {
+			fmt.Println("❌ Login failed:")
+			fmt.Println(strings.Join(failures, "\n"))
+		}
 	}
 
-	if !loginSuccess {
+	if len(accounts) == 0 {
 		fmt.Println("❌ All accounts failed. Exiting.")
 		os.Exit(1)
 	}
+	cfg, cfgPath = accounts[0].config, accounts[0].configPath
+
+	client, err := accounts[0].downloadClient()
+	if err != nil {
+		fmt.Println("Config error:", cfgPath, err)
+		os.Exit(2)
+	}
+
+	if outputDir != "" {
+		if err := checkWritableDirectory(outputDir); err != nil {
+			fmt.Println("Output directory error:", err)
+			os.Exit(2)
+		}
+	}
 
 	// === CONTEXT ===
-	ctx, cancel := context.WithCancel(context.Background())
+	downloadCtx, abort := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(downloadCtx)
 
 	app := &application{
 		config:      cfg,
-		downloadSem: make(chan struct{}, cfg.MaxDownloadWorkers),
-		globalSem:   make(chan struct{}, cfg.MaxGlobalWorkers),
+		downloadSem: newSemaphore(cfg.MaxDownloadWorkers),
+		globalSem:   newSemaphore(cfg.MaxGlobalWorkers),
+		hookSem:     newSemaphore(cfg.MaxHookWorkers),
 		ctx:         ctx,
+		downloadCtx: downloadCtx,
 		logWriter:   os.Stdout,
-		bp:          bp,
-		bs:          bs,
+		bp:          beatport.New(beatport.StoreBeatport, cfg.Proxy, accounts[0].auth),
+		bs:          beatport.New(beatport.StoreBeatsource, cfg.Proxy, accounts[0].auth),
+		httpClient:  client.client,
+		httpProxy:   client.proxy,
+		accounts:    accounts,
+
+		forceDownload:  *forceFlag,
+		limit:          *limitFlag,
+		maxReleases:    *maxReleasesFlag,
+		listOnly:       *listOnlyFlag,
+		artistType:     *artistTypeFlag,
+		artistReleases: *artistReleasesFlag,
+		since:          *sinceFlag,
+		until:          *untilFlag,
+		clearCart:      *clearCartFlag,
+		isrcLatest:     *isrcLatestFlag,
+		includeOwned:   *includeOwnedFlag,
+		previews:       *previewsFlag,
+		genres:         genres,
+		trackFilter:    trackFilter,
+		dryRun:         *dryRunFlag,
+	}
+
+	app.logLevel = parseLogLevel(cfg.LogLevel)
+	if verbose {
+		app.logLevel = logLevelDebug
+	}
+
+	// JSON logs go to stderr, so they don't interleave with the progress
+	// bars on stdout.
+	if cfg.LogFormat == "json" {
+		app.jsonLogWriter = os.Stderr
 	}
 
 	// === SIGNAL HANDLING ===
+	// The handler logs through app, so it starts once logging is set up.
+	// The first signal stops starting new downloads and lets the ones in
+	// flight finish, the second aborts them and removes their partial files
+	// before the batch ends. A third one exits right away.
 	go func() {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
 		<-sigCh
 		if len(app.urls) > 0 {
-			app.LogInfo("Shutdown signal received, waiting for downloads...")
+			app.LogInfo(fmt.Sprintf("Finishing %d downloads, press Ctrl-C again to abort.", app.activeDownloads.Load()))
 			cancel()
 			<-sigCh
+			app.LogInfo("Aborting downloads...")
+			abort()
+			<-sigCh
+			app.queue.writeRemaining()
+			app.printAccountSummary()
 		}
 		os.Exit(0)
 	}()
@@ -128,7 +458,8 @@ func main() {
 			fmt.Println(err.Error())
 			Pause()
 		}
-		f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		maxSize, _ := config.ParseByteSize(cfg.ErrorLogMaxSize)
+		f, err := openErrorLog(logFilePath, maxSize)
 		if err != nil {
 			panic(err)
 		}
@@ -136,42 +467,272 @@ func main() {
 		defer f.Close()
 	}
 
-	// === CLI ARGUMENTS ===
-	quitFlag := flag.Bool("q", false, "Quit after finishing")
-	flag.Parse()
-	inputArgs := flag.Args()
+	// === FAILED URLS FILE ===
+	if errorLogPath, _, err := FindErrorLogFile(); err == nil {
+		app.failedFilePath = filepath.Join(filepath.Dir(errorLogPath), failedFilename)
+	}
+
+	// === REQUEST RATE LIMITS ===
+	clients := []*beatport.Beatport{app.bp, app.bs}
+	for _, acc := range app.accounts {
+		clients = append(clients, acc.bp, acc.bs)
+	}
+	var apiLimiter *ratelimit.Limiter
+	if cfg.APIRateLimit > 0 {
+		apiLimiter = ratelimit.New(cfg.APIRateLimit, int(math.Ceil(cfg.APIRateLimit)))
+	}
+	for _, inst := range clients {
+		if apiLimiter != nil {
+			inst.SetRateLimiter(apiLimiter)
+		}
+		inst.OnRateLimited(func(delay time.Duration) {
+			app.LogInfo(fmt.Sprintf("Rate limited by the API, retrying in %s", delay))
+		})
+		inst.OnRequest(func(method, url string, status int) {
+			app.LogDebug(fmt.Sprintf("%s %s: %d", method, url, status))
+		})
+	}
+	if cfg.DownloadRateLimit > 0 {
+		app.requestLimiter = ratelimit.New(cfg.DownloadRateLimit, int(math.Ceil(cfg.DownloadRateLimit)))
+	}
+
+	// === BANDWIDTH LIMIT ===
+	if maxSpeed, _ := config.ParseByteSize(cfg.MaxDownloadSpeed); maxSpeed > 0 {
+		app.downloadLimiter = ratelimit.New(float64(maxSpeed), int(maxSpeed))
+	}
+
+	// === DOWNLOAD ARCHIVE ===
+	if cfg.DownloadArchive != "" && !*noArchiveFlag {
+		archive, err := openDownloadArchive(cfg.DownloadArchive)
+		if err != nil {
+			fmt.Println(err.Error())
+			Pause()
+		}
+		app.archive = archive
+		defer archive.Close()
+	}
 
+	// === ISRC INDEX ===
+	if *reindexFlag {
+		index := &isrcIndex{root: cfg.DownloadsDirectory}
+		n, err := index.reindex(readISRCTag)
+		if err != nil {
+			fmt.Println("Reindex failed:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Indexed %d tracks in %s\n", n, index.path())
+		return
+	}
+	if cfg.ISRCIndex {
+		index, err := loadISRCIndex(cfg.DownloadsDirectory)
+		if err != nil {
+			fmt.Println(err.Error())
+			Pause()
+		}
+		app.isrcIndex = index
+	}
+
+	// === URL QUEUE ===
+	queue, err := loadURLQueue(filepath.Join(filepath.Dir(cfgPath), queueFilename))
+	if err != nil {
+		app.LogError("load url queue", err)
+	}
+	app.queue = queue
+	if pending := queue.Pending(); len(pending) > 0 && !*noResumeFlag && !*dryRunFlag && stdinInteractive() {
+		fmt.Printf("Resume %d unfinished URLs from the previous run? [Y/n]: ", len(pending))
+		if answer := strings.TrimSpace(GetLine()); answer == "" || strings.EqualFold(answer, "y") {
+			app.urls = append(app.urls, pending...)
+		} else if err := queue.Set(nil); err != nil {
+			app.LogError("clear url queue", err)
+		}
+	}
+
+	// === ACCOUNT USAGE ===
+	usage, err := loadAccountUsage(filepath.Join(filepath.Dir(cfgPath), usageFilename))
+	if err != nil {
+		app.LogError("load account usage", err)
+	}
+	app.usage = usage
+
+	// === INPUT URLS ===
+	if *libraryFlag {
+		app.urls = append(app.urls, libraryURL)
+	}
+	if *cartFlag {
+		app.urls = append(app.urls, cartURL)
+	}
+	if *isrcFileFlag != "" {
+		app.parseISRCFile(*isrcFileFlag)
+	}
+	quit := *quitFlag
 	for _, arg := range inputArgs {
-		if strings.HasSuffix(arg, ".txt") {
+		switch {
+		case arg == "-":
+			// Stdin is used up, so there's nothing left to prompt for.
+			app.parseStdin()
+			quit = true
+		case strings.HasSuffix(arg, ".txt"):
 			app.parseTextFile(arg)
-		} else {
+		default:
 			app.urls = append(app.urls, arg)
 		}
 	}
 
+	var searchQueries []string
+	if *searchFlag != "" {
+		searchQueries = append(searchQueries, *searchFlag)
+	}
+	if *searchFileFlag != "" {
+		searchQueries = append(searchQueries, app.parseSearchFile(*searchFileFlag)...)
+	}
+	if len(searchQueries) > 0 {
+		// Scripts can tell from the exit code that a query had no match.
+		if unmatched := app.matchSearchQueries(searchQueries); unmatched > 0 {
+			exitCode = 1
+			if len(app.urls) == 0 {
+				return
+			}
+		}
+	}
+
+	if searchMode {
+		for searchQuery == "" {
+			fmt.Print("Enter search query: ")
+			searchQuery = strings.TrimSpace(GetLine())
+		}
+		app.search(searchQuery, searchType)
+	}
+
 	// === MAIN LOOP ===
 	for {
 		if len(app.urls) == 0 {
 			app.mainPrompt()
 		}
 
-		app.pbp = mpb.New(mpb.WithAutoRefresh(), mpb.WithOutput(color.Output))
-		app.logWriter = app.pbp
-		app.activeFiles = make(map[string]struct{}, len(app.urls))
+		if err := app.loginActiveAccount(); err != nil {
+			app.LogError("log in", err)
+			if quit {
+				break
+			}
+			app.urls = []string{}
+			continue
+		}
+
+		app.normalizeUrls()
+		app.labelReleaseLists.clear()
+		if !quit && !app.listOnly {
+			app.confirmArtistDownloads()
+		}
+
+		if !app.listOnly && !app.dryRun && !app.previews && !app.checkDiskSpace() {
+			if quit {
+				break
+			}
+			app.urls = []string{}
+			continue
+		}
+
+		app.pbp, app.logWriter = nil, os.Stdout
+		if app.config.ShowProgress {
+			app.pbp = mpb.New(mpb.WithAutoRefresh(), mpb.WithOutput(color.Output))
+			app.logWriter = app.pbp
+		}
+		app.batchProgress = app.newBatchProgress()
+		app.activeFiles = make(map[string]*activeFile, len(app.urls))
+		app.downloadedCount.Store(0)
+		app.skippedCount.Store(0)
+		app.unavailableCount.Store(0)
+		app.genreSkipCount.Store(0)
+		app.filterSkipCount.Store(0)
+		app.ownedSkipCount.Store(0)
+		app.failedUrls = nil
+		batchStart := time.Now()
+
+		// A dry run is never resumed.
+		if app.dryRun {
+			app.queue = nil
+		}
+		if err := app.queue.Set(app.urls); err != nil {
+			app.LogError("save url queue", err)
+		}
 
-		for _, url := range app.urls {
+		for _, entry := range app.urls {
 			app.globalWorker(func() {
-				app.handleUrl(url)
+				app.handleUrl(entry)
+				if app.ctx.Err() != nil {
+					return
+				}
+				if err := app.queue.Done(entry); err != nil {
+					app.LogError("update url queue", err)
+				}
 			})
 		}
 
 		app.wg.Wait()
-		app.pbp.Shutdown()
+		app.batchProgress.finish(ctx.Err() != nil)
+		if app.pbp != nil {
+			app.pbp.Shutdown()
+		}
+		app.covers.clear()
+		app.printSummary()
+		app.notifyWebhook(app.batchReport(time.Since(batchStart), ctx.Err() != nil))
+
+		if n, err := app.writeFailedUrls(); err != nil {
+			app.LogError("failed urls", err)
+		} else if n > 0 {
+			fmt.Printf("%d failures written to %s\n", n, failedFilename)
+		}
 
-		if *quitFlag || ctx.Err() != nil {
+		if ctx.Err() != nil {
+			if path, err := app.queue.writeRemaining(); err != nil {
+				app.LogError("shutdown", err)
+			} else if path != "" {
+				fmt.Println("Unfinished URLs written to", path)
+			}
+			break
+		}
+
+		if quit {
 			break
 		}
 
 		app.urls = []string{}
 	}
 }
+
+func (app *application) printSummary() {
+	summary := app.summary()
+	if summary == "" {
+		return
+	}
+	fmt.Println(summary)
+	app.printAccountSummary()
+}
+
+// summary describes the tracks of the batch, or returns an empty string if
+// there were none.
+func (app *application) summary() string {
+	downloaded, skipped := app.downloadedCount.Load(), app.skippedCount.Load()
+	unavailable, genreSkipped := app.unavailableCount.Load(), app.genreSkipCount.Load()
+	filterSkipped, ownedSkipped := app.filterSkipCount.Load(), app.ownedSkipCount.Load()
+	if downloaded+skipped+unavailable+genreSkipped+filterSkipped+ownedSkipped == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("Downloaded %d tracks, skipped %d existing", downloaded, skipped)
+	if app.dryRun {
+		summary = fmt.Sprintf("Would download %d tracks, skipped %d existing", downloaded, skipped)
+	}
+	if unavailable > 0 {
+		summary += fmt.Sprintf(", %d unavailable", unavailable)
+	}
+	if genreSkipped > 0 {
+		summary += fmt.Sprintf(", skipped %d tracks outside genre filter", genreSkipped)
+	}
+	if filterSkipped > 0 {
+		summary += fmt.Sprintf(", skipped %d tracks outside bpm/key filter", filterSkipped)
+	}
+	if ownedSkipped > 0 {
+		summary += fmt.Sprintf(", skipped %d purchased tracks", ownedSkipped)
+	}
+	return summary
+}
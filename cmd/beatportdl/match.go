@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// matchSearchQueries searches the tracks for each query and queues the best
+// match, if it's at least as similar as search_min_confidence. The chosen
+// matches are printed so they can be checked afterwards. It returns the
+// number of queries without a confident match.
+func (app *application) matchSearchQueries(queries []string) int {
+	if err := app.loginActiveAccount(); err != nil {
+		app.FatalError("log in", err)
+	}
+
+	var unmatched int
+	for _, query := range queries {
+		storeTag, query := extractStoreTag(query)
+		inst := app.bp
+		if storeTag == "beatsource" {
+			inst = app.bs
+		}
+		results, err := inst.Search(app.ctx, query, beatport.SearchParams{
+			Type:    searchTypes["track"],
+			PerPage: searchPageSize,
+		})
+		if err != nil {
+			app.LogError(fmt.Sprintf("search %q", query), err)
+			unmatched++
+			continue
+		}
+
+		track, score := bestMatch(query, results.Tracks)
+		if track == nil || score < app.config.SearchMinConfidence {
+			fmt.Printf("No confident match for %q", query)
+			if track != nil {
+				fmt.Printf(" (best: %s, score %.2f)", matchDisplay(track), score)
+			}
+			fmt.Println()
+			unmatched++
+			continue
+		}
+		fmt.Printf("Matched %q: %s, score %.2f, %s\n", query, matchDisplay(track), score, track.StoreUrl())
+		app.urls = append(app.urls, track.StoreUrl())
+	}
+	return unmatched
+}
+
+// parseSearchFile reads a text file of URLs and search queries, one per
+// line, queues the URLs and returns the queries.
+func (app *application) parseSearchFile(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		app.FatalError("read search file", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanLines)
+
+	var queries []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "https://") || beatport.IsIDLink(line):
+			app.urls = append(app.urls, line)
+		default:
+			queries = append(queries, line)
+		}
+	}
+	return queries
+}
+
+func matchDisplay(track *beatport.Track) string {
+	return fmt.Sprintf("%s - %s (%s)", track.Artists.Display(0, ""), track.Name.String(), track.MixName.String())
+}
+
+// bestMatch returns the track most similar to query and its score.
+func bestMatch(query string, tracks []beatport.Track) (*beatport.Track, float64) {
+	var best *beatport.Track
+	var bestScore float64
+	for i := range tracks {
+		if score := matchScore(query, &tracks[i]); best == nil || score > bestScore {
+			best, bestScore = &tracks[i], score
+		}
+	}
+	return best, bestScore
+}
+
+// matchScore rates from 0 to 1 how similar a track is to query. A query in
+// the form "artists - title" compares the artists and the title separately,
+// the title either with or without the mix name. Any other query is compared
+// against the artists and title together.
+func matchScore(query string, track *beatport.Track) float64 {
+	artists := track.Artists.Display(0, "")
+	title := track.Name.String()
+	mixTitle := fmt.Sprintf("%s (%s)", title, track.MixName.String())
+
+	queryArtists, queryTitle, found := strings.Cut(query, " - ")
+	if !found {
+		return max(
+			similarity(query, artists+" "+title),
+			similarity(query, artists+" "+mixTitle),
+		)
+	}
+	titleScore := max(similarity(queryTitle, title), similarity(queryTitle, mixTitle))
+	return (similarity(queryArtists, artists) + titleScore) / 2
+}
+
+// similarity returns the Sørensen-Dice coefficient of the letter pairs of a
+// and b, ignoring case, punctuation and the order of words.
+func similarity(a, b string) float64 {
+	pairsA, pairsB := letterPairs(a), letterPairs(b)
+	if len(pairsA)+len(pairsB) == 0 {
+		return 0
+	}
+	var shared int
+	for pair, count := range pairsA {
+		shared += min(count, pairsB[pair])
+	}
+	var total int
+	for _, count := range pairsA {
+		total += count
+	}
+	for _, count := range pairsB {
+		total += count
+	}
+	return 2 * float64(shared) / float64(total)
+}
+
+func letterPairs(s string) map[string]int {
+	pairs := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		runes := []rune(word)
+		if len(runes) == 1 {
+			pairs[word]++
+		}
+		for i := 0; i < len(runes)-1; i++ {
+			pairs[string(runes[i:i+2])]++
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestBestMatch(t *testing.T) {
+	track := func(artist, name, mix string) beatport.Track {
+		return beatport.Track{
+			Artists: beatport.Artists{{Name: artist}},
+			Name:    beatport.SanitizedString(name),
+			MixName: beatport.SanitizedString(mix),
+		}
+	}
+	tracks := []beatport.Track{
+		track("deadmau5", "Strobe", "Radio Edit"),
+		track("deadmau5", "Strobe", "Original Mix"),
+		track("Strobe", "Deadmau5 Tribute", "Original Mix"),
+	}
+
+	tests := []struct {
+		query string
+		mix   string
+	}{
+		{"deadmau5 - Strobe (Original Mix)", "Original Mix"},
+		{"Deadmau5 - strobe radio edit", "Radio Edit"},
+		{"deadmau5 strobe", "Radio Edit"},
+	}
+	for _, tt := range tests {
+		match, score := bestMatch(tt.query, tracks)
+		if match.Name.String() != "Strobe" || match.MixName.String() != tt.mix {
+			t.Errorf("bestMatch(%q) = %s", tt.query, matchDisplay(match))
+		}
+		if score < 0.8 {
+			t.Errorf("bestMatch(%q) score %.2f, expected at least 0.8", tt.query, score)
+		}
+	}
+
+	if _, score := bestMatch("Bicep - Glue", tracks); score >= 0.5 {
+		t.Errorf("Unrelated track scored %.2f", score)
+	}
+}
@@ -25,8 +25,8 @@ type StreamKey struct {
 	IV    []byte
 }
 
-func getStreamSegments(stream string) (*[]string, *StreamKey, error) {
-	resp, err := http.Get(stream)
+func (app *application) getStreamSegments(client proxiedClient, stream string) (*[]string, *StreamKey, error) {
+	resp, err := app.requestFile(app.downloadCtx, client, stream, 0)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -51,7 +51,7 @@ func getStreamSegments(stream string) (*[]string, *StreamKey, error) {
 			break
 		}
 		if i == 0 {
-			req, err := http.Get(base + segment.Key.URI)
+			req, err := app.requestFile(app.downloadCtx, client, base+segment.Key.URI, 0)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -88,13 +88,19 @@ func decryptSegment(segment []byte, key StreamKey) ([]byte, error) {
 	return decrypted[:len(decrypted)-int(padding)], nil
 }
 
-func (app *application) downloadSegments(path string, segmentUrls []string, key StreamKey, pbPrefix string) (string, error) {
+func (app *application) downloadSegments(client proxiedClient, path string, segmentUrls []string, key StreamKey, pbPrefix string) (_ string, err error) {
 	tempFileName := uuid.New().String()
 	path = filepath.Join(path, tempFileName)
 	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0755)
 	if err != nil {
 		return "", err
 	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(path)
+		}
+	}()
 
 	var bar *mpb.Bar
 	var start time.Time
@@ -105,7 +111,7 @@ func (app *application) downloadSegments(path string, segmentUrls []string, key
 	}
 
 	for _, segmentUrl := range segmentUrls {
-		req, err := http.Get(segmentUrl)
+		req, err := app.requestFile(app.downloadCtx, client, segmentUrl, 0)
 		if err != nil {
 			return "", err
 		}
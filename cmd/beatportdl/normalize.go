@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// shortLinkHosts are the hosts of the short links in newsletters and shared
+// from the mobile app, which redirect to the store.
+var shortLinkHosts = []string{"bp.lnk.to", "btprt.dj"}
+
+// trackingParams are query parameters added by newsletters and social
+// networks, on top of the utm_ ones.
+var trackingParams = []string{"fbclid", "gclid", "igshid", "mc_cid", "mc_eid", "_ga"}
+
+// normalizeUrl cleans up a URL pasted from an email or an app before it is
+// parsed: short links are followed to the store, tracking parameters are
+// removed and the host is lowercased. A fragment is kept only if it's a
+// position selection like #1-20.
+func (app *application) normalizeUrl(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if base, _, _ := strings.Cut(rawURL, "#"); beatport.IsIDLink(base) {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(shortLinkHosts, strings.ToLower(u.Hostname())) {
+		fragment := u.Fragment
+		if u, err = app.resolveShortLink(u); err != nil {
+			return "", fmt.Errorf("resolve short link: %w", err)
+		}
+		u.Fragment = fragment
+	}
+	return cleanUrl(u), nil
+}
+
+// normalizeUrls normalizes the queued URLs once, before they're looked at
+// to confirm artist downloads, estimate their size and download them. The
+// options after a URL are kept. A URL that can't be normalized is left as
+// it is, and its error is kept in urlErrors for handleUrl to report.
+func (app *application) normalizeUrls() {
+	app.urlErrors = make(map[string]error)
+	for i, entry := range app.urls {
+		url, options := splitUrlEntry(entry)
+		normalized, err := app.normalizeUrl(url)
+		if err != nil {
+			app.urlErrors[url] = err
+			continue
+		}
+		app.urls[i] = strings.Join(append([]string{normalized}, options...), " ")
+	}
+}
+
+// cleanUrl lowercases the host of u and drops its tracking parameters and
+// any fragment that isn't a position selection.
+func cleanUrl(u *url.URL) string {
+	u.Host = strings.ToLower(u.Host)
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") || slices.Contains(trackingParams, strings.ToLower(key)) {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	if _, positions, err := splitPositions("#" + u.Fragment); positions == nil || err != nil {
+		u.Fragment, u.RawFragment = "", ""
+	}
+	return u.String()
+}
+
+// resolveShortLink follows the redirect of a short link, once.
+func (app *application) resolveShortLink(u *url.URL) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(app.ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := *app.downloadClient().client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		return nil, fmt.Errorf("no redirect (%s)", resp.Status)
+	}
+	return location, nil
+}
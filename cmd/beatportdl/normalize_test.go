@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"unspok3n/beatportdl/config"
+)
+
+func TestNormalizeUrl(t *testing.T) {
+	app := &application{ctx: context.Background(), httpClient: http.DefaultClient}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"https://WWW.Beatport.com/track/strobe/1696999?utm_source=newsletter&utm_medium=email",
+			"https://www.beatport.com/track/strobe/1696999",
+		},
+		{
+			"https://www.beatport.com/top-100?per_page=50&fbclid=abc",
+			"https://www.beatport.com/top-100?per_page=50",
+		},
+		{"https://www.beatport.com/chart/a/1#1-20", "https://www.beatport.com/chart/a/1#1-20"},
+		{"https://www.beatport.com/chart/a/1#tracks", "https://www.beatport.com/chart/a/1"},
+		{"  chart:998877#5,9  ", "chart:998877#5,9"},
+	}
+	for _, tt := range tests {
+		normalized, err := app.normalizeUrl(tt.input)
+		if err != nil || normalized != tt.expected {
+			t.Errorf("normalizeUrl(%q) = %q, %v, expected %q", tt.input, normalized, err, tt.expected)
+		}
+	}
+}
+
+func TestResolveShortLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://www.beatport.com/release/a/1?utm_campaign=x", http.StatusFound)
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:     &config.AppConfig{},
+		ctx:        context.Background(),
+		httpClient: http.DefaultClient,
+	}
+	u, _ := url.Parse(server.URL + "/abc")
+	location, err := app.resolveShortLink(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if normalized := cleanUrl(location); normalized != "https://www.beatport.com/release/a/1" {
+		t.Errorf("Short link resolved to %q", normalized)
+	}
+}
+
+func TestNormalizeUrls(t *testing.T) {
+	app := &application{
+		ctx: context.Background(),
+		httpClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})},
+		urls: []string{
+			"https://WWW.Beatport.com/artist/a/1?utm_source=x quality=high",
+			"https://bp.lnk.to/abc",
+		},
+	}
+	app.normalizeUrls()
+	if app.urls[0] != "https://www.beatport.com/artist/a/1 quality=high" {
+		t.Errorf("URL normalized to %q, expected the options to be kept", app.urls[0])
+	}
+	if app.urls[1] != "https://bp.lnk.to/abc" || app.urlErrors["https://bp.lnk.to/abc"] == nil {
+		t.Errorf("Unresolved short link queued as %q with error %v", app.urls[1], app.urlErrors[app.urls[1]])
+	}
+}
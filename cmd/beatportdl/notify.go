@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// notifyTimeout bounds the webhook request, so an unreachable endpoint
+// doesn't hold up the next batch or the exit.
+const notifyTimeout = 10 * time.Second
+
+// batchReport is the JSON body posted to notify_webhook when a batch ends.
+// Text and Content repeat the summary line for Slack and Discord webhooks,
+// which show those fields as the message.
+type batchReport struct {
+	Text            string          `json:"text"`
+	Content         string          `json:"content"`
+	Interrupted     bool            `json:"interrupted"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	Downloaded      int64           `json:"downloaded"`
+	Skipped         int64           `json:"skipped"`
+	Unavailable     int64           `json:"unavailable"`
+	Filtered        int64           `json:"filtered"`
+	Failed          int             `json:"failed"`
+	Accounts        []accountReport `json:"accounts"`
+}
+
+type accountReport struct {
+	Username   string `json:"username"`
+	Downloaded int64  `json:"downloaded"`
+	Skipped    int64  `json:"skipped"`
+	Failed     int64  `json:"failed"`
+	Bytes      int64  `json:"bytes"`
+	ThisMonth  int64  `json:"this_month"`
+	Exhausted  bool   `json:"exhausted"`
+}
+
+// batchReport collects the counts of the batch that just ended, and the
+// usage of each account during this run.
+func (app *application) batchReport(duration time.Duration, interrupted bool) batchReport {
+	app.failedUrlsMutex.Lock()
+	failed := len(app.failedUrls)
+	app.failedUrlsMutex.Unlock()
+
+	summary := app.summary()
+	if summary == "" {
+		summary = "No tracks downloaded"
+	}
+	status := "finished"
+	if interrupted {
+		status = "interrupted"
+	}
+	text := fmt.Sprintf("BeatportDL batch %s in %s: %s", status, duration.Round(time.Second), summary)
+	if failed > 0 {
+		text += fmt.Sprintf(", %d failed", failed)
+	}
+
+	report := batchReport{
+		Text:            text,
+		Content:         text,
+		Interrupted:     interrupted,
+		DurationSeconds: duration.Seconds(),
+		Downloaded:      app.downloadedCount.Load(),
+		Skipped:         app.skippedCount.Load(),
+		Unavailable:     app.unavailableCount.Load(),
+		Filtered:        app.genreSkipCount.Load() + app.filterSkipCount.Load() + app.ownedSkipCount.Load(),
+		Failed:          failed,
+	}
+	for _, acc := range app.accounts {
+		report.Accounts = append(report.Accounts, accountReport{
+			Username:   acc.username,
+			Downloaded: acc.downloaded.Load(),
+			Skipped:    acc.skipped.Load(),
+			Failed:     acc.failed.Load(),
+			Bytes:      acc.bytes.Load(),
+			ThisMonth:  app.usage.Month(acc.username),
+			Exhausted:  acc.exhausted,
+		})
+	}
+	return report
+}
+
+// notifyWebhook posts report to notify_webhook through the download client,
+// so it goes through the proxy. Failures are only logged.
+func (app *application) notifyWebhook(report batchReport) {
+	if app.config.NotifyWebhook == "" || app.dryRun {
+		return
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		app.LogError("notify webhook", err)
+		return
+	}
+
+	// The batch context may already be canceled after an interrupt.
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", app.config.NotifyWebhook, bytes.NewReader(body))
+	if err != nil {
+		app.LogError("notify webhook", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.downloadClient().client.Do(req)
+	if err != nil {
+		app.LogError("notify webhook", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		app.LogError("notify webhook", fmt.Errorf("unexpected response: %s", resp.Status))
+	}
+}
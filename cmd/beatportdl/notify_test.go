@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unspok3n/beatportdl/config"
+)
+
+func TestNotifyWebhook(t *testing.T) {
+	reports := make(chan batchReport, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report batchReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Error(err)
+		}
+		reports <- report
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:     &config.AppConfig{NotifyWebhook: server.URL},
+		logWriter:  io.Discard,
+		httpClient: http.DefaultClient,
+		accounts:   []*account{{username: "user"}},
+		failedUrls: []string{"https://www.beatport.com/track/x/1"},
+	}
+	app.downloadedCount.Store(3)
+	app.accounts[0].downloaded.Store(3)
+
+	app.notifyWebhook(app.batchReport(90*time.Second, true))
+	report := <-reports
+	if report.Downloaded != 3 || report.Failed != 1 || !report.Interrupted || report.DurationSeconds != 90 {
+		t.Errorf("Report = %+v", report)
+	}
+	if len(report.Accounts) != 1 || report.Accounts[0].Username != "user" || report.Accounts[0].Downloaded != 3 {
+		t.Errorf("Account reports = %+v", report.Accounts)
+	}
+	if !strings.HasPrefix(report.Content, "BeatportDL batch interrupted in 1m30s: Downloaded 3 tracks") {
+		t.Errorf("Report text = %q", report.Content)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// ownedTracks is the set of tracks purchased with an account in one store,
+// listed the first time it's needed and kept for the rest of the run.
+type ownedTracks struct {
+	once sync.Once
+	ids  map[int64]bool
+}
+
+// ownedTracks returns the owned track set of the account for store.
+func (acc *account) ownedTracks(store beatport.Store) *ownedTracks {
+	acc.ownedMutex.Lock()
+	defer acc.ownedMutex.Unlock()
+	if acc.owned == nil {
+		acc.owned = make(map[beatport.Store]*ownedTracks)
+	}
+	if acc.owned[store] == nil {
+		acc.owned[store] = &ownedTracks{}
+	}
+	return acc.owned[store]
+}
+
+// ownedWanted skips the tracks of charts and label or artist releases that
+// were purchased with the active account, which can be downloaded from the
+// library without using the download quota, unless --include-owned is set.
+// If the purchases can't be listed, no track is skipped.
+func (app *application) ownedWanted(inst *beatport.Beatport, track *beatport.Track) bool {
+	if app.includeOwned {
+		return true
+	}
+	owned := app.currentAccount().ownedTracks(inst.Store())
+	owned.once.Do(func() {
+		ids := make(map[int64]bool)
+		err := ForPaginated[beatport.Purchase](app.ctx, 0, "", inst.GetPurchases, func(purchase beatport.Purchase, i int) error {
+			ids[purchase.ID] = true
+			return nil
+		})
+		if err != nil {
+			app.LogError("list purchased tracks", err)
+			return
+		}
+		owned.ids = ids
+		app.LogDebug(fmt.Sprintf("Listed %d purchased tracks", len(ids)))
+	})
+	if !owned.ids[track.ID] {
+		return true
+	}
+	app.infoLogWrapper(track.StoreUrl(), "skipping, already purchased (download it with the library command or pass --include-owned)")
+	app.ownedSkipCount.Add(1)
+	return false
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestOwnedWanted(t *testing.T) {
+	acc := newAccount("first.yml", &config.AppConfig{}, "")
+	app := &application{
+		config:    &config.AppConfig{},
+		ctx:       context.Background(),
+		logWriter: io.Discard,
+		accounts:  []*account{acc},
+		bp:        beatport.New(beatport.StoreBeatport, "", acc.auth),
+		bs:        beatport.New(beatport.StoreBeatsource, "", acc.auth),
+	}
+	// Mark the purchases of both stores as listed.
+	acc.ownedTracks(beatport.StoreBeatport).once.Do(func() {
+		acc.ownedTracks(beatport.StoreBeatport).ids = map[int64]bool{1: true}
+	})
+	acc.ownedTracks(beatport.StoreBeatsource).once.Do(func() {})
+
+	owned := &beatport.Track{ID: 1}
+	if app.ownedWanted(app.bp, owned) {
+		t.Error("ownedWanted() accepted a purchased track")
+	}
+	if !app.ownedWanted(app.bp, &beatport.Track{ID: 2}) {
+		t.Error("ownedWanted() skipped a track that wasn't purchased")
+	}
+	if !app.ownedWanted(app.bs, owned) {
+		t.Error("ownedWanted() skipped a track purchased in the other store")
+	}
+	if app.ownedSkipCount.Load() != 1 {
+		t.Errorf("ownedSkipCount = %d, expected 1", app.ownedSkipCount.Load())
+	}
+
+	app.includeOwned = true
+	if !app.ownedWanted(app.bp, owned) {
+		t.Error("ownedWanted() skipped a purchased track with --include-owned")
+	}
+}
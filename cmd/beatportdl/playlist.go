@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+type playlistEntry struct {
+	position int
+	path     string
+	track    *beatport.Track
+}
+
+// playlistWriter collects the downloaded tracks of a playlist or chart as
+// the workers finish them, and writes them in their original order. A nil
+// playlistWriter ignores everything, for when write_playlist is off.
+type playlistWriter struct {
+	artistsLimit     int
+	artistsShortForm string
+	whitespace       string
+	entries          []playlistEntry
+	mutex            sync.Mutex
+}
+
+func (app *application) newPlaylistWriter() *playlistWriter {
+	if !app.config.WritePlaylist || app.dryRun {
+		return nil
+	}
+	return &playlistWriter{
+		artistsLimit:     app.config.ArtistsLimit,
+		artistsShortForm: app.config.ArtistsShortForm,
+		whitespace:       app.config.WhitespaceCharacter,
+	}
+}
+
+// add records the file of the track at position. Tracks that weren't
+// downloaded have an empty path and are left out.
+func (w *playlistWriter) add(position int, path string, track *beatport.Track) {
+	if w == nil || path == "" {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.entries = append(w.entries, playlistEntry{
+		position: position,
+		path:     path,
+		track:    track,
+	})
+}
+
+// write saves the tracks as <name>.m3u8 in directory, with paths relative to
+// it.
+func (w *playlistWriter) write(directory string, name string) (string, error) {
+	if w == nil {
+		return "", nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.entries) == 0 {
+		return "", nil
+	}
+	slices.SortFunc(w.entries, func(a, b playlistEntry) int {
+		return a.position - b.position
+	})
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, entry := range w.entries {
+		path, err := filepath.Rel(directory, entry.path)
+		if err != nil {
+			path = entry.path
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n%s\n", int(entry.track.LengthMs)/1000, w.title(entry.track), filepath.ToSlash(path))
+	}
+
+	location := filepath.Join(directory, beatport.SanitizePath(beatport.SanitizeForPath(name), w.whitespace)+".m3u8")
+	if err := os.WriteFile(location, []byte(b.String()), 0644); err != nil {
+		return "", err
+	}
+	return location, nil
+}
+
+func (w *playlistWriter) title(track *beatport.Track) string {
+	title := track.Name.String()
+	if mix := track.MixName.String(); mix != "" {
+		title += " (" + mix + ")"
+	}
+	if artists := track.Artists.Display(w.artistsLimit, w.artistsShortForm); artists != "" {
+		title = artists + " - " + title
+	}
+	return strings.ReplaceAll(title, "\n", " ")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestPlaylistWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := &playlistWriter{}
+	w.add(1, filepath.Join(dir, "Release", "02 Second.flac"), &beatport.Track{
+		Name:     "Second",
+		MixName:  "Extended Mix",
+		LengthMs: 245_500,
+		Artists:  beatport.Artists{{Name: "B"}, {Name: "C"}},
+	})
+	w.add(2, "", &beatport.Track{Name: "Skipped"})
+	w.add(0, filepath.Join(dir, "01 First.flac"), &beatport.Track{
+		Name:     "First",
+		LengthMs: 180_000,
+		Artists:  beatport.Artists{{Name: "A"}},
+	})
+
+	location, err := w.write(dir, "Best of: 2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "Best of 2024.m3u8"); location != want {
+		t.Errorf("location = %q, want %q", location, want)
+	}
+	data, err := os.ReadFile(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#EXTM3U\n" +
+		"#EXTINF:180,A - First\n01 First.flac\n" +
+		"#EXTINF:245,B, C - Second (Extended Mix)\nRelease/02 Second.flac\n"
+	if string(data) != want {
+		t.Errorf("playlist =\n%s\nwant\n%s", data, want)
+	}
+
+	var disabled *playlistWriter
+	disabled.add(0, filepath.Join(dir, "01 First.flac"), &beatport.Track{})
+	if location, err := disabled.write(dir, "Disabled"); location != "" || err != nil {
+		t.Errorf("nil writer wrote %q, %v", location, err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxPosition is the highest position a selection may name, well above the
+// length of any chart or playlist, so a typo like #1-999999999 doesn't fill
+// memory with positions.
+const maxPosition = 10000
+
+// splitPositions splits a position selection suffix like #1-20 or
+// #5,9,12-15 off a URL. The selection is nil if the URL has no suffix, or
+// a fragment that isn't a position selection, like #tracks, which is
+// dropped.
+func splitPositions(rawURL string) (string, map[int]bool, error) {
+	base, suffix, found := strings.Cut(rawURL, "#")
+	if !found {
+		return rawURL, nil, nil
+	}
+	if strings.Trim(suffix, "0123456789,- ") != "" || strings.Trim(suffix, ",- ") == "" {
+		return base, nil, nil
+	}
+	positions := make(map[int]bool)
+	for _, field := range strings.Split(suffix, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(field), "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 1 || start > maxPosition {
+			return "", nil, fmt.Errorf("invalid position: %s", field)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return "", nil, fmt.Errorf("invalid position range: %s", field)
+			}
+			if end > maxPosition {
+				return "", nil, fmt.Errorf("invalid position range: %s, positions go up to %d", field, maxPosition)
+			}
+		}
+		for n := start; n <= end; n++ {
+			positions[n] = true
+		}
+	}
+	return base, positions, nil
+}
+
+// lastPosition returns the last selected position.
+func lastPosition(positions map[int]bool) int {
+	var last int
+	for position := range positions {
+		last = max(last, position)
+	}
+	return last
+}
+
+// positionsOutOfRange returns the selected positions after the last one, as
+// ranges like 21-25,30.
+func positionsOutOfRange(positions map[int]bool, last int) string {
+	var outOfRange []int
+	for position := range positions {
+		if position > last {
+			outOfRange = append(outOfRange, position)
+		}
+	}
+	slices.Sort(outOfRange)
+
+	var ranges []string
+	for i := 0; i < len(outOfRange); {
+		j := i
+		for j+1 < len(outOfRange) && outOfRange[j+1] == outOfRange[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(outOfRange[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", outOfRange[i], outOfRange[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
+
+// warnPositionsOutOfRange logs the selected positions after the last of
+// count tracks. They don't fail the link.
+func (app *application) warnPositionsOutOfRange(url string, positions map[int]bool, count int) {
+	if outOfRange := positionsOutOfRange(positions, count); outOfRange != "" {
+		app.errorLogWrapper(url, "select positions", fmt.Errorf("positions %s out of range, only %d tracks", outOfRange, count))
+	}
+}
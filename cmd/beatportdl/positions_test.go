@@ -0,0 +1,50 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitPositions(t *testing.T) {
+	tests := []struct {
+		input     string
+		url       string
+		positions []int
+		fail      bool
+	}{
+		{"https://www.beatport.com/chart/a/1", "https://www.beatport.com/chart/a/1", nil, false},
+		{"https://www.beatport.com/chart/a/1#1-3", "https://www.beatport.com/chart/a/1", []int{1, 2, 3}, false},
+		{"release:2#5,9,12-13", "release:2", []int{5, 9, 12, 13}, false},
+		{"https://www.beatport.com/chart/a/1#", "https://www.beatport.com/chart/a/1", nil, false},
+		{"https://www.beatport.com/chart/a/1#tracks", "https://www.beatport.com/chart/a/1", nil, false},
+		{"https://www.beatport.com/chart/a/1#0", "", nil, true},
+		{"https://www.beatport.com/chart/a/1#5-2", "", nil, true},
+		{"https://www.beatport.com/chart/a/1#1-999999999", "", nil, true},
+		{"https://www.beatport.com/chart/a/1#10001", "", nil, true},
+	}
+	for _, tt := range tests {
+		url, positions, err := splitPositions(tt.input)
+		if (err != nil) != tt.fail {
+			t.Errorf("splitPositions(%q) error = %v, expected failure %v", tt.input, err, tt.fail)
+			continue
+		}
+		var selected []int
+		for position := range positions {
+			selected = append(selected, position)
+		}
+		slices.Sort(selected)
+		if url != tt.url || !slices.Equal(selected, tt.positions) {
+			t.Errorf("splitPositions(%q) = %q, %v, expected %q, %v", tt.input, url, selected, tt.url, tt.positions)
+		}
+	}
+}
+
+func TestPositionsOutOfRange(t *testing.T) {
+	_, positions, _ := splitPositions("#5,9,12-15,20")
+	if got := positionsOutOfRange(positions, 12); got != "13-15,20" {
+		t.Errorf("positionsOutOfRange() = %q, expected 13-15,20", got)
+	}
+	if got := positionsOutOfRange(positions, 20); got != "" {
+		t.Errorf("positionsOutOfRange() = %q, expected none", got)
+	}
+}
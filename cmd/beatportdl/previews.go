@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+// previewsDirectory is the subdirectory of the downloads directory that
+// --previews downloads to, apart from the purchased files.
+const previewsDirectory = "previews"
+
+// previewExtension returns the file extension of the preview at sampleURL.
+func previewExtension(sampleURL string) string {
+	u, err := url.Parse(sampleURL)
+	if err != nil || path.Ext(u.Path) == "" {
+		return ".mp3"
+	}
+	return path.Ext(u.Path)
+}
+
+// savePreview downloads the preview clip of a track for --previews, named
+// after the track with a [preview] suffix. The clips are public, so they
+// don't need a subscription, and they don't count towards the download
+// quota of any account.
+func (app *application) savePreview(track *beatport.Track, directory string) (location string, err error) {
+	if track.SampleURL == "" {
+		app.infoLogWrapper(track.StoreUrl(), "skipping, no preview available")
+		app.unavailableCount.Add(1)
+		return "", nil
+	}
+	fileName := app.trackFileName(track) + " [preview]"
+	extension := previewExtension(track.SampleURL)
+
+	if app.dryRun {
+		fmt.Printf("Would download %s/%s%s\n", directory, fileName, extension)
+		app.downloadedCount.Add(1)
+		return "", nil
+	}
+
+	filePath, file, err := app.claimTrackFile(directory, fileName, extension, track.ID)
+	if errors.Is(err, errDownloadedInBatch) {
+		app.infoLogWrapper(track.StoreUrl(), "skipping, "+err.Error())
+		app.skippedCount.Add(1)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		app.releaseFile(filePath, file, err)
+	}()
+
+	if _, err := os.Stat(filePath); err == nil && !app.forceDownload {
+		app.infoLogWrapper(track.StoreUrl(), "skipping, already exists")
+		app.skippedCount.Add(1)
+		return "", nil
+	}
+
+	var prefix string
+	infoDisplay := fmt.Sprintf("%s (%s) [Preview]", track.Name.String(), track.MixName.String())
+	if app.config.ShowProgress {
+		prefix = infoDisplay
+	} else {
+		fmt.Println("Downloading " + infoDisplay)
+	}
+
+	app.activeDownloads.Add(1)
+	defer app.activeDownloads.Add(-1)
+
+	partPath := filePath + partFileSuffix
+	if err := app.downloadFile(track.SampleURL, partPath, prefix); err != nil {
+		return "", err
+	}
+	if err := os.Rename(partPath, filePath); err != nil {
+		return "", fmt.Errorf("rename partial file: %w", err)
+	}
+
+	if !app.config.ShowProgress {
+		fmt.Printf("Finished downloading %s\n", infoDisplay)
+	}
+	app.downloadedCount.Add(1)
+	return filePath, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestSavePreview(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("preview"))
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:      &config.AppConfig{TrackFileTemplate: "{name}"},
+		logWriter:   io.Discard,
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  http.DefaultClient,
+		activeFiles: make(map[string]*activeFile),
+	}
+	dir := t.TempDir()
+	track := &beatport.Track{ID: 1, Name: "Strobe", SampleURL: server.URL + "/1.LOFI.mp3"}
+
+	location, err := app.savePreview(track, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(dir, "Strobe [preview].mp3"); filepath.Clean(location) != expected {
+		t.Errorf("savePreview() = %q, expected %q", location, expected)
+	}
+	if data, _ := os.ReadFile(location); string(data) != "preview" {
+		t.Errorf("Preview content = %q", data)
+	}
+
+	track.SampleURL = ""
+	if location, err := app.savePreview(track, dir); location != "" || err != nil {
+		t.Errorf("savePreview() without a preview = %q, %v", location, err)
+	}
+	if app.downloadedCount.Load() != 1 || app.unavailableCount.Load() != 1 {
+		t.Errorf("Counted %d downloaded and %d unavailable tracks", app.downloadedCount.Load(), app.unavailableCount.Load())
+	}
+}
+
+func TestPreviewsNotRecorded(t *testing.T) {
+	app := &application{previews: true}
+	if app.recordsDownloads() {
+		t.Error("Previews are added to the download archive and the ISRC index")
+	}
+	app.previews = false
+	if !app.recordsDownloads() {
+		t.Error("Downloads aren't added to the download archive")
+	}
+}
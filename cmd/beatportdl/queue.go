@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	queueFilename     = "beatportdl-queue.json"
+	remainingFilename = "beatportdl-remaining.txt"
+)
+
+// urlQueue persists the URLs of the current batch that haven't been processed
+// yet, so an interrupted batch can be resumed on the next start. A nil queue
+// persists nothing.
+type urlQueue struct {
+	path    string
+	pending []string
+	mutex   sync.Mutex
+}
+
+// loadURLQueue reads the queue file at path. The returned queue is usable
+// even when the file can't be read, it is overwritten by the next batch.
+func loadURLQueue(path string) (*urlQueue, error) {
+	queue := &urlQueue{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return queue, nil
+	}
+	if err != nil {
+		return queue, fmt.Errorf("read url queue: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &queue.pending); err != nil {
+			return queue, fmt.Errorf("decode url queue: %w", err)
+		}
+	}
+	return queue, nil
+}
+
+func (q *urlQueue) Pending() []string {
+	if q == nil {
+		return nil
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return slices.Clone(q.pending)
+}
+
+// Set replaces the pending URLs with urls.
+func (q *urlQueue) Set(urls []string) error {
+	if q == nil {
+		return nil
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pending = slices.Clone(urls)
+	return q.write()
+}
+
+// Done removes a single occurrence of url from the pending URLs.
+func (q *urlQueue) Done(url string) error {
+	if q == nil {
+		return nil
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	i := slices.Index(q.pending, url)
+	if i == -1 {
+		return nil
+	}
+	q.pending = slices.Delete(q.pending, i, i+1)
+	return q.write()
+}
+
+// write replaces the queue file through a rename, so it never contains a
+// partially written list. An empty queue removes the file.
+func (q *urlQueue) write() error {
+	if len(q.pending) == 0 {
+		if err := os.Remove(q.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove url queue: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(q.pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode url queue: %w", err)
+	}
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("write url queue: %w", err)
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write url queue: %w", err)
+	}
+	return nil
+}
+
+// writeRemaining writes the pending URLs as a plain URL list next to the
+// queue file, which can be passed back as an argument, and returns its path.
+func (q *urlQueue) writeRemaining() (string, error) {
+	pending := q.Pending()
+	if len(pending) == 0 {
+		return "", nil
+	}
+	path := filepath.Join(filepath.Dir(q.path), remainingFilename)
+	data := strings.Join(pending, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		return "", fmt.Errorf("write remaining urls: %w", err)
+	}
+	return path, nil
+}
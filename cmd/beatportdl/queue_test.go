@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestURLQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), queueFilename)
+	queue, err := loadURLQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{"https://a", "https://b", "https://a"}
+	if err := queue.Set(urls); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Done("https://a"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := loadURLQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reloaded.Pending(), []string{"https://b", "https://a"}; !slices.Equal(got, want) {
+		t.Fatalf("pending = %v, want %v", got, want)
+	}
+
+	queue.Done("https://b")
+	queue.Done("https://a")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("queue file not removed when empty: %v", err)
+	}
+}
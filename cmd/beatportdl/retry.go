@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+type statusError struct {
+	Code   int
+	Status string
+	// RetryAfter is the delay requested by a 429 response.
+	RetryAfter time.Duration
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	err := &statusError{Code: resp.StatusCode, Status: resp.Status}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		err.RetryAfter = beatport.RetryAfter(resp)
+	}
+	return err
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("bad status: %s", e.Status)
+}
+
+// incompleteDownloadError is returned when a response body ends without an
+// error before or after its Content-Length.
+type incompleteDownloadError struct {
+	Written  int64
+	Expected int64
+}
+
+func (e *incompleteDownloadError) Error() string {
+	return fmt.Sprintf("incomplete download: got %d bytes, expected %d", e.Written, e.Expected)
+}
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// retryableDownloadError reports whether a failed download is worth retrying:
+// network errors, truncated bodies, checksum mismatches, rate limiting and server errors are,
+// client errors like 403 or 404 are not.
+func retryableDownloadError(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	var incompleteErr *incompleteDownloadError
+	return errors.As(err, &netErr) ||
+		errors.As(err, &incompleteErr) ||
+		errors.Is(err, errChecksumMismatch) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// retryDelay returns the exponential backoff for the given attempt (starting
+// at 1), reduced by a random amount of up to jitter (0-1) of the delay.
+func retryDelay(attempt int, base time.Duration, jitter float64) time.Duration {
+	delay := base << (attempt - 1)
+	if delay <= 0 {
+		return 0
+	}
+	maxJitter := time.Duration(float64(delay) * jitter)
+	if maxJitter <= 0 {
+		return delay
+	}
+	return delay - rand.N(maxJitter+1)
+}
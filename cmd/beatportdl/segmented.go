@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unspok3n/beatportdl/internal/ratelimit"
+
+	"github.com/vbauerster/mpb/v8"
+)
+
+const minSegmentSize = 1 << 20
+
+var errRangeNotSupported = errors.New("range requests not supported")
+
+// downloadFileSegmented downloads url to destination over several concurrent
+// range requests. It reports false without error if the server doesn't
+// support ranges or the file is too small to split, in which case the caller
+// should fall back to a single stream.
+func (app *application) downloadFileSegmented(client proxiedClient, url string, destination string, progress *downloadProgress) (bool, error) {
+	req, err := http.NewRequestWithContext(app.downloadCtx, "HEAD", url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := app.doDownloadRequest(client, req)
+	if err != nil {
+		return false, nil
+	}
+	resp.Body.Close()
+
+	size := resp.ContentLength
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || size <= 0 {
+		return false, nil
+	}
+	segments := min(int64(app.config.DownloadSegments), size/minSegmentSize)
+	if segments < 2 {
+		return false, nil
+	}
+
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return true, fmt.Errorf("create file: %w", err)
+	}
+
+	bar := progress.start(size, 0)
+
+	ctx, cancel := context.WithCancel(app.downloadCtx)
+	defer cancel()
+
+	errs := make(chan error, segments)
+	wg := sync.WaitGroup{}
+	segmentSize := size / segments
+	for i := range segments {
+		start := i * segmentSize
+		end := start + segmentSize - 1
+		if i == segments-1 {
+			end = size - 1
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := app.downloadFileRange(ctx, client, url, out, start, end, bar); err != nil {
+				errs <- err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	err = <-errs
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		// a partially written sparse file can't be resumed by size
+		os.Remove(destination)
+		if errors.Is(err, errRangeNotSupported) {
+			return false, nil
+		}
+		if app.downloadCtx.Err() != nil {
+			return true, app.downloadCtx.Err()
+		}
+		return true, err
+	}
+
+	// The joined file is verified against the HEAD response, which has the
+	// size and checksum of the whole file.
+	info, err := os.Stat(destination)
+	if err == nil {
+		err = app.verifyDownload(destination, resp, info.Size())
+	}
+	if err != nil {
+		os.Remove(destination)
+		return true, err
+	}
+	return true, nil
+}
+
+func (app *application) downloadFileRange(ctx context.Context, client proxiedClient, url string, out *os.File, start, end int64, bar *mpb.Bar) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := app.doDownloadRequest(client, req)
+	if err != nil {
+		return fmt.Errorf("download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return errRangeNotSupported
+	case resp.StatusCode != http.StatusPartialContent:
+		return newStatusError(resp)
+	case contentRangeStart(resp) != start:
+		return errRangeNotSupported
+	}
+
+	var body io.Reader = resp.Body
+	if app.downloadLimiter != nil {
+		body = ratelimit.NewReader(ctx, body, app.downloadLimiter)
+	}
+
+	buf := make([]byte, 32*1024)
+	offset := start
+	for {
+		readStart := time.Now()
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := out.WriteAt(buf[:n], offset); err != nil {
+				return err
+			}
+			offset += int64(n)
+			if bar != nil {
+				bar.EwmaIncrBy(n, time.Since(readStart))
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if offset != end+1 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import "sync"
+
+// semaphore limits the number of concurrent workers. Unlike a buffered
+// channel, its size can change while workers hold it. Shrinking never
+// interrupts or blocks the workers already holding it; new workers just
+// wait until enough of them have released it, so resizing can't deadlock
+// in-flight workers.
+type semaphore struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	size  int
+	used  int
+}
+
+func newSemaphore(size int) *semaphore {
+	s := &semaphore{size: max(size, 1)}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+func (s *semaphore) acquire() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.used >= s.size {
+		s.cond.Wait()
+	}
+	s.used++
+}
+
+func (s *semaphore) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.used--
+	s.cond.Signal()
+}
+
+func (s *semaphore) resize(size int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.size = max(size, 1)
+	s.cond.Broadcast()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSemaphoreResize(t *testing.T) {
+	s := newSemaphore(2)
+	s.acquire()
+	s.acquire()
+
+	// Shrinking below the number of holders must not block them.
+	s.resize(1)
+	s.release()
+
+	acquired := make(chan struct{})
+	go func() {
+		s.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquired a semaphore that is still full after shrinking")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Waiting worker not woken after release")
+	}
+
+	s.resize(3)
+	done := make(chan struct{})
+	go func() {
+		s.acquire()
+		s.acquire()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Growing the semaphore didn't admit new workers")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/taglib"
+)
+
+// mappingValues returns the values of all the tag mapping fields of a track.
+func (app *application) mappingValues(track *beatport.Track) map[string]string {
+	subgenre := ""
+	if track.Subgenre != nil {
+		subgenre = track.Subgenre.Name
+	}
+	trackNumber, trackTotal := track.TrackNumber()
+	return map[string]string{
+		"track_id":                  strconv.Itoa(int(track.ID)),
+		"track_url":                 track.StoreUrl(),
+		"track_name":                fmt.Sprintf("%s (%s)", track.Name.String(), track.MixName.String()),
+		"track_artists":             app.tagArtists(track.Artists, false),
+		"track_remixers":            app.tagArtists(track.Remixers, false),
+		"track_artists_limited":     app.tagArtists(track.Artists, true),
+		"track_remixers_limited":    app.tagArtists(track.Remixers, true),
+		"track_number":              strconv.Itoa(trackNumber),
+		"track_number_with_padding": beatport.NumberWithPadding(trackNumber, trackTotal, app.config.TrackNumberPadding),
+		"track_number_with_total":   fmt.Sprintf("%d/%d", trackNumber, trackTotal),
+		"track_genre":               track.Genre.Name,
+		"track_subgenre":            subgenre,
+		"track_genre_with_subgenre": track.GenreWithSubgenre("|"),
+		"track_subgenre_or_genre":   track.SubgenreOrGenre(),
+		"track_key":                 track.Key.Display(cmp.Or(app.config.KeyFormat, app.config.KeySystem)),
+		"track_bpm":                 formatBPM(track.BPM, app.config.BPMFormat),
+		"track_isrc":                track.ISRC,
+
+		"release_id":               strconv.Itoa(int(track.Release.ID)),
+		"release_url":              track.Release.StoreUrl(),
+		"release_name":             track.Release.Name.String(),
+		"release_artists":          app.tagArtists(track.Release.Artists, false),
+		"release_remixers":         app.tagArtists(track.Release.Remixers, false),
+		"release_artists_limited":  app.tagArtists(track.Release.Artists, true),
+		"release_remixers_limited": app.tagArtists(track.Release.Remixers, true),
+		"release_date":             track.Release.Date,
+		"release_year":             track.Release.Year(),
+		"release_track_count":      strconv.Itoa(track.Release.TrackCount),
+		"release_track_count_with_padding": beatport.NumberWithPadding(
+			track.Release.TrackCount, track.Release.TrackCount, app.config.TrackNumberPadding,
+		),
+		"release_catalog_number": track.Release.CatalogNumber.String(),
+		"release_upc":            track.Release.UPC,
+		"release_label":          track.Release.Label.Name,
+		"release_label_url":      track.Release.Label.StoreUrl(),
+	}
+}
+
+// tagValues returns the values of the tag mapping fields of a track, without
+// the fields left out of tag_fields. With multi_value_artists, multiValues
+// holds the names of the artist fields, to be written as one value each.
+func (app *application) tagValues(track *beatport.Track) (values map[string]string, multiValues map[string][]string) {
+	values = app.mappingValues(track)
+	if len(app.config.TagFields) > 0 {
+		for field := range values {
+			if !slices.Contains(app.config.TagFields, field) {
+				delete(values, field)
+			}
+		}
+	}
+
+	multiValues = make(map[string][]string)
+	if app.config.MultiValueArtists {
+		for field, artists := range map[string]beatport.Artists{
+			"track_artists":    track.Artists,
+			"track_remixers":   track.Remixers,
+			"release_artists":  track.Release.Artists,
+			"release_remixers": track.Release.Remixers,
+		} {
+			if values[field] != "" {
+				multiValues[field] = artists.Names()
+			}
+		}
+	}
+	return values, multiValues
+}
+
+// tagTemplateValues resolves tag_templates for a track, with the track
+// template keywords and the tag mapping fields as placeholders. Tags whose
+// template resolves to nothing are left out.
+func (app *application) tagTemplateValues(track *beatport.Track) map[string]string {
+	if len(app.config.TagTemplates) == 0 {
+		return nil
+	}
+	placeholders := track.TemplateValues(beatport.NamingPreferences{
+		ArtistsLimit:       app.config.ArtistsLimit,
+		ArtistsShortForm:   app.config.ArtistsShortForm,
+		TrackNumberPadding: app.config.TrackNumberPadding,
+		KeySystem:          app.config.KeySystem,
+	})
+	maps.Copy(placeholders, app.mappingValues(track))
+
+	values := make(map[string]string, len(app.config.TagTemplates))
+	for tag, template := range app.config.TagTemplates {
+		if value := strings.TrimSpace(beatport.ParseTemplate(template, placeholders)); value != "" {
+			values[tag] = value
+		}
+	}
+	return values
+}
+
+// tagArtists joins the names of artists with artist_separator. When limited,
+// more than artists_limit artists are replaced with artists_short_form.
+func (app *application) tagArtists(artists beatport.Artists, limited bool) string {
+	if limited && app.config.ArtistsShortForm != "" && len(artists) > app.config.ArtistsLimit {
+		return app.config.ArtistsShortForm
+	}
+	return strings.Join(artists.Names(), app.config.ArtistSeparator)
+}
+
+// setTagValues writes value to property, or each of values when there are
+// several. Empty values are left out.
+func setTagValues(file *taglib.File, property, value string, values []string) {
+	if value == "" {
+		return
+	}
+	if len(values) < 2 {
+		file.SetProperty(property, &value)
+		return
+	}
+	file.SetProperty(property, &values[0])
+	for _, v := range values[1:] {
+		file.AppendProperty(property, v)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestTagValues(t *testing.T) {
+	app := &application{config: &config.AppConfig{
+		ArtistSeparator:  "; ",
+		ArtistsLimit:     1,
+		ArtistsShortForm: "VA",
+		KeySystem:        "standard-short",
+	}}
+	track := &beatport.Track{
+		Name:     "Strobe",
+		MixName:  "Original Mix",
+		Artists:  beatport.Artists{{Name: "deadmau5"}, {Name: "Kaskade"}},
+		Remixers: beatport.Artists{{Name: "Lane 8"}},
+	}
+
+	values, multiValues := app.tagValues(track)
+	if values["track_artists"] != "deadmau5; Kaskade" || values["track_artists_limited"] != "VA" {
+		t.Errorf("Artist values = %q, %q", values["track_artists"], values["track_artists_limited"])
+	}
+	if len(multiValues) != 0 {
+		t.Errorf("Multiple values %v without multi_value_artists", multiValues)
+	}
+
+	app.config.MultiValueArtists = true
+	app.config.TagFields = []string{"track_name", "track_artists"}
+	values, multiValues = app.tagValues(track)
+	if len(values) != 2 || values["track_name"] != "Strobe (Original Mix)" {
+		t.Errorf("tagValues() = %v, expected only track_name and track_artists", values)
+	}
+	expected := map[string][]string{"track_artists": {"deadmau5", "Kaskade"}}
+	if !reflect.DeepEqual(multiValues, expected) {
+		t.Errorf("Multiple values = %v, expected %v", multiValues, expected)
+	}
+}
+
+func TestTagTemplateValues(t *testing.T) {
+	app := &application{config: &config.AppConfig{
+		ArtistSeparator: ", ",
+		KeySystem:       "standard-short",
+		TagTemplates: map[string]string{
+			"COMMENT": "Beatport {track_id} | {label}",
+			"WWW":     "{track_url}",
+			"MOOD":    "{subgenre}",
+		},
+	}}
+	track := &beatport.Track{
+		ID:      17,
+		Slug:    "strobe",
+		Release: beatport.Release{Label: beatport.Label{Name: "mau5trap: Records"}},
+	}
+
+	values := app.tagTemplateValues(track)
+	expected := map[string]string{
+		"COMMENT": "Beatport 17 | mau5trap: Records",
+		"WWW":     track.StoreUrl(),
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("tagTemplateValues() = %v, expected %v", values, expected)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unspok3n/beatportdl/config"
+)
+
+// urlOptionNames are the options that can follow a URL in a text file,
+// like "quality=lossless dir=Techno/2024".
+var urlOptionNames = []string{"quality", "dir"}
+
+// splitUrlEntry splits a queued URL from the options that follow it.
+func splitUrlEntry(entry string) (string, []string) {
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], fields[1:]
+}
+
+// parseUrlOption checks an option token and returns it normalized.
+func parseUrlOption(token string) (string, error) {
+	name, value, ok := strings.Cut(token, "=")
+	if !ok || value == "" {
+		return "", fmt.Errorf("invalid option %q (expected name=value)", token)
+	}
+	switch strings.ToLower(name) {
+	case "quality":
+		quality, err := config.NormalizeQuality(value)
+		if err != nil {
+			return "", err
+		}
+		if quality == "medium-hls" && !config.FFMPEGInstalled() {
+			return "", fmt.Errorf("quality %s needs ffmpeg, which wasn't found", quality)
+		}
+		return "quality=" + quality, nil
+	case "dir":
+		return "dir=" + value, nil
+	default:
+		return "", fmt.Errorf("unknown option %q (expected %s)", name, strings.Join(urlOptionNames, ", "))
+	}
+}
+
+// urlOptions are the settings a queued URL is downloaded with: those of the
+// config, overridden by the options after the URL. They are passed down to
+// the downloads of the URL, so URLs with different options can download at
+// the same time.
+type urlOptions struct {
+	options            []string
+	quality            string
+	downloadsDirectory string
+}
+
+// newUrlOptions applies the options of a URL to cfg. A relative dir is
+// inside the downloads directory.
+func newUrlOptions(cfg *config.AppConfig, options []string) urlOptions {
+	opts := urlOptions{
+		options:            options,
+		quality:            cfg.Quality,
+		downloadsDirectory: cfg.DownloadsDirectory,
+	}
+	for _, option := range options {
+		name, value, _ := strings.Cut(option, "=")
+		switch name {
+		case "quality":
+			opts.quality = value
+		case "dir":
+			if !filepath.IsAbs(value) {
+				value = filepath.Join(cfg.DownloadsDirectory, value)
+			}
+			opts.downloadsDirectory = value
+		}
+	}
+	return opts
+}
+
+// entry returns url followed by the options, as queued.
+func (opts urlOptions) entry(url string) string {
+	return strings.Join(append([]string{url}, opts.options...), " ")
+}
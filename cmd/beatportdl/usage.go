@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const usageFilename = "beatportdl-usage.json"
+
+// accountUsage persists the number of tracks each account downloaded per
+// month, keyed by username and then by month (YYYY-MM). A nil usage records
+// nothing.
+type accountUsage struct {
+	path   string
+	counts map[string]map[string]int64
+	mutex  sync.Mutex
+}
+
+// loadAccountUsage reads the usage file at path. The returned usage is
+// usable even when the file can't be read, it starts counting from zero.
+func loadAccountUsage(path string) (*accountUsage, error) {
+	usage := &accountUsage{
+		path:   path,
+		counts: make(map[string]map[string]int64),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return usage, nil
+	}
+	if err != nil {
+		return usage, fmt.Errorf("read account usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &usage.counts); err != nil {
+		return usage, fmt.Errorf("decode account usage: %w", err)
+	}
+	return usage, nil
+}
+
+func currentMonth() string {
+	return time.Now().Format("2006-01")
+}
+
+// Month returns the number of tracks username downloaded this month.
+func (u *accountUsage) Month(username string) int64 {
+	if u == nil {
+		return 0
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.counts[username][currentMonth()]
+}
+
+// Add records a download by username and writes the usage file.
+func (u *accountUsage) Add(username string) error {
+	if u == nil {
+		return nil
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.counts[username] == nil {
+		u.counts[username] = make(map[string]int64)
+	}
+	u.counts[username][currentMonth()]++
+	return u.write()
+}
+
+func (u *accountUsage) write() error {
+	data, err := json.MarshalIndent(u.counts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode account usage: %w", err)
+	}
+	tmpPath := u.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("write account usage: %w", err)
+	}
+	if err := os.Rename(tmpPath, u.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write account usage: %w", err)
+	}
+	return nil
+}
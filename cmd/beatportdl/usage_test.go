@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAccountUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), usageFilename)
+	usage, err := loadAccountUsage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := usage.Add("first"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usage.Add("second")
+
+	reloaded, err := loadAccountUsage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := reloaded.Month("first"); n != 3 {
+		t.Errorf("Month(first) = %d, expected 3", n)
+	}
+	if n := reloaded.Month("second"); n != 1 {
+		t.Errorf("Month(second) = %d, expected 1", n)
+	}
+
+	var nilUsage *accountUsage
+	if err := nilUsage.Add("first"); err != nil || nilUsage.Month("first") != 0 {
+		t.Error("nil usage recorded a download")
+	}
+}
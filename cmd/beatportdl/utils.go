@@ -2,17 +2,31 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unspok3n/beatportdl/config"
+	"unspok3n/beatportdl/internal/beatport"
+	"unspok3n/beatportdl/internal/ratelimit"
 
 	"github.com/fatih/color"
 	"github.com/vbauerster/mpb/v8"
@@ -35,7 +49,19 @@ func (app *application) globalWorker(fn func()) {
 	}()
 }
 
+// downloadWorker runs fn, the download of a track, in a download worker.
+// The track counts towards the batch progress bar.
 func (app *application) downloadWorker(wg *sync.WaitGroup, fn func()) {
+	app.batchProgress.add()
+	app.downloadJob(wg, func() {
+		defer app.batchProgress.done()
+		fn()
+	})
+}
+
+// downloadJob runs fn in a download worker, like downloadWorker, for
+// downloads that aren't tracks.
+func (app *application) downloadJob(wg *sync.WaitGroup, fn func()) {
 	wg.Add(1)
 
 	go func() {
@@ -58,86 +84,527 @@ func (app *application) downloadWorker(wg *sync.WaitGroup, fn func()) {
 	}()
 }
 
-func (app *application) semAcquire(s chan struct{}) {
-	s <- struct{}{}
+func (app *application) semAcquire(s *semaphore) {
+	s.acquire()
 }
 
-func (app *application) semRelease(s chan struct{}) {
-	<-s
+func (app *application) semRelease(s *semaphore) {
+	s.release()
 }
 
+// downloadFile downloads url to destination with the download client of the
+// active account, see downloadFileWithClient.
 func (app *application) downloadFile(url string, destination string, pbPrefix string) error {
-	out, err := os.Create(destination)
-	if err != nil {
-		return fmt.Errorf("create file: %w", err)
+	return app.downloadFileWithClient(app.downloadClient(), url, destination, pbPrefix)
+}
+
+// downloadFileWithClient downloads url to destination with client, retrying
+// transient failures. An existing destination is treated as a partial
+// download and resumed, so retries continue where the previous attempt
+// stopped.
+func (app *application) downloadFileWithClient(client proxiedClient, url string, destination string, pbPrefix string) error {
+	progress := app.newDownloadProgress(pbPrefix)
+	for attempt := 1; ; attempt++ {
+		if progress != nil {
+			progress.attempt = attempt
+		}
+		err := app.downloadFileAttempt(client, url, destination, progress)
+		if err == nil {
+			return nil
+		}
+		if app.ctx.Err() != nil {
+			progress.drop()
+			return err
+		}
+		if attempt > app.config.MaxRetries || !retryableDownloadError(err) {
+			progress.fail()
+			if attempt > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return err
+		}
+
+		delay := retryDelay(
+			attempt,
+			time.Duration(app.config.RetryBackoffSeconds)*time.Second,
+			app.config.RetryJitter,
+		)
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			delay = max(delay, statusErr.RetryAfter)
+		}
+		app.LogError(
+			fmt.Sprintf(
+				"download %s (retry %d/%d in %s)",
+				filepath.Base(strings.TrimSuffix(destination, partFileSuffix)),
+				attempt, app.config.MaxRetries, delay.Round(time.Millisecond),
+			),
+			err,
+		)
+		progress.drop()
+
+		select {
+		case <-app.ctx.Done():
+			return app.ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (app *application) downloadFileAttempt(client proxiedClient, url string, destination string, progress *downloadProgress) (err error) {
+	var offset int64
+	if info, err := os.Stat(destination); err == nil {
+		offset = info.Size()
+	}
+
+	if offset == 0 && app.config.DownloadSegments > 1 {
+		if done, err := app.downloadFileSegmented(client, url, destination, progress); done {
+			return err
+		}
 	}
-	defer out.Close()
 
-	resp, err := http.Get(url)
+	resp, err := app.requestFile(app.downloadCtx, client, url, offset)
 	if err != nil {
 		return fmt.Errorf("download file: %w", err)
 	}
-	defer resp.Body.Close()
+	defer func() {
+		resp.Body.Close()
+	}()
+
+	if (resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp) != offset) ||
+		resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+		offset = 0
+		resp, err = app.requestFile(app.downloadCtx, client, url, offset)
+		if err != nil {
+			return fmt.Errorf("download file: %w", err)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return newStatusError(resp)
+	}
+
+	out, err := os.OpenFile(destination, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer func() {
+		out.Close()
+		if err != nil && app.downloadCtx.Err() != nil {
+			if !app.config.ResumeDownloads || !strings.HasSuffix(destination, partFileSuffix) {
+				os.Remove(destination)
+			}
+			err = app.downloadCtx.Err()
+		}
+	}()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	var body io.Reader = resp.Body
+	if app.downloadLimiter != nil {
+		body = ratelimit.NewReader(app.downloadCtx, body, app.downloadLimiter)
 	}
 
-	if pbPrefix != "" {
-		contentLength, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
-		bar := app.pbp.AddBar(int64(contentLength), ProgressBarOptions(pbPrefix)...)
+	var written int64
+	if progress != nil {
+		contentLength, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+		bar := progress.start(offset+contentLength, offset)
 
-		proxyReader := bar.ProxyReader(resp.Body)
+		proxyReader := bar.ProxyReader(body)
 		defer proxyReader.Close()
 
-		_, err = io.Copy(out, proxyReader)
+		written, err = io.Copy(out, proxyReader)
 		if err != nil {
 			return err
 		}
 	} else {
-		_, err = io.Copy(out, resp.Body)
+		written, err = io.Copy(out, body)
 		if err != nil {
 			return err
 		}
 	}
 
+	// A body that ends early without a read error, or a checksum that
+	// doesn't match, leaves a file that can't be resumed, so it's removed
+	// and the retry starts over.
+	if err := app.verifyDownload(destination, resp, written); err != nil {
+		out.Close()
+		os.Remove(destination)
+		return err
+	}
+
+	return nil
+}
+
+// verifyDownload checks the written bytes of the file downloaded to
+// destination against the Content-Length of resp and, with verify_checksum,
+// the MD5 of the file against the Content-MD5 or ETag header.
+func (app *application) verifyDownload(destination string, resp *http.Response, written int64) error {
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return &incompleteDownloadError{Written: written, Expected: resp.ContentLength}
+	}
+	if !app.config.VerifyChecksum {
+		return nil
+	}
+	if expected := responseMD5(resp); expected != nil {
+		sum, err := fileMD5(destination)
+		if err != nil {
+			return fmt.Errorf("verify checksum: %w", err)
+		}
+		if !bytes.Equal(sum, expected) {
+			return errChecksumMismatch
+		}
+	}
+	return nil
+}
+
+// requestFile requests url with client, asking the server to skip the first
+// offset bytes when offset is positive.
+func (app *application) requestFile(ctx context.Context, client proxiedClient, url string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return app.doDownloadRequest(client, req)
+}
+
+// doDownloadRequest sends a download request with client once the download
+// request limiter allows it, and throttles the limiter when the server
+// answers 429.
+func (app *application) doDownloadRequest(client proxiedClient, req *http.Request) (*http.Response, error) {
+	if app.requestLimiter != nil {
+		if err := app.requestLimiter.WaitN(req.Context(), 1); err != nil {
+			return nil, err
+		}
+	}
+	proxy := client.proxy
+	resp, err := client.client.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if proxy != "" && errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+			return nil, &proxyError{Proxy: proxyHost(proxy), Err: err}
+		}
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		if app.requestLimiter != nil {
+			app.requestLimiter.Throttle(beatport.RetryAfter(resp))
+		}
+	case http.StatusProxyAuthRequired:
+		resp.Body.Close()
+		return nil, &proxyError{Proxy: proxyHost(proxy), Status: resp.Status}
+	}
+	return resp, nil
+}
+
+// proxiedClient is a download client and the proxy it was built for, which
+// proxy errors name.
+type proxiedClient struct {
+	client *http.Client
+	proxy  string
+}
+
+// downloadClient returns the download client of the active account, which
+// changes when switching to an account with another proxy.
+func (app *application) downloadClient() proxiedClient {
+	app.httpClientMutex.RLock()
+	defer app.httpClientMutex.RUnlock()
+	return proxiedClient{client: app.httpClient, proxy: app.httpProxy}
+}
+
+// proxyError is returned when a download fails because the proxy could not
+// be reached or refused to forward the request, so it isn't mistaken for
+// the CDN rejecting the file itself.
+type proxyError struct {
+	Proxy  string
+	Status string
+	Err    error
+}
+
+func (e *proxyError) Error() string {
+	if e.Status != "" {
+		return fmt.Sprintf("proxy %s rejected the download: %s", e.Proxy, e.Status)
+	}
+	return fmt.Sprintf("proxy %s: %s", e.Proxy, e.Err)
+}
+
+func (e *proxyError) Unwrap() error {
+	return e.Err
+}
+
+// proxyHost returns the host of proxyUrl without its credentials.
+func proxyHost(proxyUrl string) string {
+	proxy, err := url.Parse(proxyUrl)
+	if err != nil || proxy.Host == "" {
+		return "(unknown)"
+	}
+	return proxy.Host
+}
+
+// NewHttpClient returns the client shared by all media and artwork downloads,
+// routed through proxyUrl if it is set. Credentials in the proxy URL are sent
+// to the proxy on every request. Up to idleConnsPerHost connections
+// per host are kept alive between downloads, so a batch of small files doesn't
+// pay for a new TLS handshake on every request.
+func NewHttpClient(proxyUrl string, idleConnsPerHost int) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = max(idleConnsPerHost, http.DefaultMaxIdleConnsPerHost)
+	transport.MaxIdleConns = max(transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	if proxyUrl != "" {
+		proxy, err := config.ParseProxyUrl(proxyUrl)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+		transport.OnProxyConnectResponse = func(_ context.Context, proxy *url.URL, _ *http.Request, resp *http.Response) error {
+			if resp.StatusCode != http.StatusOK {
+				return &proxyError{Proxy: proxy.Host, Status: resp.Status}
+			}
+			return nil
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// responseMD5 returns the MD5 digest of the file announced by the server in
+// the Content-MD5 header of a full response, or in an ETag that is a plain
+// MD5 digest, as S3 sends for files uploaded in one part. It returns nil
+// if the response has neither.
+func responseMD5(resp *http.Response) []byte {
+	if resp.StatusCode == http.StatusOK {
+		sum, err := base64.StdEncoding.DecodeString(resp.Header.Get("Content-MD5"))
+		if err == nil && len(sum) == md5.Size {
+			return sum
+		}
+	}
+	sum, err := hex.DecodeString(strings.Trim(resp.Header.Get("ETag"), `"`))
+	if err == nil && len(sum) == md5.Size {
+		return sum
+	}
 	return nil
 }
 
+func fileMD5(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// contentRangeStart returns the first byte position of a partial response,
+// or -1 if the Content-Range header is missing or malformed.
+func contentRangeStart(resp *http.Response) int64 {
+	var start int64
+	_, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
 func toMetaFunc(c *color.Color) func(string) string {
 	return func(s string) string {
 		return c.Sprint(s)
 	}
 }
 
-func ProgressBarOptions(prefix string) []mpb.BarOption {
+// ProgressBarOptions returns the options for the bar of a file download.
+// The decorators, like the retry label, are shown after the prefix. A bar
+// aborted without being dropped turns red and shows that the download
+// failed.
+func ProgressBarOptions(prefix string, decorators ...decor.Decorator) []mpb.BarOption {
 	red, green, blue := color.New(color.FgRed), color.New(color.FgGreen), color.New(color.FgBlue)
 
-	options := []mpb.BarOption{
-		mpb.BarFillerClearOnComplete(),
-		mpb.PrependDecorators(
-			decor.OnCompleteMeta(
-				decor.OnComplete(
+	prepend := []decor.Decorator{
+		decor.OnCompleteMeta(
+			decor.OnComplete(
+				decor.OnAbort(
 					decor.Meta(decor.Spinner([]string{"⣾ ", "⣽ ", "⣻ ", "⢿ ", "⡿ ", "⣟ ", "⣯ ", "⣷ "}), toMetaFunc(red)),
-					"✓ ",
+					"✗ ",
 				),
-				toMetaFunc(green),
+				"✓ ",
 			),
-			decor.Name(prefix, decor.WCSyncSpaceR),
+			toMetaFunc(green),
 		),
+		decor.OnAbortMeta(decor.Name(prefix, decor.WCSyncSpaceR), toMetaFunc(red)),
+	}
+	options := []mpb.BarOption{
+		mpb.BarFillerClearOnComplete(),
+		mpb.PrependDecorators(append(prepend, decorators...)...),
 		mpb.AppendDecorators(
-			decor.OnComplete(decor.Meta(decor.Percentage(decor.WCSyncSpace), toMetaFunc(blue)), ""),
-			decor.OnComplete(decor.Name(" |"), ""),
-			decor.OnComplete(decor.Meta(
+			decor.OnAbortMeta(
+				decor.OnAbort(decor.OnComplete(decor.Meta(decor.Percentage(decor.WCSyncSpace), toMetaFunc(blue)), ""), "failed"),
+				toMetaFunc(red),
+			),
+			decor.OnAbort(decor.OnComplete(decor.Name(" |"), ""), ""),
+			decor.OnAbort(decor.OnComplete(decor.Meta(
 				decor.EwmaSpeed(decor.SizeB1000(0), "% .2f", 30, decor.WCSyncSpace), toMetaFunc(blue),
-			), ""),
-			decor.OnComplete(decor.Name(" | "), ""),
-			decor.OnComplete(decor.Meta(decor.EwmaETA(decor.ET_STYLE_MMSS, 0, decor.WCSyncWidth), toMetaFunc(blue)), ""),
+			), ""), ""),
+			decor.OnAbort(decor.OnComplete(decor.Name(" | "), ""), ""),
+			decor.OnAbort(decor.OnComplete(decor.Meta(decor.EwmaETA(decor.ET_STYLE_MMSS, 0, decor.WCSyncWidth), toMetaFunc(blue)), ""), ""),
 		),
 	}
 	return options
 }
 
+// downloadProgress is the progress bar of a file downloaded over one or more
+// attempts. Each attempt replaces the bar of the previous one, labeled with
+// its retry number, so the bar never looks stuck or restarts silently.
+type downloadProgress struct {
+	pbp        *mpb.Progress
+	prefix     string
+	attempt    int
+	maxRetries int
+	bar        *mpb.Bar
+}
+
+// newDownloadProgress returns the progress of a download shown with prefix,
+// or nil without a prefix, when no progress is shown.
+func (app *application) newDownloadProgress(prefix string) *downloadProgress {
+	if prefix == "" {
+		return nil
+	}
+	return &downloadProgress{
+		pbp:        app.pbp,
+		prefix:     prefix,
+		attempt:    1,
+		maxRetries: app.config.MaxRetries,
+	}
+}
+
+// start adds the bar of the current attempt, with total bytes of which
+// current are already downloaded. A nil progress has no bar.
+func (p *downloadProgress) start(total, current int64) *mpb.Bar {
+	if p == nil {
+		return nil
+	}
+	p.drop()
+	var decorators []decor.Decorator
+	if p.attempt > 1 {
+		label := fmt.Sprintf("(retry %d/%d)", p.attempt-1, p.maxRetries)
+		decorators = append(decorators, decor.Meta(decor.Name(label, decor.WCSyncSpaceR), toMetaFunc(color.New(color.FgYellow))))
+	}
+	p.bar = p.pbp.AddBar(total, ProgressBarOptions(p.prefix, decorators...)...)
+	p.bar.SetCurrent(current)
+	return p.bar
+}
+
+// drop removes the bar of the current attempt.
+func (p *downloadProgress) drop() {
+	if p != nil && p.bar != nil {
+		p.bar.Abort(true)
+		p.bar = nil
+	}
+}
+
+// fail leaves the bar of the last attempt on screen, marked as failed.
+func (p *downloadProgress) fail() {
+	if p != nil && p.bar != nil {
+		p.bar.Abort(false)
+		p.bar = nil
+	}
+}
+
+// ChartProgressBarOptions returns the options for a bar counting the finished
+// tracks of a chart.
+func ChartProgressBarOptions(name string) []mpb.BarOption {
+	green, blue := color.New(color.FgGreen), color.New(color.FgBlue)
+
+	return []mpb.BarOption{
+		mpb.BarPriority(-1),
+		mpb.PrependDecorators(
+			decor.OnCompleteMeta(decor.OnComplete(decor.Name("♫ "), "✓ "), toMetaFunc(green)),
+			decor.Name(name, decor.WCSyncSpaceR),
+		),
+		mpb.AppendDecorators(
+			decor.Meta(decor.CountersNoUnit("%d / %d tracks", decor.WCSyncSpace), toMetaFunc(blue)),
+		),
+	}
+}
+
+// BatchProgressBarOptions returns the options for the bar of the whole
+// batch, above the bars of the charts and files.
+func BatchProgressBarOptions() []mpb.BarOption {
+	green, blue := color.New(color.FgGreen), color.New(color.FgBlue)
+
+	return []mpb.BarOption{
+		mpb.BarPriority(-2),
+		mpb.PrependDecorators(
+			decor.OnCompleteMeta(decor.OnComplete(decor.Name("≡ "), "✓ "), toMetaFunc(green)),
+			decor.Name("Batch", decor.WCSyncSpaceR),
+		),
+		mpb.AppendDecorators(
+			decor.Meta(decor.CountersNoUnit("%d / %d tracks", decor.WCSyncSpace), toMetaFunc(blue)),
+			decor.OnComplete(decor.Name(" | "), ""),
+			decor.OnComplete(decor.Meta(decor.AverageETA(decor.ET_STYLE_MMSS, decor.WCSyncWidth), toMetaFunc(blue)), ""),
+		),
+	}
+}
+
+// batchProgress is the bar of the whole batch, counting the finished tracks
+// out of those queued so far. Its total grows as the links are resolved. A
+// nil batchProgress shows nothing.
+type batchProgress struct {
+	bar   *mpb.Bar
+	total int64
+	mutex sync.Mutex
+}
+
+// newBatchProgress adds the batch bar, unless progress bars or the batch
+// bar are turned off.
+func (app *application) newBatchProgress() *batchProgress {
+	if !app.config.ShowProgress || !app.config.BatchProgress {
+		return nil
+	}
+	return &batchProgress{bar: app.pbp.AddBar(0, BatchProgressBarOptions()...)}
+}
+
+func (p *batchProgress) add() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.total++
+	p.bar.SetTotal(p.total, false)
+}
+
+func (p *batchProgress) done() {
+	if p != nil {
+		p.bar.Increment()
+	}
+}
+
+// finish completes the bar at the end of the batch, or leaves it where it
+// stopped if the batch was interrupted.
+func (p *batchProgress) finish(interrupted bool) {
+	if p == nil {
+		return
+	}
+	if interrupted {
+		p.bar.Abort(false)
+		return
+	}
+	p.bar.SetTotal(-1, true)
+}
+
 func GetLine() string {
 	reader := bufio.NewReader(os.Stdin)
 	input, err := reader.ReadString('\n')
@@ -163,18 +630,95 @@ func Pause() {
 
 func (app *application) LogError(caller string, err error) {
 	message := fmt.Sprintf("%s: %s\n", caller, err.Error())
+	if app.jsonLogWriter != nil {
+		line := jsonLogLine(logEntry{
+			Level:   "error",
+			Caller:  caller,
+			Message: strings.TrimSuffix(message, "\n"),
+			Error:   err.Error(),
+		})
+		app.jsonLogWriter.Write(line)
+		if app.logFile != nil {
+			app.logFile.Write(line)
+		}
+		return
+	}
 	fmt.Fprint(app.logWriter, message)
 
 	if app.logFile != nil {
-		app.logFile.WriteString(message)
+		app.logFile.writeLine("ERROR", strings.TrimSuffix(message, "\n"))
+	}
+}
+
+// logLevel is the most detailed kind of message logged. The zero value logs
+// info messages, so applications built without a config do too.
+type logLevel int
+
+const (
+	logLevelError logLevel = iota - 1
+	logLevelInfo
+	logLevelDebug
+)
+
+func parseLogLevel(level string) logLevel {
+	switch level {
+	case "error":
+		return logLevelError
+	case "debug":
+		return logLevelDebug
+	default:
+		return logLevelInfo
 	}
 }
 
 func (app *application) LogInfo(info string) {
+	if app.logLevel < logLevelInfo {
+		return
+	}
+	if app.jsonLogWriter != nil {
+		app.jsonLogWriter.Write(jsonLogLine(logEntry{
+			Level:   "info",
+			Message: info,
+		}))
+		return
+	}
 	message := fmt.Sprintf("%s\n", info)
 	fmt.Fprint(app.logWriter, message)
 }
 
+// LogDebug logs details for bug reports, such as API requests and download
+// URLs, with log_level: debug or -v.
+func (app *application) LogDebug(message string) {
+	if app.logLevel < logLevelDebug {
+		return
+	}
+	if app.jsonLogWriter != nil {
+		app.jsonLogWriter.Write(jsonLogLine(logEntry{
+			Level:   "debug",
+			Message: message,
+		}))
+		return
+	}
+	fmt.Fprintf(app.logWriter, "DEBUG %s\n", message)
+}
+
+// logEntry is a log message written with log_format: json.
+type logEntry struct {
+	Level     string `json:"level"`
+	Caller    string `json:"caller,omitempty"`
+	Message   string `json:"message"`
+	Error     string `json:"error,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// jsonLogLine encodes entry as a single line, so each line written to the
+// log is one complete JSON object.
+func jsonLogLine(entry logEntry) []byte {
+	entry.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	line, _ := json.Marshal(entry)
+	return append(line, '\n')
+}
+
 func (app *application) FatalError(caller string, err error) {
 	app.LogError(caller, err)
 	Pause()
@@ -216,6 +760,82 @@ func FindConfigFile() (string, bool, error) {
 	return findFile(configFilename, additionalDirs)
 }
 
+// ConfigDirectories returns the directories searched for account config files,
+// in order of precedence: the user config directory, the executable directory
+// and the working directory.
+func ConfigDirectories() ([]string, error) {
+	var directories []string
+
+	if userCfgDir, err := os.UserConfigDir(); err == nil {
+		directories = append(directories, filepath.Join(userCfgDir, "beatportdl"))
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %v", err)
+	}
+	directories = append(directories, filepath.Dir(execPath))
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %v", err)
+	}
+	directories = append(directories, workingDir)
+
+	var unique []string
+	for _, directory := range directories {
+		if !slices.Contains(unique, directory) {
+			unique = append(unique, directory)
+		}
+	}
+	return unique, nil
+}
+
+// FindConfigFiles returns every YAML config file in the first config directory
+// that contains any.
+func FindConfigFiles() ([]string, error) {
+	directories, err := ConfigDirectories()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, directory := range directories {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			continue
+		}
+
+		var configFiles []string
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			name := entry.Name()
+			if stateFile(name) {
+				continue
+			}
+			if strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".json") {
+				configFiles = append(configFiles, filepath.Join(directory, name))
+			}
+		}
+
+		if len(configFiles) > 0 {
+			return configFiles, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no config files found in: %s", strings.Join(directories, ", "))
+}
+
+// stateFile reports whether name is one of the JSON files BeatportDL keeps
+// next to the config files, which must not be read as configs.
+func stateFile(name string) bool {
+	return strings.HasSuffix(name, tokenCacheSuffix) ||
+		strings.HasPrefix(name, strings.TrimSuffix(cacheFilename, ".json")) ||
+		name == queueFilename ||
+		name == usageFilename
+}
+
 func FindCacheFile() (string, bool, error) {
 	var additionalDirs []string
 
@@ -232,6 +852,34 @@ func FindCacheFile() (string, bool, error) {
 	return findFile(cacheFilename, additionalDirs)
 }
 
+// AccountCacheFilePath returns the token cache file for an account, named
+// after its config file, e.g. account1.yml.token.json.
+func AccountCacheFilePath(configFilePath string) string {
+	return configFilePath + tokenCacheSuffix
+}
+
+// migrateAccountCacheFile renames a token cache file written by earlier
+// versions, which were keyed by a hash of the username, so the account
+// doesn't have to log in with its password again.
+func migrateAccountCacheFile(configFilePath, username string) {
+	cachePath := AccountCacheFilePath(configFilePath)
+	if _, err := os.Stat(cachePath); !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	os.Rename(legacyAccountCacheFilePath(configFilePath, username), cachePath)
+}
+
+func legacyAccountCacheFilePath(configFilePath, username string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(username))
+	fileName := fmt.Sprintf(
+		"%s-%s.json",
+		strings.TrimSuffix(cacheFilename, ".json"),
+		hex.EncodeToString(hash.Sum(nil)),
+	)
+	return filepath.Join(filepath.Dir(configFilePath), fileName)
+}
+
 func FindErrorLogFile() (string, bool, error) {
 	var additionalDirs []string
 	return findFile(errorFilename, additionalDirs)
@@ -269,6 +917,21 @@ func findFile(fileName string, additionalDirs []string) (string, bool, error) {
 	return defaultFilePath, false, nil
 }
 
+// checkWritableDirectory creates directory if needed and makes sure files
+// can be created in it.
+func checkWritableDirectory(directory string) error {
+	if err := CreateDirectory(directory); err != nil {
+		return fmt.Errorf("%s: %w", directory, err)
+	}
+	file, err := os.CreateTemp(directory, ".beatportdl-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", directory, err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil
+}
+
 func CreateDirectory(directory string) error {
 	if _, err := os.Stat(directory); os.IsNotExist(err) {
 		if err := os.MkdirAll(directory, 0760); err != nil {
@@ -1,9 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unspok3n/beatportdl/config"
+
+	"github.com/vbauerster/mpb/v8"
 )
 
 func TestFindConfigFile(t *testing.T) {
@@ -73,3 +91,614 @@ func TestFindCacheFile(t *testing.T) {
 		}
 	})
 }
+
+func TestDownloadFileResume(t *testing.T) {
+	content := []byte(strings.Repeat("beatportdl", 1000))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:      &config.AppConfig{},
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  http.DefaultClient,
+	}
+
+	t.Run("Append to a partial file", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "track.flac")
+		if err := os.WriteFile(destination, content[:4000], 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.downloadFile(server.URL, destination, ""); err != nil {
+			t.Fatalf("downloadFile() failed: %v", err)
+		}
+
+		data, _ := os.ReadFile(destination)
+		if !bytes.Equal(data, content) {
+			t.Errorf("Content mismatch, got %d bytes, expected %d", len(data), len(content))
+		}
+	})
+
+	t.Run("Restart when the partial file is too large", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "track.flac")
+		if err := os.WriteFile(destination, append(content, content...), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := app.downloadFile(server.URL, destination, ""); err != nil {
+			t.Fatalf("downloadFile() failed: %v", err)
+		}
+
+		data, _ := os.ReadFile(destination)
+		if !bytes.Equal(data, content) {
+			t.Errorf("Content mismatch, got %d bytes, expected %d", len(data), len(content))
+		}
+	})
+}
+
+func TestDownloadFileRetry(t *testing.T) {
+	app := &application{
+		config:      &config.AppConfig{MaxRetries: 2},
+		logWriter:   io.Discard,
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  http.DefaultClient,
+	}
+
+	t.Run("Retry on server errors", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		destination := filepath.Join(t.TempDir(), "track.flac")
+		if err := app.downloadFile(server.URL, destination, ""); err != nil {
+			t.Fatalf("downloadFile() failed: %v", err)
+		}
+		if requests != 3 {
+			t.Errorf("Expected 3 requests, got %d", requests)
+		}
+	})
+
+	t.Run("Do not retry on client errors", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+
+		destination := filepath.Join(t.TempDir(), "track.flac")
+		if err := app.downloadFile(server.URL, destination, ""); err == nil {
+			t.Fatal("downloadFile() succeeded on 403")
+		}
+		if requests != 1 {
+			t.Errorf("Expected 1 request, got %d", requests)
+		}
+	})
+}
+
+func TestDownloadFileChecksum(t *testing.T) {
+	content := []byte(strings.Repeat("beatportdl", 1000))
+	sum := md5.Sum(content)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", etag)
+		if requests == 1 {
+			w.Write(bytes.ToUpper(content))
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:      &config.AppConfig{MaxRetries: 1, VerifyChecksum: true},
+		logWriter:   io.Discard,
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  http.DefaultClient,
+	}
+
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := app.downloadFile(server.URL, destination, ""); err != nil {
+		t.Fatalf("downloadFile() failed: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected a retry after the checksum mismatch, got %d requests", requests)
+	}
+	data, _ := os.ReadFile(destination)
+	if !bytes.Equal(data, content) {
+		t.Error("Content mismatch after retry")
+	}
+
+	etag = `"00000000000000000000000000000000"`
+	requests = 1
+	if err := app.downloadFile(server.URL, destination+"2", ""); !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("downloadFile() = %v, expected a checksum mismatch", err)
+	}
+	if _, err := os.Stat(destination + "2"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("File with a bad checksum was not removed")
+	}
+}
+
+func TestDownloadFileRetryResumes(t *testing.T) {
+	content := []byte(strings.Repeat("beatportdl", 1000))
+	var rangeHeader string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:4000])
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		rangeHeader = r.Header.Get("Range")
+		http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:      &config.AppConfig{MaxRetries: 1},
+		logWriter:   io.Discard,
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  http.DefaultClient,
+	}
+
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := app.downloadFile(server.URL, destination, ""); err != nil {
+		t.Fatalf("downloadFile() failed: %v", err)
+	}
+
+	if rangeHeader != "bytes=4000-" {
+		t.Errorf("Retry did not resume, Range header: %q", rangeHeader)
+	}
+	data, _ := os.ReadFile(destination)
+	if !bytes.Equal(data, content) {
+		t.Errorf("Content mismatch, got %d bytes, expected %d", len(data), len(content))
+	}
+}
+
+func TestDownloadFileCancel(t *testing.T) {
+	newApp := func(ctx, downloadCtx context.Context) *application {
+		return &application{
+			config:      &config.AppConfig{MaxRetries: 3},
+			logWriter:   io.Discard,
+			ctx:         ctx,
+			downloadCtx: downloadCtx,
+			httpClient:  http.DefaultClient,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "8000")
+		w.Write(make([]byte, 4000))
+		w.(http.Flusher).Flush()
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	app := newApp(ctx, ctx)
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := app.downloadFile(server.URL, destination, ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("Partial file was not removed")
+	}
+
+	t.Run("Keep resumable partial file", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "8000")
+			w.Write(make([]byte, 4000))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		app := newApp(ctx, ctx)
+		app.config.ResumeDownloads = true
+
+		destination := filepath.Join(t.TempDir(), "track.flac"+partFileSuffix)
+		go func() {
+			for {
+				if info, err := os.Stat(destination); err == nil && info.Size() == 4000 {
+					cancel()
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+
+		if err := app.downloadFile(server.URL, destination, ""); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+		if info, err := os.Stat(destination); err != nil || info.Size() != 4000 {
+			t.Errorf("Resumable partial file was not kept: %v", err)
+		}
+	})
+
+	t.Run("Finish the download after the first interrupt", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "8000")
+			w.Write(make([]byte, 4000))
+			w.(http.Flusher).Flush()
+			cancel()
+			w.Write(make([]byte, 4000))
+		}))
+		defer server.Close()
+
+		app := newApp(ctx, context.Background())
+		destination := filepath.Join(t.TempDir(), "track.flac")
+		if err := app.downloadFile(server.URL, destination, ""); err != nil {
+			t.Fatalf("downloadFile() failed: %v", err)
+		}
+		if info, err := os.Stat(destination); err != nil || info.Size() != 8000 {
+			t.Errorf("Download in flight didn't finish: %v", err)
+		}
+	})
+}
+
+func TestDownloadFileSegmented(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 350000))
+	var rangeRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			rangeRequests.Add(1)
+		}
+		http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:      &config.AppConfig{DownloadSegments: 3},
+		logWriter:   io.Discard,
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  http.DefaultClient,
+	}
+
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := app.downloadFile(server.URL, destination, ""); err != nil {
+		t.Fatalf("downloadFile() failed: %v", err)
+	}
+
+	if got := rangeRequests.Load(); got != 3 {
+		t.Errorf("Expected 3 range requests, got %d", got)
+	}
+	data, _ := os.ReadFile(destination)
+	if !bytes.Equal(data, content) {
+		t.Errorf("Content mismatch, got %d bytes, expected %d", len(data), len(content))
+	}
+}
+
+func TestDownloadFileSegmentedChecksum(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 350000))
+	sum := md5.Sum(content)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	app := &application{
+		config:      &config.AppConfig{DownloadSegments: 3, VerifyChecksum: true},
+		logWriter:   io.Discard,
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  http.DefaultClient,
+	}
+
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := app.downloadFile(server.URL, destination, ""); err != nil {
+		t.Fatalf("downloadFile() failed: %v", err)
+	}
+
+	etag = `"00000000000000000000000000000000"`
+	destination = filepath.Join(t.TempDir(), "track.flac")
+	if err := app.downloadFile(server.URL, destination, ""); !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("downloadFile() = %v, expected a checksum mismatch", err)
+	}
+	if _, err := os.Stat(destination); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Joined file with a bad checksum was not removed")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDownloadFileWithClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("track"))
+	}))
+	defer server.Close()
+
+	// The active account's client must not be used for a file another
+	// account served.
+	active := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("active account's client used")
+	})}
+	app := &application{
+		config:      &config.AppConfig{},
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  active,
+	}
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	if err := app.downloadFileWithClient(proxiedClient{client: http.DefaultClient}, server.URL, destination, ""); err != nil {
+		t.Fatalf("downloadFileWithClient() failed: %v", err)
+	}
+
+	acc := newAccount("a.yml", &config.AppConfig{Proxy: "http://proxy:8080", MaxDownloadWorkers: 1}, "")
+	client, err := acc.downloadClient()
+	if err != nil || client.proxy != "http://proxy:8080" {
+		t.Errorf("downloadClient() = %q, %v, expected the account's proxy", client.proxy, err)
+	}
+	if again, _ := acc.downloadClient(); again.client != client.client {
+		t.Error("downloadClient() built a new client on the second call")
+	}
+}
+
+func TestDownloadFileIncomplete(t *testing.T) {
+	// A body that ends cleanly before Content-Length, which a real
+	// connection reports as io.ErrUnexpectedEOF instead.
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			ContentLength: 8000,
+			Body:          io.NopCloser(bytes.NewReader(make([]byte, 4000))),
+		}, nil
+	})}
+	app := &application{
+		config:      &config.AppConfig{},
+		logWriter:   io.Discard,
+		ctx:         context.Background(),
+		downloadCtx: context.Background(),
+		httpClient:  client,
+	}
+
+	destination := filepath.Join(t.TempDir(), "track.flac")
+	err := app.downloadFile("http://cdn.invalid/track.flac", destination, "")
+	var incompleteErr *incompleteDownloadError
+	if !errors.As(err, &incompleteErr) || incompleteErr.Written != 4000 || incompleteErr.Expected != 8000 {
+		t.Fatalf("downloadFile() = %v, expected an incomplete download of 4000 of 8000 bytes", err)
+	}
+	if _, err := os.Stat(destination); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Incomplete file was not removed")
+	}
+}
+
+// BenchmarkDownloadSmallFiles downloads small files over TLS from many
+// concurrent workers, comparing a new connection per file, the default two
+// idle connections per host and the pool used by the downloads client.
+func BenchmarkDownloadSmallFiles(b *testing.B) {
+	content := make([]byte, 64*1024)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	const workers = 15
+	newClient := func(keepAlive bool, idleConnsPerHost int) *http.Client {
+		client, err := NewHttpClient("", idleConnsPerHost)
+		if err != nil {
+			b.Fatal(err)
+		}
+		transport := client.Transport.(*http.Transport)
+		transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+		transport.DisableKeepAlives = !keepAlive
+		return client
+	}
+
+	for _, bc := range []struct {
+		name             string
+		keepAlive        bool
+		idleConnsPerHost int
+	}{
+		{"New connection per file", false, 0},
+		{"Default idle connections", true, 0},
+		{"Shared client", true, workers},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			app := &application{
+				config:      &config.AppConfig{},
+				logWriter:   io.Discard,
+				ctx:         context.Background(),
+				downloadCtx: context.Background(),
+				httpClient:  newClient(bc.keepAlive, bc.idleConnsPerHost),
+			}
+			dir := b.TempDir()
+			b.SetParallelism(workers)
+			b.ResetTimer()
+			var n atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					destination := filepath.Join(dir, strconv.FormatInt(n.Add(1), 10))
+					if err := app.downloadFile(server.URL, destination, ""); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestDownloadFileProxy(t *testing.T) {
+	content := []byte(strings.Repeat("beatportdl", 100))
+	var proxyAuth atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyAuth.Store(r.Header.Get("Proxy-Authorization"))
+		if r.Header.Get("Proxy-Authorization") == "" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		w.Write(content)
+	}))
+	defer proxy.Close()
+
+	newApp := func(proxyUrl string) *application {
+		client, err := NewHttpClient(proxyUrl, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &application{
+			config:      &config.AppConfig{Proxy: proxyUrl},
+			ctx:         context.Background(),
+			downloadCtx: context.Background(),
+			httpClient:  client,
+			httpProxy:   proxyUrl,
+		}
+	}
+
+	t.Run("Send proxy credentials", func(t *testing.T) {
+		app := newApp(strings.Replace(proxy.URL, "http://", "http://user:pass@", 1))
+		destination := filepath.Join(t.TempDir(), "track.flac")
+		if err := app.downloadFile("http://cdn.invalid/track.flac", destination, ""); err != nil {
+			t.Fatalf("downloadFile() failed: %v", err)
+		}
+		if auth, _ := proxyAuth.Load().(string); !strings.HasPrefix(auth, "Basic ") {
+			t.Errorf("Proxy-Authorization = %q, expected basic credentials", auth)
+		}
+	})
+
+	t.Run("Report proxy rejection", func(t *testing.T) {
+		app := newApp(proxy.URL)
+		destination := filepath.Join(t.TempDir(), "track.flac")
+		err := app.downloadFile("http://cdn.invalid/track.flac", destination, "")
+		var proxyErr *proxyError
+		if !errors.As(err, &proxyErr) {
+			t.Fatalf("downloadFile() error = %v, expected a proxy error", err)
+		}
+	})
+}
+
+func TestLogJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	app := &application{logWriter: &stdout, jsonLogWriter: &stderr}
+
+	app.LogError("fetch track", errors.New("request failed"))
+	app.LogInfo("skipping, already exists")
+
+	if stdout.Len() != 0 {
+		t.Errorf("JSON logs written to the log writer: %q", stdout.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Got %d log lines, expected 2: %q", len(lines), stderr.String())
+	}
+
+	var entry logEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != "error" || entry.Caller != "fetch track" || entry.Error != "request failed" || entry.Message != "fetch track: request failed" {
+		t.Errorf("Unexpected error entry %+v", entry)
+	}
+	if _, err := time.Parse(time.RFC3339, entry.Timestamp); err != nil {
+		t.Errorf("Invalid timestamp: %v", err)
+	}
+
+	entry = logEntry{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != "info" || entry.Message != "skipping, already exists" || entry.Error != "" {
+		t.Errorf("Unexpected info entry %+v", entry)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	var log bytes.Buffer
+	app := &application{logWriter: &log, logLevel: logLevelError}
+	app.LogInfo("info")
+	app.LogDebug("debug")
+	app.LogError("caller", errors.New("error"))
+	if log.String() != "caller: error\n" {
+		t.Errorf("Logged %q at error level, expected only the error", log.String())
+	}
+
+	log.Reset()
+	app.logLevel = logLevelDebug
+	app.LogInfo("info")
+	app.LogDebug("debug")
+	if log.String() != "info\nDEBUG debug\n" {
+		t.Errorf("Logged %q at debug level, expected info and debug", log.String())
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	app := &application{
+		config: &config.AppConfig{MaxRetries: 2},
+		pbp:    mpb.New(mpb.WithOutput(io.Discard)),
+	}
+	defer app.pbp.Shutdown()
+	if app.newDownloadProgress("") != nil {
+		t.Fatal("newDownloadProgress() without a prefix should show no progress")
+	}
+
+	progress := app.newDownloadProgress("Strobe")
+	first := progress.start(100, 0)
+	progress.attempt = 2
+	second := progress.start(100, 40)
+	if !first.Aborted() || second.Aborted() {
+		t.Error("start() should replace the bar of the previous attempt")
+	}
+	progress.fail()
+	if !second.Aborted() {
+		t.Error("fail() should abort the bar of the last attempt")
+	}
+}
+
+func TestBatchProgress(t *testing.T) {
+	app := &application{
+		config:      &config.AppConfig{ShowProgress: true, BatchProgress: true},
+		ctx:         context.Background(),
+		downloadSem: newSemaphore(2),
+		pbp:         mpb.New(mpb.WithOutput(io.Discard)),
+	}
+	defer app.pbp.Shutdown()
+	app.batchProgress = app.newBatchProgress()
+
+	var wg sync.WaitGroup
+	for range 3 {
+		app.downloadWorker(&wg, func() {})
+	}
+	app.downloadJob(&wg, func() {})
+	wg.Wait()
+	if app.batchProgress.total != 3 || app.batchProgress.bar.Current() != 3 {
+		t.Errorf("Batch progress = %d / %d, expected 3 / 3 tracks", app.batchProgress.bar.Current(), app.batchProgress.total)
+	}
+	app.batchProgress.finish(false)
+	if !app.batchProgress.bar.Completed() {
+		t.Error("finish() should complete the batch bar")
+	}
+
+	app.config.BatchProgress = false
+	if app.newBatchProgress() != nil {
+		t.Error("newBatchProgress() should show nothing with batch_progress off")
+	}
+}
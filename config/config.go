@@ -1,51 +1,109 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
 	"unspok3n/beatportdl/internal/validator"
 
 	"gopkg.in/yaml.v2"
 )
 
 type AppConfig struct {
-	Username      string `yaml:"username,omitempty"`
-	Password      string `yaml:"password,omitempty"`
-	Quality       string `yaml:"quality,omitempty"`
-	WriteErrorLog bool   `yaml:"write_error_log,omitempty"`
-	ShowProgress  bool   `yaml:"show_progress,omitempty"`
-
-	MaxGlobalWorkers   int `yaml:"max_global_workers,omitempty"`
-	MaxDownloadWorkers int `yaml:"max_download_workers,omitempty"`
-
-	DownloadsDirectory      string `yaml:"downloads_directory,omitempty"`
-	SortByContext           bool   `yaml:"sort_by_context,omitempty"`
-	SortByLabel             bool   `yaml:"sort_by_label,omitempty"`
-	ForceReleaseDirectories bool   `yaml:"force_release_directories,omitempty"`
-	TrackExists             string `yaml:"track_exists,omitempty"`
-	TrackNumberPadding      int    `yaml:"track_number_padding,omitempty"`
-
-	ReleaseDirectoryTemplate  string `yaml:"release_directory_template,omitempty"`
-	PlaylistDirectoryTemplate string `yaml:"playlist_directory_template,omitempty"`
-	ChartDirectoryTemplate    string `yaml:"chart_directory_template,omitempty"`
-	LabelDirectoryTemplate    string `yaml:"label_directory_template,omitempty"`
-	ArtistDirectoryTemplate   string `yaml:"artist_directory_template,omitempty"`
-	TrackFileTemplate         string `yaml:"track_file_template,omitempty"`
-	WhitespaceCharacter       string `yaml:"whitespace_character,omitempty"`
-	ArtistsLimit              int    `yaml:"artists_limit,omitempty"`
-	ArtistsShortForm          string `yaml:"artists_short_form,omitempty"`
-	KeySystem                 string `yaml:"key_system,omitempty"`
-
-	CoverSize string `yaml:"cover_size,omitempty"`
-	KeepCover bool   `yaml:"keep_cover,omitempty"`
-	FixTags   bool   `yaml:"fix_tags,omitempty"`
-
-	TagMappings map[string]map[string]string `yaml:"tag_mappings,omitempty"`
-
-	Proxy string `yaml:"proxy,omitempty"`
+	Username        string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password        string `yaml:"password,omitempty" json:"password,omitempty"`
+	Quality         string `yaml:"quality,omitempty" json:"quality,omitempty"`
+	WriteErrorLog   bool   `yaml:"write_error_log,omitempty" json:"write_error_log,omitempty"`
+	LogFormat       string `yaml:"log_format,omitempty" json:"log_format,omitempty"`
+	LogLevel        string `yaml:"log_level,omitempty" json:"log_level,omitempty"`
+	ErrorLogMaxSize string `yaml:"error_log_max_size,omitempty" json:"error_log_max_size,omitempty"`
+	ShowProgress    bool   `yaml:"show_progress,omitempty" json:"show_progress,omitempty"`
+	BatchProgress   bool   `yaml:"batch_progress,omitempty" json:"batch_progress,omitempty"`
+
+	MaxGlobalWorkers   int `yaml:"max_global_workers,omitempty" json:"max_global_workers,omitempty"`
+	MaxDownloadWorkers int `yaml:"max_download_workers,omitempty" json:"max_download_workers,omitempty"`
+	MaxHookWorkers     int `yaml:"max_hook_workers,omitempty" json:"max_hook_workers,omitempty"`
+
+	MaxDownloadSpeed  string  `yaml:"max_download_speed,omitempty" json:"max_download_speed,omitempty"`
+	DownloadSegments  int     `yaml:"download_segments,omitempty" json:"download_segments,omitempty"`
+	APIRateLimit      float64 `yaml:"api_rate_limit,omitempty" json:"api_rate_limit,omitempty"`
+	DownloadRateLimit float64 `yaml:"download_rate_limit,omitempty" json:"download_rate_limit,omitempty"`
+	MonthlyQuota      int     `yaml:"monthly_quota,omitempty" json:"monthly_quota,omitempty"`
+	AccountStrategy   string  `yaml:"account_strategy,omitempty" json:"account_strategy,omitempty"`
+	LazyLogin         bool    `yaml:"lazy_login,omitempty" json:"lazy_login,omitempty"`
+	LoginTimeout      int     `yaml:"login_timeout,omitempty" json:"login_timeout,omitempty"`
+
+	MaxRetries          int     `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`
+	RetryBackoffSeconds int     `yaml:"retry_backoff_seconds,omitempty" json:"retry_backoff_seconds,omitempty"`
+	RetryJitter         float64 `yaml:"retry_jitter,omitempty" json:"retry_jitter,omitempty"`
+
+	DownloadsDirectory      string `yaml:"downloads_directory,omitempty" json:"downloads_directory,omitempty"`
+	SortByContext           bool   `yaml:"sort_by_context,omitempty" json:"sort_by_context,omitempty"`
+	SortByLabel             bool   `yaml:"sort_by_label,omitempty" json:"sort_by_label,omitempty"`
+	ForceReleaseDirectories bool   `yaml:"force_release_directories,omitempty" json:"force_release_directories,omitempty"`
+	WritePlaylist           bool   `yaml:"write_playlist,omitempty" json:"write_playlist,omitempty"`
+	ExportCue               bool   `yaml:"export_cue,omitempty" json:"export_cue,omitempty"`
+	TrackExists             string `yaml:"track_exists,omitempty" json:"track_exists,omitempty"`
+	SkipExisting            bool   `yaml:"skip_existing,omitempty" json:"skip_existing,omitempty"`
+	ResumeDownloads         bool   `yaml:"resume_downloads,omitempty" json:"resume_downloads,omitempty"`
+	VerifyExistingSize      bool   `yaml:"verify_existing_size,omitempty" json:"verify_existing_size,omitempty"`
+	VerifyChecksum          bool   `yaml:"verify_checksum,omitempty" json:"verify_checksum,omitempty"`
+	DownloadArchive         string `yaml:"download_archive,omitempty" json:"download_archive,omitempty"`
+	ISRCIndex               bool   `yaml:"isrc_index,omitempty" json:"isrc_index,omitempty"`
+	CheckDiskSpace          bool   `yaml:"check_disk_space,omitempty" json:"check_disk_space,omitempty"`
+	EstimatedTrackSize      string `yaml:"estimated_track_size,omitempty" json:"estimated_track_size,omitempty"`
+	TrackNumberPadding      int    `yaml:"track_number_padding,omitempty" json:"track_number_padding,omitempty"`
+
+	ReleaseDirectoryTemplate  string `yaml:"release_directory_template,omitempty" json:"release_directory_template,omitempty"`
+	PlaylistDirectoryTemplate string `yaml:"playlist_directory_template,omitempty" json:"playlist_directory_template,omitempty"`
+	ChartDirectoryTemplate    string `yaml:"chart_directory_template,omitempty" json:"chart_directory_template,omitempty"`
+	Top100DirectoryTemplate   string `yaml:"top_100_directory_template,omitempty" json:"top_100_directory_template,omitempty"`
+	LabelDirectoryTemplate    string `yaml:"label_directory_template,omitempty" json:"label_directory_template,omitempty"`
+	ArtistDirectoryTemplate   string `yaml:"artist_directory_template,omitempty" json:"artist_directory_template,omitempty"`
+	TrackFileTemplate         string `yaml:"track_file_template,omitempty" json:"track_file_template,omitempty"`
+	DirectoryTemplate         string `yaml:"directory_template,omitempty" json:"directory_template,omitempty"`
+	WhitespaceCharacter       string `yaml:"whitespace_character,omitempty" json:"whitespace_character,omitempty"`
+	ArtistsLimit              int    `yaml:"artists_limit,omitempty" json:"artists_limit,omitempty"`
+	ArtistsShortForm          string `yaml:"artists_short_form,omitempty" json:"artists_short_form,omitempty"`
+	KeySystem                 string `yaml:"key_system,omitempty" json:"key_system,omitempty"`
+	KeyFormat                 string `yaml:"key_format,omitempty" json:"key_format,omitempty"`
+	BPMFormat                 string `yaml:"bpm_format,omitempty" json:"bpm_format,omitempty"`
+
+	// TrackFilenameTemplate, ReleaseDirnameTemplate and WriteMetadata are
+	// aliases of track_file_template, release_directory_template and
+	// fix_tags, moved there by Parse.
+	TrackFilenameTemplate  string `yaml:"track_filename_template,omitempty" json:"track_filename_template,omitempty"`
+	ReleaseDirnameTemplate string `yaml:"release_dirname_template,omitempty" json:"release_dirname_template,omitempty"`
+	WriteMetadata          *bool  `yaml:"write_metadata,omitempty" json:"write_metadata,omitempty"`
+
+	CoverSize     string `yaml:"cover_size,omitempty" json:"cover_size,omitempty"`
+	KeepCover     bool   `yaml:"keep_cover,omitempty" json:"keep_cover,omitempty"`
+	CoverFilename string `yaml:"cover_filename,omitempty" json:"cover_filename,omitempty"`
+	EmbedArtwork  bool   `yaml:"embed_artwork,omitempty" json:"embed_artwork,omitempty"`
+	FixTags       bool   `yaml:"fix_tags,omitempty" json:"fix_tags,omitempty"`
+
+	TagMappings       map[string]map[string]string `yaml:"tag_mappings,omitempty" json:"tag_mappings,omitempty"`
+	TagFields         []string                     `yaml:"tag_fields,omitempty" json:"tag_fields,omitempty"`
+	TagTemplates      map[string]string            `yaml:"tag_templates,omitempty" json:"tag_templates,omitempty"`
+	ArtistSeparator   string                       `yaml:"artist_separator,omitempty" json:"artist_separator,omitempty"`
+	MultiValueArtists bool                         `yaml:"multi_value_artists,omitempty" json:"multi_value_artists,omitempty"`
+
+	PostDownloadHook string `yaml:"post_download_hook,omitempty" json:"post_download_hook,omitempty"`
+
+	SearchMinConfidence float64 `yaml:"search_min_confidence,omitempty" json:"search_min_confidence,omitempty"`
+
+	Proxy string `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+
+	NotifyWebhook string `yaml:"notify_webhook,omitempty" json:"notify_webhook,omitempty"`
 }
 
 const (
@@ -60,12 +118,54 @@ var (
 		"update",
 	}
 
+	SupportedQualities = []string{
+		"lossless",
+		"high",
+		"medium",
+		"medium-hls",
+	}
+
+	qualityAliases = map[string]string{
+		"flac":    "lossless",
+		"aac-256": "high",
+		"aac-128": "medium",
+	}
+
+	SupportedLogFormats = []string{
+		"text",
+		"json",
+	}
+
+	SupportedLogLevels = []string{
+		"error",
+		"info",
+		"debug",
+	}
+
+	SupportedAccountStrategies = []string{
+		"sticky",
+		"roundrobin",
+	}
+
 	SupportedKeySystems = []string{
 		"standard",
 		"standard-short",
 		"openkey",
 		"camelot",
 	}
+
+	SupportedKeyFormats = []string{
+		"camelot",
+		"openkey",
+		"classical",
+		"beatport",
+		"standard",
+	}
+
+	SupportedBPMFormats = []string{
+		"integer",
+		"decimal",
+	}
 )
 
 func FFMPEGInstalled() bool {
@@ -73,52 +173,117 @@ func FFMPEGInstalled() bool {
 	return err == nil
 }
 
-// Parse reads a single YAML config file
+// NormalizeQuality resolves quality aliases (flac, aac-256, aac-128) to the
+// quality names used by the API.
+func NormalizeQuality(quality string) (string, error) {
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	if alias, ok := qualityAliases[quality]; ok {
+		quality = alias
+	}
+	if !validator.PermittedValue(quality, SupportedQualities...) {
+		return "", fmt.Errorf("invalid quality: %s", quality)
+	}
+	return quality, nil
+}
+
+// configAliases are the keys accepted as aliases, each with the key it
+// stands for. Parse rejects configs that set both.
+var configAliases = [][2]string{
+	{"track_filename_template", "track_file_template"},
+	{"release_dirname_template", "release_directory_template"},
+	{"write_metadata", "fix_tags"},
+}
+
+// Parse reads a single YAML config file, or a JSON one if its name ends in
+// .json, and fills in the defaults. The result must be checked with Validate.
 func Parse(filePath string) (*AppConfig, error) {
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
 	config := AppConfig{
 		Quality:                   "lossless",
 		CoverSize:                 DefaultCoverSize,
+		CoverFilename:             "cover.jpg",
+		EmbedArtwork:              true,
+		ArtistSeparator:           ", ",
 		TrackFileTemplate:         "{number}. {artists} - {name} ({mix_name})",
 		ReleaseDirectoryTemplate:  "[{catalog_number}] {artists} - {name}",
 		PlaylistDirectoryTemplate: "{name} [{created_date}]",
 		ChartDirectoryTemplate:    "{name} [{published_date}]",
+		Top100DirectoryTemplate:   "{name} - {published_date}",
 		LabelDirectoryTemplate:    "{name} [{updated_date}]",
 		ArtistDirectoryTemplate:   "{name}",
 		ArtistsLimit:              3,
 		ArtistsShortForm:          "VA",
 		KeySystem:                 "standard-short",
+		BPMFormat:                 "integer",
 		TrackExists:               "update",
 		TrackNumberPadding:        2,
 		FixTags:                   true,
+		LogFormat:                 "text",
+		LogLevel:                  "info",
+		ErrorLogMaxSize:           "10M",
 		ShowProgress:              true,
+		BatchProgress:             true,
 		MaxGlobalWorkers:          15,
 		MaxDownloadWorkers:        15,
+		MaxHookWorkers:            2,
+		LoginTimeout:              30,
+		DownloadSegments:          1,
+		APIRateLimit:              10,
+		AccountStrategy:           "sticky",
+		MaxRetries:                3,
+		RetryBackoffSeconds:       2,
+		RetryJitter:               0.5,
+		SearchMinConfidence:       0.8,
+		ResumeDownloads:           true,
 	}
 
-	decoder := yaml.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
+	decode := func(v any) error {
+		if strings.ToLower(filepath.Ext(filePath)) == ".json" {
+			return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+		}
+		return yaml.NewDecoder(bytes.NewReader(data)).Decode(v)
+	}
+	if err = decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to decode config file: %w", err)
 	}
+	var keys map[string]any
+	if err = decode(&keys); err != nil {
+		return nil, fmt.Errorf("failed to decode config file: %w", err)
+	}
+	for _, alias := range configAliases {
+		if _, ok := keys[alias[0]]; ok {
+			if _, ok := keys[alias[1]]; ok {
+				return nil, fmt.Errorf("%s and its alias %s are both set", alias[1], alias[0])
+			}
+		}
+	}
 
-	if config.Username == "" || config.Password == "" {
-		return nil, fmt.Errorf("username or password is not provided")
+	if config.Password, err = decryptPassword(config.Password); err != nil {
+		return nil, err
 	}
 
-	if config.Quality == "medium-hls" && !FFMPEGInstalled() {
-		return nil, errors.New("ffmpeg not found")
+	if quality, err := NormalizeQuality(config.Quality); err == nil {
+		config.Quality = quality
 	}
 
-	if config.TagMappings != nil {
-		if err = ValidateTagMappings(config.TagMappings); err != nil {
-			return nil, err
-		}
+	if config.TrackFilenameTemplate != "" {
+		config.TrackFileTemplate = config.TrackFilenameTemplate
+		config.TrackFilenameTemplate = ""
+	}
+	if config.ReleaseDirnameTemplate != "" {
+		config.ReleaseDirectoryTemplate = config.ReleaseDirnameTemplate
+		config.ReleaseDirnameTemplate = ""
+	}
+	if config.WriteMetadata != nil {
+		config.FixTags = *config.WriteMetadata
+		config.WriteMetadata = nil
+	}
 
+	if config.TagMappings != nil {
 		if _, ok := config.TagMappings["flac"]; !ok {
 			config.TagMappings["flac"] = DefaultTagMappings["flac"]
 		}
@@ -130,23 +295,204 @@ func Parse(filePath string) (*AppConfig, error) {
 		config.TagMappings = DefaultTagMappings
 	}
 
-	if !validator.PermittedValue(config.KeySystem, SupportedKeySystems...) {
-		return nil, fmt.Errorf("invalid key system")
+	if size, err := strconv.Atoi(config.CoverSize); err == nil {
+		config.CoverSize = fmt.Sprintf("%dx%d", size, size)
+	}
+
+	return &config, nil
+}
+
+// EnvVariables are the environment variables ApplyEnv reads.
+var EnvVariables = []string{"BEATPORT_USERNAME", "BEATPORT_PASSWORD", "BEATPORT_PROXY"}
+
+// ApplyEnv overrides the credentials and proxy with the BEATPORT_USERNAME,
+// BEATPORT_PASSWORD and BEATPORT_PROXY environment variables, so they don't
+// have to be stored in the config file. Unset or empty variables are ignored.
+// The variables describe one account, so the caller only applies them when
+// a single config file is loaded.
+func (c *AppConfig) ApplyEnv() error {
+	for name, field := range map[string]*string{
+		"BEATPORT_USERNAME": &c.Username,
+		"BEATPORT_PASSWORD": &c.Password,
+		"BEATPORT_PROXY":    &c.Proxy,
+	} {
+		if value := os.Getenv(name); value != "" {
+			*field = value
+		}
 	}
+	// Parse already decrypted the password of the config file, only the
+	// one from the environment may still be encrypted.
+	if password := os.Getenv("BEATPORT_PASSWORD"); password != "" {
+		var err error
+		if c.Password, err = decryptPassword(password); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if config.DownloadsDirectory == "" {
-		return nil, fmt.Errorf("no downloads directory provided")
+// Validate checks every option of the config and returns all problems found
+// joined into a single error, or nil if the config is usable.
+func (c *AppConfig) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
 	}
 
-	if !validator.PermittedValue(config.TrackExists, SupportedTrackExistsOptions...) {
-		return nil, fmt.Errorf("invalid track exists behavior")
+	check(c.Username != "", "username is not provided")
+	check(c.Password != "", "password is not provided")
+	check(c.DownloadsDirectory != "", "no downloads directory provided")
+	// The API has no downbeat data to build a beatgrid from, so export_cue
+	// is rejected instead of being silently ignored.
+	check(!c.ExportCue, "export_cue is not supported: Beatport provides no beatgrid data")
+
+	check(validator.PermittedValue(c.Quality, SupportedQualities...),
+		"invalid quality: %s (expected one of %s)", c.Quality, strings.Join(SupportedQualities, ", "))
+	if c.Quality == "medium-hls" && !FFMPEGInstalled() {
+		errs = append(errs, errors.New("ffmpeg not found, required for medium-hls quality"))
 	}
 
-	if config.TrackNumberPadding > 10 || config.TrackNumberPadding < 0 {
-		return nil, fmt.Errorf("invalid track number padding")
+	if err := ValidateTagMappings(c.TagMappings); err != nil {
+		errs = append(errs, err)
+	}
+	if err := ValidateTagTemplates(c.TagTemplates); err != nil {
+		errs = append(errs, err)
+	}
+	for _, t := range []struct {
+		option   string
+		template string
+		keywords []string
+	}{
+		{"track_file_template", c.TrackFileTemplate, beatport.TrackTemplateKeywords},
+		{"directory_template", c.DirectoryTemplate, beatport.TrackTemplateKeywords},
+		{"release_directory_template", c.ReleaseDirectoryTemplate, beatport.ReleaseTemplateKeywords},
+		{"playlist_directory_template", c.PlaylistDirectoryTemplate, beatport.PlaylistTemplateKeywords},
+		{"chart_directory_template", c.ChartDirectoryTemplate, beatport.ChartTemplateKeywords},
+		{"top_100_directory_template", c.Top100DirectoryTemplate, beatport.ChartTemplateKeywords},
+		{"label_directory_template", c.LabelDirectoryTemplate, beatport.LabelTemplateKeywords},
+		{"artist_directory_template", c.ArtistDirectoryTemplate, beatport.ArtistTemplateKeywords},
+	} {
+		if err := beatport.CheckTemplate(t.template, t.keywords); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", t.option, err))
+		}
 	}
+	for _, field := range c.TagFields {
+		check(validator.PermittedValue(field, SupportedTagMappingFields...), "invalid tag field '%s'", field)
+	}
+	check(c.ArtistSeparator != "", "invalid artist separator: empty")
 
-	return &config, nil
+	check(validator.PermittedValue(c.LogFormat, SupportedLogFormats...),
+		"invalid log format: %s (expected one of %s)", c.LogFormat, strings.Join(SupportedLogFormats, ", "))
+	check(validator.PermittedValue(c.LogLevel, SupportedLogLevels...),
+		"invalid log level: %s (expected one of %s)", c.LogLevel, strings.Join(SupportedLogLevels, ", "))
+	check(validator.PermittedValue(c.KeySystem, SupportedKeySystems...),
+		"invalid key system: %s (expected one of %s)", c.KeySystem, strings.Join(SupportedKeySystems, ", "))
+	check(c.KeyFormat == "" || validator.PermittedValue(c.KeyFormat, SupportedKeyFormats...),
+		"invalid key format: %s (expected one of %s)", c.KeyFormat, strings.Join(SupportedKeyFormats, ", "))
+	check(validator.PermittedValue(c.BPMFormat, SupportedBPMFormats...),
+		"invalid bpm format: %s (expected one of %s)", c.BPMFormat, strings.Join(SupportedBPMFormats, ", "))
+	check(validator.PermittedValue(c.TrackExists, SupportedTrackExistsOptions...),
+		"invalid track exists behavior: %s (expected one of %s)", c.TrackExists, strings.Join(SupportedTrackExistsOptions, ", "))
+	check(c.TrackNumberPadding >= 0 && c.TrackNumberPadding <= 10,
+		"invalid track number padding: %d (expected 0 to 10)", c.TrackNumberPadding)
+
+	var coverWidth, coverHeight int
+	n, _ := fmt.Sscanf(c.CoverSize, "%dx%d", &coverWidth, &coverHeight)
+	check(n == 2 && coverWidth > 0 && coverHeight > 0,
+		"invalid cover size: %s (expected e.g. 1400x1400)", c.CoverSize)
+	check(c.CoverFilename != "" && c.CoverFilename != "." && c.CoverFilename != ".." &&
+		!strings.ContainsAny(c.CoverFilename, `/\`),
+		"invalid cover filename: %s (expected a file name like folder.jpg)", c.CoverFilename)
+
+	check(c.MaxGlobalWorkers >= 1, "invalid max global workers: %d (expected at least 1)", c.MaxGlobalWorkers)
+	check(c.MaxDownloadWorkers >= 1, "invalid max download workers: %d (expected at least 1)", c.MaxDownloadWorkers)
+	check(c.MaxHookWorkers >= 1, "invalid max hook workers: %d (expected at least 1)", c.MaxHookWorkers)
+
+	if _, err := ParseByteSize(c.MaxDownloadSpeed); err != nil {
+		errs = append(errs, fmt.Errorf("invalid max download speed: %w", err))
+	}
+	if _, err := ParseByteSize(c.EstimatedTrackSize); err != nil {
+		errs = append(errs, fmt.Errorf("invalid estimated track size: %w", err))
+	}
+	if _, err := ParseByteSize(c.ErrorLogMaxSize); err != nil {
+		errs = append(errs, fmt.Errorf("invalid error log max size: %w", err))
+	}
+
+	check(c.DownloadSegments >= 0 && c.DownloadSegments <= 16,
+		"invalid download segments: %d (expected 0 to 16)", c.DownloadSegments)
+	check(c.APIRateLimit >= 0, "invalid api rate limit: %v (expected 0 or more)", c.APIRateLimit)
+	check(c.DownloadRateLimit >= 0, "invalid download rate limit: %v (expected 0 or more)", c.DownloadRateLimit)
+	check(validator.PermittedValue(c.AccountStrategy, SupportedAccountStrategies...),
+		"invalid account strategy: %s (expected one of %s)", c.AccountStrategy, strings.Join(SupportedAccountStrategies, ", "))
+	check(c.MonthlyQuota >= 0, "invalid monthly quota: %d (expected 0 or more)", c.MonthlyQuota)
+
+	check(c.MaxRetries >= 0, "invalid max retries: %d (expected 0 or more)", c.MaxRetries)
+	check(c.LoginTimeout >= 1, "invalid login timeout: %d (expected at least 1 second)", c.LoginTimeout)
+	check(c.RetryBackoffSeconds >= 0, "invalid retry backoff: %d (expected 0 or more)", c.RetryBackoffSeconds)
+	check(c.RetryJitter >= 0 && c.RetryJitter <= 1, "invalid retry jitter: %v (expected 0 to 1)", c.RetryJitter)
+	check(c.SearchMinConfidence >= 0 && c.SearchMinConfidence <= 1,
+		"invalid search min confidence: %v (expected 0 to 1)", c.SearchMinConfidence)
+
+	if c.Proxy != "" {
+		if _, err := ParseProxyUrl(c.Proxy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.NotifyWebhook != "" {
+		webhook, err := url.Parse(c.NotifyWebhook)
+		check(err == nil && (webhook.Scheme == "http" || webhook.Scheme == "https") && webhook.Host != "",
+			"invalid notify webhook: %s (expected an http or https url)", c.NotifyWebhook)
+	}
+
+	return errors.Join(errs...)
+}
+
+// ParseProxyUrl parses and validates an http, https or socks5 proxy URL.
+func ParseProxyUrl(proxyUrl string) (*url.URL, error) {
+	proxy, err := url.Parse(proxyUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy url: unsupported scheme '%s' (expected http, https or socks5)", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url: missing host")
+	}
+	return proxy, nil
+}
+
+// ParseByteSize parses a size in bytes with an optional decimal unit suffix,
+// e.g. "500000", "500K", "5M" or "1.5MB". An empty string is zero.
+func ParseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+
+	value = strings.TrimSuffix(value, "B")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1e3
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1e6
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	return int64(number * multiplier), nil
 }
 
 // ParseMultiple tries multiple YAML files in order and returns the first valid one
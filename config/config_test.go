@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unspok3n/beatportdl/internal/beatport"
+)
+
+func TestParseJSON(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "beatportdl-config.yml")
+	jsonPath := filepath.Join(dir, "beatportdl-config.json")
+	os.WriteFile(yamlPath, []byte(`username: user
+password: pass
+quality: flac
+downloads_directory: /music
+cover_size: "600"
+embed_artwork: false
+max_download_workers: 4
+tag_mappings:
+  flac:
+    track_name: TITLE
+`), 0600)
+	os.WriteFile(jsonPath, []byte(`{
+  "username": "user",
+  "password": "pass",
+  "quality": "flac",
+  "downloads_directory": "/music",
+  "cover_size": "600",
+  "embed_artwork": false,
+  "max_download_workers": 4,
+  "tag_mappings": {"flac": {"track_name": "TITLE"}}
+}`), 0600)
+
+	yamlConfig, err := Parse(yamlPath)
+	if err != nil {
+		t.Fatalf("Parse(yaml) failed: %v", err)
+	}
+	jsonConfig, err := Parse(jsonPath)
+	if err != nil {
+		t.Fatalf("Parse(json) failed: %v", err)
+	}
+	if !reflect.DeepEqual(yamlConfig, jsonConfig) {
+		t.Errorf("JSON config differs from YAML config:\n%+v\n%+v", jsonConfig, yamlConfig)
+	}
+	if yamlConfig.EmbedArtwork {
+		t.Error("Parse() ignored embed_artwork: false")
+	}
+
+	if err := jsonConfig.Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+
+	os.WriteFile(jsonPath, []byte(`{"username": "user", "password": "pass", "quality": "ultra"}`), 0600)
+	jsonConfig, err = Parse(jsonPath)
+	if err != nil {
+		t.Fatalf("Parse(json) failed: %v", err)
+	}
+	if err := jsonConfig.Validate(); err == nil {
+		t.Error("Validate() accepted an invalid quality")
+	}
+}
+
+func TestParseTemplateAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-config.yml")
+	os.WriteFile(path, []byte(`track_filename_template: "{track_number:02} {title}"
+release_dirname_template: "{catalog_number|NOCAT} {name}"
+`), 0600)
+
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TrackFileTemplate != "{track_number:02} {title}" {
+		t.Errorf("track_file_template = %q, expected track_filename_template", cfg.TrackFileTemplate)
+	}
+	if cfg.ReleaseDirectoryTemplate != "{catalog_number|NOCAT} {name}" {
+		t.Errorf("release_directory_template = %q, expected release_dirname_template", cfg.ReleaseDirectoryTemplate)
+	}
+	if err := beatport.CheckTemplate(cfg.TrackFileTemplate, beatport.TrackTemplateKeywords); err != nil {
+		t.Errorf("CheckTemplate() rejected track_filename_template: %v", err)
+	}
+}
+
+func TestParseAliasConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"beatportdl-config.yml", "track_file_template: \"{name}\"\ntrack_filename_template: \"{title}\"\n"},
+		{"beatportdl-config.yml", "release_directory_template: \"{name}\"\nrelease_dirname_template: \"{name}\"\n"},
+		{"beatportdl-config.json", `{"fix_tags": true, "write_metadata": false}`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.name)
+		os.WriteFile(path, []byte(tt.content), 0600)
+		if _, err := Parse(path); err == nil || !strings.Contains(err.Error(), "are both set") {
+			t.Errorf("Parse(%q) = %v, expected an error for a key and its alias", tt.content, err)
+		}
+	}
+}
+
+func TestParseWriteMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-config.yml")
+	os.WriteFile(path, []byte("write_metadata: false\n"), 0600)
+
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.FixTags {
+		t.Error("fix_tags enabled with write_metadata: false")
+	}
+	if cfg.WriteMetadata != nil {
+		t.Error("write_metadata left set after moving it to fix_tags")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beatportdl-config.yml")
+	os.WriteFile(path, []byte("username: user\npassword: pass\n"), 0600)
+	t.Setenv("BEATPORT_USERNAME", "")
+	t.Setenv("BEATPORT_PASSWORD", "secret")
+
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "pass" {
+		t.Errorf("Parse() password = %q, expected the config file's, the environment is applied by ApplyEnv", cfg.Password)
+	}
+	if !cfg.EmbedArtwork {
+		t.Error("Parse() didn't enable embed_artwork by default")
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "user" || cfg.Password != "secret" {
+		t.Errorf("ApplyEnv() = %q, %q, expected user and the password from the environment", cfg.Username, cfg.Password)
+	}
+}
+
+func TestParseEncryptedPassword(t *testing.T) {
+	key, _ := NewPasswordKey()
+	t.Setenv(PasswordKeyEnv, key)
+	decodedKey, err := PasswordKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := EncryptPassword("secret", decodedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "beatportdl-config.yml")
+	os.WriteFile(path, []byte("username: user\npassword: "+encrypted+"\n"), 0600)
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "secret" {
+		t.Errorf("Parse() password = %q, expected the decrypted password", cfg.Password)
+	}
+
+	// A decrypted password that looks encrypted is only decrypted once.
+	encrypted, err = EncryptPassword("enc:secret", decodedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("username: user\npassword: "+encrypted+"\n"), 0600)
+	t.Setenv("BEATPORT_PASSWORD", "")
+	cfg, err = Parse(path)
+	if err == nil {
+		err = cfg.ApplyEnv()
+	}
+	if err != nil || cfg.Password != "enc:secret" {
+		t.Errorf("Parse() and ApplyEnv() = %q, %v, expected enc:secret", cfg.Password, err)
+	}
+
+	t.Setenv(PasswordKeyEnv, "")
+	if _, err := Parse(path); !errors.Is(err, ErrPasswordKeyMissing) {
+		t.Errorf("Parse() = %v, expected an error about the missing key", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	config := &AppConfig{
+		Quality:            "lossless",
+		CoverSize:          DefaultCoverSize,
+		CoverFilename:      "covers/front.jpg",
+		KeySystem:          "standard",
+		TrackExists:        "update",
+		AccountStrategy:    "sticky",
+		MaxGlobalWorkers:   0,
+		MaxDownloadWorkers: 1,
+		Proxy:              "ftp://proxy",
+		TrackFileTemplate:  "{number:02} {titel}",
+		ExportCue:          true,
+	}
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate() accepted an invalid config")
+	}
+	for _, problem := range []string{"username", "password", "downloads directory", "max global workers", "cover filename", "proxy", "track_file_template", "export_cue"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Validate() error doesn't mention %s:\n%v", problem, err)
+		}
+	}
+}
+
+func TestValidateTagTemplates(t *testing.T) {
+	valid := map[string]string{"COMMENT": "Beatport {track_id} | {label}", "WWW": "{track_url}"}
+	if err := ValidateTagTemplates(valid); err != nil {
+		t.Errorf("ValidateTagTemplates() failed: %v", err)
+	}
+	invalid := map[string]string{"COMMENT": "{track_id} {labels}"}
+	if err := ValidateTagTemplates(invalid); err == nil || !strings.Contains(err.Error(), "{labels}") {
+		t.Errorf("ValidateTagTemplates() = %v, expected an error about {labels}", err)
+	}
+}
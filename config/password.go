@@ -0,0 +1,96 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PasswordKeyEnv is the environment variable holding the key of encrypted
+// passwords, 32 bytes encoded in base64.
+const PasswordKeyEnv = "BEATPORTDL_KEY"
+
+// encryptedPasswordPrefix marks an encrypted password in the config file.
+const encryptedPasswordPrefix = "enc:"
+
+var ErrPasswordKeyMissing = errors.New(PasswordKeyEnv + " is not set")
+
+// PasswordKey returns the key of encrypted passwords from the environment.
+func PasswordKey() ([]byte, error) {
+	encoded := os.Getenv(PasswordKeyEnv)
+	if encoded == "" {
+		return nil, ErrPasswordKeyMissing
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid %s: expected 32 bytes in base64", PasswordKeyEnv)
+	}
+	return key, nil
+}
+
+// NewPasswordKey returns a random key for encrypted passwords, encoded for
+// the environment variable.
+func NewPasswordKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// EncryptPassword encrypts password with AES-GCM into the form read from the
+// config file.
+func EncryptPassword(password string, key []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(password), nil)
+	return encryptedPasswordPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptPassword returns the plaintext of a password encrypted with
+// EncryptPassword. Passwords without the prefix are returned unchanged.
+func decryptPassword(password string) (string, error) {
+	encoded, found := strings.CutPrefix(password, encryptedPasswordPrefix)
+	if !found {
+		return password, nil
+	}
+	key, err := PasswordKey()
+	if err != nil {
+		return "", fmt.Errorf("decrypt password: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decrypt password: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("decrypt password: too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt password: wrong %s or corrupted password", PasswordKeyEnv)
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
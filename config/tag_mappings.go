@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"unspok3n/beatportdl/internal/beatport"
 	"unspok3n/beatportdl/internal/validator"
 )
 
@@ -20,6 +23,21 @@ func ValidateTagMappings(m map[string]map[string]string) error {
 	return nil
 }
 
+// ValidateTagTemplates checks that the tag templates only use the track
+// template keywords and the tag mapping fields as placeholders.
+func ValidateTagTemplates(templates map[string]string) error {
+	keywords := slices.Concat(beatport.TrackTemplateKeywords, SupportedTagMappingFields)
+	for tag, template := range templates {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("invalid tag template: empty tag name")
+		}
+		if err := beatport.CheckTemplate(template, keywords); err != nil {
+			return fmt.Errorf("invalid tag template %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
 var (
 	SupportedTagMappingFormats = []string{
 		"flac",
@@ -66,6 +84,7 @@ var (
 		"flac": {
 			"track_name":              "TITLE",
 			"track_artists":           "ARTIST",
+			"track_remixers":          "REMIXER",
 			"track_number":            "TRACKNUMBER",
 			"track_subgenre_or_genre": "GENRE",
 			"track_key":               "KEY",
@@ -80,13 +99,14 @@ var (
 			"release_label":          "LABEL",
 		},
 		"m4a": {
-			"track_name":    "TITLE",
-			"track_artists": "ARTIST",
-			"track_number":  "TRACKNUMBER",
-			"track_genre":   "GENRE",
-			"track_key":     "KEY",
-			"track_bpm":     "BPM",
-			"track_isrc":    "ISRC",
+			"track_name":     "TITLE",
+			"track_artists":  "ARTIST",
+			"track_remixers": "REMIXER",
+			"track_number":   "TRACKNUMBER",
+			"track_genre":    "GENRE",
+			"track_key":      "KEY",
+			"track_bpm":      "BPM",
+			"track_isrc":     "ISRC",
 
 			"release_name":           "ALBUM",
 			"release_artists":        "ALBUMARTIST",
@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -8,21 +9,45 @@ import (
 )
 
 type Artist struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	Slug string `json:"slug"`
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Store Store  `json:"store"`
 }
 
 type Artists []Artist
 
+// ArtistTemplateKeywords are the keywords of artist directory templates.
+var ArtistTemplateKeywords = []string{
+	"id",
+	"name",
+	"slug",
+}
+
 func (a *Artist) DirectoryName(n NamingPreferences) string {
-	templateValues := map[string]string{
+	directoryName := ParseTemplate(n.Template, a.templateValues())
+	return SanitizePath(directoryName, n.Whitespace)
+}
+
+func (a *Artist) templateValues() map[string]string {
+	return map[string]string{
 		"id":   strconv.Itoa(int(a.ID)),
 		"name": SanitizeForPath(a.Name),
 		"slug": a.Slug,
 	}
-	directoryName := ParseTemplate(n.Template, templateValues)
-	return SanitizePath(directoryName, n.Whitespace)
+}
+
+func (a *Artist) StoreUrl() string {
+	return storeUrl(a.ID, "artist", a.Slug, a.Store)
+}
+
+// Names returns the names of the artists in order.
+func (a *Artists) Names() []string {
+	names := make([]string, len(*a))
+	for i, artist := range *a {
+		names[i] = artist.Name
+	}
+	return names
 }
 
 func (a *Artists) Display(limit int, shortForm string) string {
@@ -37,8 +62,9 @@ func (a *Artists) Display(limit int, shortForm string) string {
 	return artistsString
 }
 
-func (b *Beatport) GetArtist(id int64) (*Artist, error) {
+func (b *Beatport) GetArtist(ctx context.Context, id int64) (*Artist, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/artists/%d/", id),
 		nil,
@@ -55,8 +81,9 @@ func (b *Beatport) GetArtist(id int64) (*Artist, error) {
 	return response, nil
 }
 
-func (b *Beatport) GetArtistTracks(id int64, page int, params string) (*Paginated[Track], error) {
+func (b *Beatport) GetArtistTracks(ctx context.Context, id int64, page int, params string) (*Paginated[Track], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/artists/%d/tracks/?page=%d&%s", id, page, params),
 		nil,
@@ -75,3 +102,25 @@ func (b *Beatport) GetArtistTracks(id int64, page int, params string) (*Paginate
 	}
 	return &response, nil
 }
+
+func (b *Beatport) GetArtistReleases(ctx context.Context, id int64, page int, params string) (*Paginated[Release], error) {
+	res, err := b.fetch(
+		ctx,
+		"GET",
+		fmt.Sprintf("/catalog/artists/%d/releases/?page=%d&%s", id, page, params),
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response Paginated[Release]
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	for i := range response.Results {
+		response.Results[i].Store = b.store
+	}
+	return &response, nil
+}
@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"sync"
 	"time"
 )
@@ -18,12 +20,15 @@ const (
 	tokenEndpoint = "/auth/o/token/"
 	authEndpoint  = "/auth/o/authorize/?client_id=" + clientId + "&response_type=code"
 	loginEndpoint = "/auth/login/"
+
+	introspectEndpoint = "/auth/o/introspect/"
 )
 
 var (
 	ErrInvalidAuthorizationCode = errors.New("invalid authorization code")
 	ErrInvalidSessionCookie     = errors.New("invalid session cookie")
 	ErrLoginIDMismatch          = errors.New("login id does not match")
+	ErrReloginFailed            = errors.New("session rejected and login failed")
 )
 
 type Auth struct {
@@ -32,6 +37,11 @@ type Auth struct {
 	tokenPair *tokenPair
 	cacheFile string
 	mutex     sync.RWMutex
+
+	// rejectedToken is the last token a relogin failed for, and reloginErr
+	// its error, returned to the other requests rejected with that token.
+	rejectedToken string
+	reloginErr    error
 }
 
 type tokenPair struct {
@@ -73,6 +83,10 @@ func (a *Auth) LoadCache() error {
 }
 
 func (a *Auth) WriteCache() error {
+	if a.cacheFile == "" {
+		return nil
+	}
+
 	data, err := json.MarshalIndent(a.tokenPair, "", " ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal tokenPair: %w", err)
@@ -83,62 +97,141 @@ func (a *Auth) WriteCache() error {
 		return fmt.Errorf("could not create folder for cache file: %w", err)
 	}
 
-	if err := os.WriteFile(a.cacheFile, data, 0600); err != nil {
+	// The token is written to a new file that is renamed over the cache, so
+	// the cache always has 0600 permissions, even if it existed before.
+	file, err := os.CreateTemp(filepath.Dir(a.cacheFile), filepath.Base(a.cacheFile)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write token to cache: %w", err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), a.cacheFile)
+	}
+	if err != nil {
+		os.Remove(file.Name())
 		return fmt.Errorf("failed to write token to cache: %w", err)
 	}
 
 	return nil
 }
 
-func (a *Auth) Check(inst *Beatport) error {
-	currentTime := time.Now().Unix()
+func (a *Auth) Check(ctx context.Context, inst *Beatport) error {
 	a.mutex.RLock()
-	tokenExpirationTime := a.tokenPair.IssuedAt + a.tokenPair.ExpiresIn
+	expired := a.expired()
 	a.mutex.RUnlock()
-	if currentTime+300 >= tokenExpirationTime {
-		a.mutex.Lock()
-		fmt.Println("Refreshing token")
-		if _, err := a.refresh(inst); err != nil {
-			if err = a.Init(inst); err != nil {
-				a.mutex.Unlock()
-				return fmt.Errorf("invalid token and authorization error: %w", err)
-			}
+	if !expired {
+		return nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	// Workers that found the token expired at the same time wait here for
+	// the first one to refresh it, and then use the new token.
+	if !a.expired() {
+		return nil
+	}
+	// Accounts that log in lazily have no token until their first request.
+	if a.tokenPair == nil {
+		return a.Init(ctx, inst)
+	}
+	fmt.Println("Refreshing token")
+	if _, err := a.refresh(ctx, inst); err != nil {
+		if err = a.Init(ctx, inst); err != nil {
+			return fmt.Errorf("invalid token and authorization error: %w", err)
 		}
-		a.mutex.Unlock()
 	}
 	return nil
 }
 
+func (a *Auth) accessToken() string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.tokenPair == nil {
+		return ""
+	}
+	return a.tokenPair.AccessToken
+}
+
 func (a *Auth) Invalidate() {
 	a.mutex.Lock()
 	a.tokenPair.IssuedAt = 0
 	a.mutex.Unlock()
 }
 
-func (a *Auth) Init(inst *Beatport) error {
+// relogin logs in again with the username and password after the API
+// rejected accessToken with a 401, e.g. because the session was revoked.
+// Requests rejected with the same token at the same time share one login:
+// the others wait for it and then use its token, or get its error.
+func (a *Auth) relogin(ctx context.Context, inst *Beatport, accessToken string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.tokenPair != nil && a.tokenPair.AccessToken != accessToken {
+		return nil
+	}
+	if a.rejectedToken == accessToken {
+		return a.reloginErr
+	}
+	fmt.Println("Session rejected, logging in again")
+	if err := a.authenticate(ctx, inst); err != nil {
+		a.rejectedToken = accessToken
+		a.reloginErr = fmt.Errorf("%w: %w", ErrReloginFailed, err)
+		return a.reloginErr
+	}
+	return nil
+}
+
+func (a *Auth) Init(ctx context.Context, inst *Beatport) error {
+	if a.cacheFile != "" && a.tokenPair == nil {
+		if err := a.LoadCache(); err == nil {
+			if !a.expired() {
+				return nil
+			}
+			if _, err := a.refresh(ctx, inst); err == nil {
+				return nil
+			}
+		}
+		a.tokenPair = nil
+	}
+
 	fmt.Println("Logging in")
-	sessionId, err := a.login(inst)
+	return a.authenticate(ctx, inst)
+}
+
+// authenticate logs in with the username and password and issues a new
+// token, without trying the cached or current token first.
+func (a *Auth) authenticate(ctx context.Context, inst *Beatport) error {
+	sessionId, err := a.login(ctx, inst)
 	if err != nil {
-		return fmt.Errorf("login: %v", err)
+		return fmt.Errorf("login: %w", err)
 	}
-	authorizationCode, err := a.authorize(inst, sessionId)
+	authorizationCode, err := a.authorize(ctx, inst, sessionId)
 	if err != nil {
-		return fmt.Errorf("authorize: %v", err)
+		return fmt.Errorf("authorize: %w", err)
 	}
-	if err := a.issue(inst, authorizationCode); err != nil {
-		return fmt.Errorf("issue token: %v", err)
+	if err := a.issue(ctx, inst, authorizationCode); err != nil {
+		return fmt.Errorf("issue token: %w", err)
 	}
 	return nil
 }
 
-func (a *Auth) refresh(inst *Beatport) (*tokenPair, error) {
+func (a *Auth) expired() bool {
+	if a.tokenPair == nil {
+		return true
+	}
+	return time.Now().Unix()+300 >= a.tokenPair.IssuedAt+a.tokenPair.ExpiresIn
+}
+
+func (a *Auth) refresh(ctx context.Context, inst *Beatport) (*tokenPair, error) {
 	payload := map[string]string{
 		"client_id":     clientId,
 		"refresh_token": a.tokenPair.RefreshToken,
 		"grant_type":    "refresh_token",
 	}
 
-	res, err := inst.fetch("POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
+	res, err := inst.fetch(ctx, "POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +251,7 @@ func (a *Auth) refresh(inst *Beatport) (*tokenPair, error) {
 	return response, nil
 }
 
-func (a *Auth) issue(inst *Beatport, code string) error {
+func (a *Auth) issue(ctx context.Context, inst *Beatport, code string) error {
 	payload := map[string]string{
 		"client_id": clientId,
 	}
@@ -172,7 +265,7 @@ func (a *Auth) issue(inst *Beatport, code string) error {
 		payload["password"] = a.password
 	}
 
-	res, err := inst.fetch("POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
+	res, err := inst.fetch(ctx, "POST", tokenEndpoint, payload, "application/x-www-form-urlencoded")
 	if err != nil {
 		return err
 	}
@@ -192,10 +285,10 @@ func (a *Auth) issue(inst *Beatport, code string) error {
 	return nil
 }
 
-func (a *Auth) authorize(inst *Beatport, sessionId string) (string, error) {
-	inst.headers["cookie"] = fmt.Sprintf("sessionid=%s", sessionId)
-	res, err := inst.fetch("GET", authEndpoint, nil, "")
-	delete(inst.headers, "cookie")
+func (a *Auth) authorize(ctx context.Context, inst *Beatport, sessionId string) (string, error) {
+	res, err := inst.fetchWithHeaders(ctx, "GET", authEndpoint, map[string]string{
+		"cookie": fmt.Sprintf("sessionid=%s", sessionId),
+	})
 	if err != nil {
 		return "", err
 	}
@@ -215,13 +308,13 @@ func (a *Auth) authorize(inst *Beatport, sessionId string) (string, error) {
 	return "", ErrInvalidAuthorizationCode
 }
 
-func (a *Auth) login(inst *Beatport) (string, error) {
+func (a *Auth) login(ctx context.Context, inst *Beatport) (string, error) {
 	payload := map[string]string{
 		"username": a.username,
 		"password": a.password,
 	}
 
-	res, err := inst.fetch("POST", loginEndpoint, payload, "application/json")
+	res, err := inst.fetch(ctx, "POST", loginEndpoint, payload, "application/json")
 	if err != nil {
 		return "", err
 	}
@@ -241,3 +334,25 @@ func (a *Auth) loginId() string {
 	hashBytes := hash.Sum(nil)
 	return hex.EncodeToString(hashBytes)
 }
+
+// AccountInfo is what the API tells about the account a token belongs to.
+// Subscription is empty for accounts without a streaming subscription.
+type AccountInfo struct {
+	UserID       int64  `json:"user_id"`
+	Username     string `json:"username"`
+	Subscription string `json:"subscription"`
+}
+
+// AccountInfo returns the account and subscription of the current token.
+func (b *Beatport) AccountInfo(ctx context.Context) (*AccountInfo, error) {
+	res, err := b.fetch(ctx, "GET", introspectEndpoint, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var info AccountInfo
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,101 @@
+package beatport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthWriteCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "account.yml.token.json")
+	if err := os.WriteFile(cacheFile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := NewAuth("user", "pass", cacheFile)
+	auth.tokenPair = &tokenPair{AccessToken: "access", RefreshToken: "refresh", LoginID: auth.loginId()}
+	if err := auth.WriteCache(); err != nil {
+		t.Fatalf("WriteCache() failed: %v", err)
+	}
+
+	if info, err := os.Stat(cacheFile); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Cache file permissions %v, expected 0600", info.Mode().Perm())
+	}
+
+	loaded := NewAuth("user", "pass", cacheFile)
+	if err := loaded.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() failed: %v", err)
+	}
+	if loaded.tokenPair.RefreshToken != "refresh" {
+		t.Errorf("Loaded refresh token %q, expected refresh", loaded.tokenPair.RefreshToken)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestAuthRelogin(t *testing.T) {
+	var logins atomic.Int32
+	respond := func(status int, header http.Header, body string) *http.Response {
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	auth := NewAuth("user", "pass", "")
+	auth.tokenPair = &tokenPair{AccessToken: "revoked", ExpiresIn: 3600, IssuedAt: time.Now().Unix()}
+	b := New(StoreBeatport, "", auth)
+	b.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		authorize := strings.HasSuffix(req.URL.Path, "/auth/o/authorize/")
+		if cookie := req.Header.Get("Cookie"); authorize != (cookie == "sessionid=session") {
+			t.Errorf("%s sent with cookie %q", req.URL.Path, cookie)
+		}
+		switch {
+		case strings.HasSuffix(req.URL.Path, loginEndpoint):
+			return respond(http.StatusOK, http.Header{"Set-Cookie": {"sessionid=session"}}, "{}"), nil
+		case authorize:
+			return respond(http.StatusFound, http.Header{"Location": {"https://www.beatport.com/?code=code"}}, ""), nil
+		case strings.HasSuffix(req.URL.Path, tokenEndpoint):
+			logins.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return respond(http.StatusOK, nil, `{"access_token": "new", "expires_in": 3600}`), nil
+		case req.Header.Get("Authorization") == "Bearer new":
+			return respond(http.StatusOK, nil, "{}"), nil
+		default:
+			return respond(http.StatusUnauthorized, nil, "{}"), nil
+		}
+	})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := b.fetch(context.Background(), "GET", "/catalog/tracks/1/", nil, "")
+			if err != nil {
+				t.Errorf("fetch() failed: %v", err)
+				return
+			}
+			res.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if n := logins.Load(); n != 1 {
+		t.Errorf("Logged in %d times, expected once", n)
+	}
+}
@@ -2,12 +2,16 @@ package beatport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync/atomic"
 	"time"
+	"unspok3n/beatportdl/internal/ratelimit"
 )
 
 const (
@@ -19,14 +23,40 @@ type Beatport struct {
 	store   Store
 	client  *http.Client
 	headers map[string]string
-	auth    *Auth
+	auth    atomic.Pointer[Auth]
+	proxy   atomic.Pointer[url.URL]
+	limiter *ratelimit.Limiter
+
+	onRateLimited func(delay time.Duration)
+	onRequest     func(method, url string, status int)
 }
 
+const (
+	// maxRateLimitRetries is the number of times a request answered with 429
+	// is retried after waiting for the delay the server asked for.
+	maxRateLimitRetries = 3
+	// MaxRetryAfter caps the delay requested by a Retry-After header.
+	MaxRetryAfter = 2 * time.Minute
+)
+
 type FetcherError struct {
 	Detail *string `json:"detail,omitempty"`
 	Error  *string `json:"error,omitempty"`
 }
 
+// StatusError is returned when the API responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+	Detail     string
+}
+
+func (e *StatusError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("request failed with status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("request failed with status code: %d - %s", e.StatusCode, e.Detail)
+}
+
 type Paginated[T any] struct {
 	Next     *string `json:"next"`
 	Previous *string `json:"previous"`
@@ -38,20 +68,14 @@ type Paginated[T any] struct {
 
 func New(store Store, proxyUrl string, auth *Auth) *Beatport {
 	transport := &http.Transport{}
-	if proxyUrl != "" {
-		proxyURL, _ := url.Parse(proxyUrl)
-		proxy := http.ProxyURL(proxyURL)
-		transport.Proxy = proxy
-	}
 	headers := map[string]string{
 		"accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
 		"accept-language": "en-US,en;q=0.9",
 		"cache-control":   "max-age=0",
 		"user-agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36",
 	}
-	f := Beatport{
+	f := &Beatport{
 		store: store,
-		auth:  auth,
 		client: &http.Client{
 			Timeout:   time.Duration(40) * time.Second,
 			Transport: transport,
@@ -61,16 +85,84 @@ func New(store Store, proxyUrl string, auth *Auth) *Beatport {
 		},
 		headers: headers,
 	}
-	return &f
+	transport.Proxy = func(*http.Request) (*url.URL, error) {
+		return f.proxy.Load(), nil
+	}
+	f.SetProxy(proxyUrl)
+	f.auth.Store(auth)
+	return f
+}
+
+func (b *Beatport) Store() Store {
+	return b.store
+}
+
+// SetProxy routes subsequent requests through proxyUrl, or connects
+// directly if it's empty.
+func (b *Beatport) SetProxy(proxyUrl string) {
+	var proxy *url.URL
+	if proxyUrl != "" {
+		proxy, _ = url.Parse(proxyUrl)
+	}
+	b.proxy.Store(proxy)
+}
+
+// SetAuth makes subsequent requests use auth, so the client can switch to
+// another account without being recreated.
+func (b *Beatport) SetAuth(auth *Auth) {
+	b.auth.Store(auth)
+}
+
+// SetRateLimiter makes every API request wait for the limiter, which may be
+// shared between several clients. A 429 response throttles the limiter, so
+// all clients sharing it pause for the delay the server asked for.
+func (b *Beatport) SetRateLimiter(limiter *ratelimit.Limiter) {
+	b.limiter = limiter
+}
+
+// OnRateLimited sets a function called with the delay before a request
+// answered with 429 is retried.
+func (b *Beatport) OnRateLimited(fn func(delay time.Duration)) {
+	b.onRateLimited = fn
+}
+
+// OnRequest sets a function called with the method, URL and response status
+// of every API request, for debug logging.
+func (b *Beatport) OnRequest(fn func(method, url string, status int)) {
+	b.onRequest = fn
+}
+
+func (b *Beatport) fetch(ctx context.Context, method, endpoint string, payload interface{}, contentType string) (*http.Response, error) {
+	return b.fetchAttempt(ctx, method, endpoint, payload, contentType, nil, 0, false)
+}
+
+// fetchWithHeaders is fetch with headers that are only sent on this
+// request. The headers of the client are shared by every request and never
+// change after New.
+func (b *Beatport) fetchWithHeaders(ctx context.Context, method, endpoint string, headers map[string]string) (*http.Response, error) {
+	return b.fetchAttempt(ctx, method, endpoint, nil, "", headers, 0, false)
+}
+
+func isAuthEndpoint(endpoint string) bool {
+	return endpoint == tokenEndpoint || endpoint == authEndpoint || endpoint == loginEndpoint
 }
 
-func (b *Beatport) fetch(method, endpoint string, payload interface{}, contentType string) (*http.Response, error) {
+// fetchAttempt sends a single request, with headers added to the headers of
+// the client. A 429 response is retried up to maxRateLimitRetries times, and
+// a 401 response is retried once after logging in again, unless authRetried
+// is set.
+func (b *Beatport) fetchAttempt(ctx context.Context, method, endpoint string, payload interface{}, contentType string, headers map[string]string, rateLimitRetries int, authRetried bool) (*http.Response, error) {
 	var body bytes.Buffer
+	auth := b.auth.Load()
 
-	if endpoint != tokenEndpoint && endpoint != authEndpoint && endpoint != loginEndpoint {
-		if err := b.auth.Check(b); err != nil {
+	// Requests to the auth endpoints are made by the Auth itself, possibly
+	// while it holds its lock, so they neither check nor send the token.
+	var accessToken string
+	if !isAuthEndpoint(endpoint) {
+		if err := auth.Check(ctx, b); err != nil {
 			return nil, err
 		}
+		accessToken = auth.accessToken()
 	}
 
 	if payload != nil {
@@ -98,7 +190,7 @@ func (b *Beatport) fetch(method, endpoint string, payload interface{}, contentTy
 		baseUrl = beatsourceBaseUrl
 	}
 
-	req, err := http.NewRequest(method, baseUrl+endpoint, &body)
+	req, err := http.NewRequestWithContext(ctx, method, baseUrl+endpoint, &body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -106,46 +198,102 @@ func (b *Beatport) fetch(method, endpoint string, payload interface{}, contentTy
 	for key, value := range b.headers {
 		req.Header.Add(key, value)
 	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	if payload != nil {
 		req.Header.Set("Content-Type", contentType)
 	}
 
-	if b.auth.tokenPair != nil {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", b.auth.tokenPair.AccessToken))
+	if accessToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	}
+
+	if b.limiter != nil {
+		if err := b.limiter.WaitN(ctx, 1); err != nil {
+			return nil, err
+		}
 	}
 
 	resp, err := b.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
+	if b.onRequest != nil {
+		b.onRequest(method, req.URL.String(), resp.StatusCode)
+	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusFound {
-		if resp.StatusCode == http.StatusUnauthorized && endpoint != tokenEndpoint && endpoint != authEndpoint && endpoint != loginEndpoint {
-			b.auth.Invalidate()
-			return b.fetch(method, endpoint, payload, contentType)
+		if resp.StatusCode == http.StatusUnauthorized && !isAuthEndpoint(endpoint) && !authRetried {
+			resp.Body.Close()
+			if err := auth.relogin(ctx, b, accessToken); err != nil {
+				return nil, err
+			}
+			return b.fetchAttempt(ctx, method, endpoint, payload, contentType, headers, rateLimitRetries, true)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && rateLimitRetries < maxRateLimitRetries {
+			resp.Body.Close()
+			if err := b.waitRetryAfter(ctx, RetryAfter(resp)); err != nil {
+				return nil, err
+			}
+			return b.fetchAttempt(ctx, method, endpoint, payload, contentType, headers, rateLimitRetries+1, authRetried)
 		}
 		defer resp.Body.Close()
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
 		response := &FetcherError{}
 		if err = json.NewDecoder(resp.Body).Decode(response); err == nil {
-			detail := "Unknown error"
+			statusErr.Detail = "Unknown error"
 			if response.Detail != nil {
-				detail = *response.Detail
+				statusErr.Detail = *response.Detail
 			} else if response.Error != nil {
-				detail = *response.Error
+				statusErr.Detail = *response.Error
 			}
-			return nil, fmt.Errorf(
-				"request failed with status code: %d - %s",
-				resp.StatusCode,
-				detail,
-			)
 		}
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+		return nil, statusErr
 	}
 
 	return resp, nil
 }
 
+// waitRetryAfter waits before retrying a rate limited request. With a rate
+// limiter, the limiter is throttled and the retry waits for it like any
+// other request; without one, only this request sleeps.
+func (b *Beatport) waitRetryAfter(ctx context.Context, delay time.Duration) error {
+	if b.onRateLimited != nil {
+		b.onRateLimited(delay)
+	}
+	if b.limiter != nil {
+		b.limiter.Throttle(delay)
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of resp,
+// given either in seconds or as an HTTP date, capped at MaxRetryAfter. It
+// returns one second if the header is missing or invalid.
+func RetryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	}
+	if delay <= 0 {
+		return time.Second
+	}
+	return min(delay, MaxRetryAfter)
+}
+
 func encodeFormPayload(payload interface{}) (url.Values, error) {
 	values := url.Values{}
 
@@ -0,0 +1,29 @@
+package beatport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"", time.Second, time.Second},
+		{"invalid", time.Second, time.Second},
+		{"30", 30 * time.Second, 30 * time.Second},
+		{"3600", MaxRetryAfter, MaxRetryAfter},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.header)
+		if delay := RetryAfter(resp); delay < tt.min || delay > tt.max {
+			t.Errorf("RetryAfter(%q) = %v, expected between %v and %v", tt.header, delay, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package beatport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Cart is one of the carts of the account, like the main cart or the hold
+// bin.
+type Cart struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	ItemCount int    `json:"item_count"`
+}
+
+// CartItem is an item of a cart. Track is nil for items that aren't tracks,
+// like releases.
+type CartItem struct {
+	ID    int64  `json:"id"`
+	Track *Track `json:"track"`
+}
+
+func (b *Beatport) GetCarts(ctx context.Context) ([]Cart, error) {
+	res, err := b.fetch(
+		ctx,
+		"GET",
+		"/my/carts/",
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response Paginated[Cart]
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Results, nil
+}
+
+func (b *Beatport) GetCartItems(ctx context.Context, id int64, page int, params string) (*Paginated[CartItem], error) {
+	res, err := b.fetch(
+		ctx,
+		"GET",
+		fmt.Sprintf("/my/carts/%d/items/?page=%d&%s", id, page, params),
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response Paginated[CartItem]
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	for _, item := range response.Results {
+		if item.Track != nil {
+			item.Track.Store = b.store
+		}
+	}
+	return &response, nil
+}
+
+func (b *Beatport) RemoveCartItem(ctx context.Context, cartID, itemID int64) error {
+	res, err := b.fetch(
+		ctx,
+		"DELETE",
+		fmt.Sprintf("/my/carts/%d/items/%d/", cartID, itemID),
+		nil,
+		"",
+	)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
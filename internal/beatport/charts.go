@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -18,6 +19,10 @@ type Chart struct {
 	ChangeDate  time.Time   `json:"change_date"`
 	PublishDate time.Time   `json:"publish_date"`
 	Image       Image       `json:"image"`
+
+	// Top100 is set for the Top 100 lists, which aren't charts of the API
+	// but are downloaded like one.
+	Top100 bool `json:"-"`
 }
 
 type ChartPerson struct {
@@ -25,12 +30,30 @@ type ChartPerson struct {
 	OwnerSlug string `json:"owner_slug"`
 }
 
+// ChartTemplateKeywords are the keywords of chart and Top 100 directory templates.
+var ChartTemplateKeywords = []string{
+	"id",
+	"name",
+	"slug",
+	"first_genre",
+	"track_count",
+	"creator",
+	"created_date",
+	"published_date",
+	"updated_date",
+}
+
 func (c *Chart) DirectoryName(n NamingPreferences) string {
+	directoryName := ParseTemplate(n.Template, c.templateValues(n))
+	return SanitizePath(directoryName, n.Whitespace)
+}
+
+func (c *Chart) templateValues(n NamingPreferences) map[string]string {
 	var firstGenre string
 	if len(c.Genres) > 0 {
 		firstGenre = c.Genres[0].Name
 	}
-	templateValues := map[string]string{
+	return map[string]string{
 		"id":             strconv.Itoa(int(c.ID)),
 		"name":           SanitizeForPath(c.Name),
 		"slug":           c.Slug,
@@ -41,12 +64,11 @@ func (c *Chart) DirectoryName(n NamingPreferences) string {
 		"published_date": c.PublishDate.Format("2006-01-02"),
 		"updated_date":   c.ChangeDate.Format("2006-01-02"),
 	}
-	directoryName := ParseTemplate(n.Template, templateValues)
-	return SanitizePath(directoryName, n.Whitespace)
 }
 
-func (b *Beatport) GetChart(id int64) (*Chart, error) {
+func (b *Beatport) GetChart(ctx context.Context, id int64) (*Chart, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/charts/%d/", id),
 		nil,
@@ -63,8 +85,9 @@ func (b *Beatport) GetChart(id int64) (*Chart, error) {
 	return response, nil
 }
 
-func (b *Beatport) GetChartTracks(id int64, page int, params string) (*Paginated[Track], error) {
+func (b *Beatport) GetChartTracks(ctx context.Context, id int64, page int, params string) (*Paginated[Track], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/charts/%d/tracks/?page=%d&%s", id, page, params),
 		nil,
@@ -1,7 +1,60 @@
 package beatport
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
 type Genre struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
 	Slug string `json:"slug"`
 }
+
+func (b *Beatport) GetGenre(ctx context.Context, id int64) (*Genre, error) {
+	res, err := b.fetch(
+		ctx,
+		"GET",
+		fmt.Sprintf("/catalog/genres/%d/", id),
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	response := &Genre{}
+	if err = json.NewDecoder(res.Body).Decode(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetTopTracks fetches the Top 100 tracks of a genre, or of the whole store
+// if genreId is 0.
+func (b *Beatport) GetTopTracks(ctx context.Context, genreId int64, page int, params string) (*Paginated[Track], error) {
+	endpoint := fmt.Sprintf("/catalog/genres/%d/top/100/?page=%d&%s", genreId, page, params)
+	if genreId == 0 {
+		endpoint = fmt.Sprintf("/catalog/tracks/top/100/?page=%d&%s", page, params)
+	}
+	res, err := b.fetch(
+		ctx,
+		"GET",
+		endpoint,
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response Paginated[Track]
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	for i := range response.Results {
+		response.Results[i].Store = b.store
+	}
+	return &response, nil
+}
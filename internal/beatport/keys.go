@@ -1,7 +1,10 @@
 package beatport
 
 import (
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 type Key struct {
@@ -18,11 +21,30 @@ type ChordType struct {
 	Name string `json:"name"`
 }
 
+// classicalKeys are the short names of the Camelot keys, spelled the way
+// Beatport spells them.
+var classicalKeys = map[string]string{
+	"1A": "Abm", "2A": "Ebm", "3A": "Bbm", "4A": "Fm", "5A": "Cm", "6A": "Gm",
+	"7A": "Dm", "8A": "Am", "9A": "Em", "10A": "Bm", "11A": "F#m", "12A": "Dbm",
+	"1B": "B", "2B": "F#", "3B": "Db", "4B": "Ab", "5B": "Eb", "6B": "Bb",
+	"7B": "F", "8B": "C", "9B": "G", "10B": "D", "11B": "A", "12B": "E",
+}
+
+// Display formats the key in a key system: "standard" (or "beatport") for
+// Beatport's name, "standard-short" (or "classical"), "openkey" or
+// "camelot". A missing or unknown key is an empty string.
 func (k *Key) Display(system string) string {
 	switch system {
-	case "standard":
+	case "standard", "beatport":
+		if _, ok := k.camelot(); !ok {
+			return ""
+		}
 		return k.Name
-	case "standard-short":
+	case "standard-short", "classical":
+		if k.Letter == "" {
+			camelot, _ := k.camelot()
+			return classicalKeys[camelot]
+		}
 		var symbol string
 		if k.IsSharp {
 			symbol = "#"
@@ -35,22 +57,89 @@ func (k *Key) Display(system string) string {
 		}
 		return k.Letter + symbol + chord
 	case "openkey":
-		var number int
-		if k.CamelotNumber > 7 {
-			number = k.CamelotNumber - 7
+		camelot, ok := k.camelot()
+		if !ok {
+			return ""
+		}
+		number, _ := strconv.Atoi(camelot[:len(camelot)-1])
+		if number > 7 {
+			number -= 7
 		} else {
-			number = k.CamelotNumber + 5
+			number += 5
 		}
-		var letter string
-		if k.ChordType.Name == "Minor" {
-			letter = "m"
-		} else if k.ChordType.Name == "Major" {
+		letter := "m"
+		if strings.HasSuffix(camelot, "B") {
 			letter = "d"
 		}
 		return strconv.Itoa(number) + letter
 	case "camelot":
-		return strconv.Itoa(k.CamelotNumber) + k.CamelotLetter
+		camelot, _ := k.camelot()
+		return camelot
 	default:
 		return ""
 	}
 }
+
+// camelot returns the key in Camelot notation, from its Camelot fields or,
+// when they are missing, from its name. It returns false for a missing or
+// unknown key.
+func (k *Key) camelot() (string, bool) {
+	if k.CamelotNumber >= 1 && k.CamelotNumber <= 12 && (k.CamelotLetter == "A" || k.CamelotLetter == "B") {
+		return strconv.Itoa(k.CamelotNumber) + k.CamelotLetter, true
+	}
+	if k.Name == "" {
+		return "", false
+	}
+	camelot, err := CamelotKey(k.Name)
+	return camelot, err == nil
+}
+
+var (
+	camelotKeyRegex = regexp.MustCompile(`^(\d{1,2})([AB])$`)
+	openKeyRegex    = regexp.MustCompile(`^(\d{1,2})([md])$`)
+	noteKeyRegex    = regexp.MustCompile(`^([A-Ga-g])([#b♯♭]?)\s*((?i:m|min|minor|maj|major))?$`)
+)
+
+var notePitchClasses = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+
+// CamelotKey converts a key in Camelot ("8A"), Open Key ("1m"), Beatport
+// ("A Minor") or short ("Am", "G#m") notation to Camelot, so keys can be
+// compared whichever notation they're given in.
+func CamelotKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if matches := camelotKeyRegex.FindStringSubmatch(strings.ToUpper(key)); matches != nil {
+		number, _ := strconv.Atoi(matches[1])
+		if number >= 1 && number <= 12 {
+			return strconv.Itoa(number) + matches[2], nil
+		}
+	}
+	if matches := openKeyRegex.FindStringSubmatch(strings.ToLower(key)); matches != nil {
+		number, _ := strconv.Atoi(matches[1])
+		if number >= 1 && number <= 12 {
+			number = (number+6)%12 + 1
+			letter := "A"
+			if matches[2] == "d" {
+				letter = "B"
+			}
+			return strconv.Itoa(number) + letter, nil
+		}
+	}
+	if matches := noteKeyRegex.FindStringSubmatch(key); matches != nil {
+		pitch := notePitchClasses[strings.ToUpper(matches[1])]
+		switch matches[2] {
+		case "#", "♯":
+			pitch++
+		case "b", "♭":
+			pitch--
+		}
+		letter := "B"
+		if mode := strings.ToLower(matches[3]); mode == "m" || mode == "min" || mode == "minor" {
+			// Minor keys share the number of their relative major.
+			pitch += 3
+			letter = "A"
+		}
+		number := ((7*pitch)%12+12+7)%12 + 1
+		return strconv.Itoa(number) + letter, nil
+	}
+	return "", fmt.Errorf("invalid key: %s", key)
+}
@@ -0,0 +1,96 @@
+package beatport
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestKeyDisplay(t *testing.T) {
+	tests := []struct {
+		camelot   string
+		classical string
+		openkey   string
+		name      string
+	}{
+		{"1A", "Abm", "6m", "Ab Minor"},
+		{"2A", "Ebm", "7m", "Eb Minor"},
+		{"3A", "Bbm", "8m", "Bb Minor"},
+		{"4A", "Fm", "9m", "F Minor"},
+		{"5A", "Cm", "10m", "C Minor"},
+		{"6A", "Gm", "11m", "G Minor"},
+		{"7A", "Dm", "12m", "D Minor"},
+		{"8A", "Am", "1m", "A Minor"},
+		{"9A", "Em", "2m", "E Minor"},
+		{"10A", "Bm", "3m", "B Minor"},
+		{"11A", "F#m", "4m", "F# Minor"},
+		{"12A", "Dbm", "5m", "Db Minor"},
+		{"1B", "B", "6d", "B Major"},
+		{"2B", "F#", "7d", "F# Major"},
+		{"3B", "Db", "8d", "Db Major"},
+		{"4B", "Ab", "9d", "Ab Major"},
+		{"5B", "Eb", "10d", "Eb Major"},
+		{"6B", "Bb", "11d", "Bb Major"},
+		{"7B", "F", "12d", "F Major"},
+		{"8B", "C", "1d", "C Major"},
+		{"9B", "G", "2d", "G Major"},
+		{"10B", "D", "3d", "D Major"},
+		{"11B", "A", "4d", "A Major"},
+		{"12B", "E", "5d", "E Major"},
+	}
+
+	for _, tt := range tests {
+		number, _ := strconv.Atoi(tt.camelot[:len(tt.camelot)-1])
+		key := Key{
+			Name:          tt.name,
+			Letter:        tt.classical[:1],
+			ChordType:     ChordType{Name: strings.Fields(tt.name)[1]},
+			CamelotNumber: number,
+			CamelotLetter: tt.camelot[len(tt.camelot)-1:],
+			IsFlat:        strings.Contains(tt.classical, "b"),
+			IsSharp:       strings.Contains(tt.classical, "#"),
+		}
+
+		for system, want := range map[string]string{
+			"camelot":   tt.camelot,
+			"openkey":   tt.openkey,
+			"classical": tt.classical,
+			"beatport":  tt.name,
+		} {
+			if got := key.Display(system); got != want {
+				t.Errorf("%s Display(%q) = %q, expected %q", tt.name, system, got, want)
+			}
+			if got, err := CamelotKey(want); got != tt.camelot {
+				t.Errorf("CamelotKey(%q) = %q, %v, expected %q", want, got, err, tt.camelot)
+			}
+		}
+	}
+}
+
+func TestCamelotKeyEnharmonic(t *testing.T) {
+	for key, want := range map[string]string{"G# Minor": "1A", "a#m": "3A", "Gb": "2B", "8a": "8A", "13A": ""} {
+		if got, _ := CamelotKey(key); got != want {
+			t.Errorf("CamelotKey(%q) = %q, expected %q", key, got, want)
+		}
+	}
+}
+
+func TestKeyDisplayFallback(t *testing.T) {
+	tests := []struct {
+		key      Key
+		expected map[string]string
+	}{
+		{Key{Name: "D♭ Major"}, map[string]string{"camelot": "3B", "openkey": "8d", "classical": "Db"}},
+		{Key{Name: "G# Minor"}, map[string]string{"camelot": "1A", "openkey": "6m", "classical": "Abm"}},
+		{Key{}, map[string]string{"camelot": "", "openkey": "", "classical": "", "standard": ""}},
+		{Key{Name: "Unknown", CamelotNumber: 0}, map[string]string{"camelot": "", "openkey": "", "classical": "", "standard": "", "beatport": ""}},
+		{Key{Name: "A Minor", CamelotNumber: 8, CamelotLetter: "A"}, map[string]string{"standard": "A Minor", "beatport": "A Minor"}},
+	}
+	for _, tt := range tests {
+		for system, want := range tt.expected {
+			if got := tt.key.Display(system); got != want {
+				t.Errorf("%q Display(%q) = %q, expected %q", tt.key.Name, system, got, want)
+			}
+		}
+	}
+}
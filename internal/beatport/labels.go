@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -16,24 +17,37 @@ type Label struct {
 	Store   Store     `json:"store"`
 }
 
+// LabelTemplateKeywords are the keywords of label directory templates.
+var LabelTemplateKeywords = []string{
+	"id",
+	"name",
+	"slug",
+	"created_date",
+	"updated_date",
+}
+
 func (l *Label) DirectoryName(n NamingPreferences) string {
-	templateValues := map[string]string{
+	directoryName := ParseTemplate(n.Template, l.templateValues())
+	return SanitizePath(directoryName, n.Whitespace)
+}
+
+func (l *Label) templateValues() map[string]string {
+	return map[string]string{
 		"id":           strconv.Itoa(int(l.ID)),
 		"name":         SanitizeForPath(l.Name),
 		"slug":         l.Slug,
 		"created_date": l.Created.Format("2006-01-02"),
 		"updated_date": l.Updated.Format("2006-01-02"),
 	}
-	directoryName := ParseTemplate(n.Template, templateValues)
-	return SanitizePath(directoryName, n.Whitespace)
 }
 
 func (l *Label) StoreUrl() string {
 	return storeUrl(l.ID, "label", l.Slug, l.Store)
 }
 
-func (b *Beatport) GetLabel(id int64) (*Label, error) {
+func (b *Beatport) GetLabel(ctx context.Context, id int64) (*Label, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/labels/%d/", id),
 		nil,
@@ -51,8 +65,9 @@ func (b *Beatport) GetLabel(id int64) (*Label, error) {
 	return response, nil
 }
 
-func (b *Beatport) GetLabelReleases(id int64, page int, params string) (*Paginated[Release], error) {
+func (b *Beatport) GetLabelReleases(ctx context.Context, id int64, page int, params string) (*Paginated[Release], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/labels/%d/releases/?page=%d&%s", id, page, params),
 		nil,
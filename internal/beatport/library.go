@@ -0,0 +1,38 @@
+package beatport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Purchase is a track bought with the account.
+type Purchase struct {
+	Track
+	PurchaseDate string `json:"purchase_date"`
+}
+
+// GetPurchases fetches a page of the tracks purchased with the account, the
+// most recent first. The id is ignored, it only matches the signature of the
+// other paginated requests.
+func (b *Beatport) GetPurchases(ctx context.Context, id int64, page int, params string) (*Paginated[Purchase], error) {
+	res, err := b.fetch(
+		ctx,
+		"GET",
+		fmt.Sprintf("/my/downloads/?page=%d&order_by=-purchase_date&%s", page, params),
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response Paginated[Purchase]
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	for i := range response.Results {
+		response.Results[i].Store = b.store
+	}
+	return &response, nil
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -18,6 +20,10 @@ var (
 	ChartLink    LinkType = "charts"
 	LabelLink    LinkType = "labels"
 	ArtistLink   LinkType = "artists"
+	Top100Link   LinkType = "top-100"
+	LibraryLink  LinkType = "library"
+	CartLink     LinkType = "cart"
+	ISRCLink     LinkType = "isrc"
 
 	StoreBeatport   Store = "beatport"
 	StoreBeatsource Store = "beatsource"
@@ -29,13 +35,103 @@ type Link struct {
 	ID       int64
 	Params   string
 	Store    Store
+	ISRC     string
+	// Positions are the track positions selected with a suffix like #1-20,
+	// set by the caller. Nil selects every track.
+	Positions map[int]bool
 }
 
 var (
-	ErrInvalidUrl = errors.New("invalid url")
+	ErrInvalidUrl      = errors.New("invalid url")
+	ErrUnsupportedHost = errors.New("unsupported url host")
 )
 
+// storeHosts maps the hosts of the stores, without a www. or m. prefix, to
+// the store they belong to.
+var storeHosts = map[string]Store{
+	"beatport.com":       StoreBeatport,
+	"api.beatport.com":   StoreBeatport,
+	"beatsource.com":     StoreBeatsource,
+	"api.beatsource.com": StoreBeatsource,
+}
+
+// hostStore returns the store of a URL host, matched case-insensitively and
+// with or without a www. or m. prefix.
+func hostStore(host string) (Store, bool) {
+	host = strings.ToLower(host)
+	host = strings.TrimPrefix(host, "www.")
+	host = strings.TrimPrefix(host, "m.")
+	store, ok := storeHosts[host]
+	return store, ok
+}
+
+// idLinkRegex matches links given as a type and an ID, like track:12345678,
+// optionally prefixed with the store (bp: or bs:).
+var idLinkRegex = regexp.MustCompile(`^(?:(bp|bs):)?(track|release|chart|playlist|label|artist):(\d+)$`)
+
+// isrcLinkRegex matches tracks given by ISRC, like isrc:GBARL1234567.
+var isrcLinkRegex = regexp.MustCompile(`^(?:(bp|bs):)?isrc:([A-Za-z]{2}[A-Za-z0-9]{3}\d{7})$`)
+
+var idLinkTypes = map[string]LinkType{
+	"track":    TrackLink,
+	"release":  ReleaseLink,
+	"chart":    ChartLink,
+	"playlist": PlaylistLink,
+	"label":    LabelLink,
+	"artist":   ArtistLink,
+}
+
+// IsIDLink reports whether input is a link given as a type and an ID, or a
+// track given by ISRC.
+func IsIDLink(input string) bool {
+	input = strings.TrimSpace(input)
+	return idLinkRegex.MatchString(input) || isrcLinkRegex.MatchString(input)
+}
+
+func parseIDLink(input string) (*Link, error) {
+	if matches := isrcLinkRegex.FindStringSubmatch(strings.TrimSpace(input)); matches != nil {
+		link := &Link{
+			Original: input,
+			Type:     ISRCLink,
+			ISRC:     strings.ToUpper(matches[2]),
+			Store:    StoreBeatport,
+		}
+		if matches[1] == "bs" {
+			link.Store = StoreBeatsource
+		}
+		return link, nil
+	}
+
+	matches := idLinkRegex.FindStringSubmatch(strings.TrimSpace(input))
+	id, err := strconv.ParseInt(matches[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id: %v", err)
+	}
+	link := &Link{
+		Original: input,
+		Type:     idLinkTypes[matches[2]],
+		ID:       id,
+		Store:    StoreBeatport,
+	}
+	if matches[1] == "bs" {
+		link.Store = StoreBeatsource
+	}
+	return link, nil
+}
+
+// storeChartSegments are the first path segments of chart links on each
+// store. Beatsource calls its curated charts playlists, which are separate
+// from the user playlists under /library/playlists.
+var storeChartSegments = map[Store][]string{
+	StoreBeatport:   {"chart"},
+	StoreBeatsource: {"playlist", "chart"},
+}
+
 func (b *Beatport) ParseUrl(inputURL string) (*Link, error) {
+	if IsIDLink(inputURL) {
+		return parseIDLink(inputURL)
+	}
+
 	u, err := url.Parse(inputURL)
 	if err != nil {
 		return nil, err
@@ -47,14 +143,11 @@ func (b *Beatport) ParseUrl(inputURL string) (*Link, error) {
 		Original: inputURL,
 	}
 
-	switch u.Host {
-	case "www.beatport.com", "api.beatport.com":
-		link.Store = StoreBeatport
-	case "www.beatsource.com", "api.beatsource.com":
-		link.Store = StoreBeatsource
-	default:
-		return nil, ErrInvalidUrl
+	store, ok := hostStore(u.Hostname())
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHost, u.Host)
 	}
+	link.Store = store
 
 	if segmentsLength == 0 {
 		return nil, ErrInvalidUrl
@@ -72,7 +165,24 @@ func (b *Beatport) ParseUrl(inputURL string) (*Link, error) {
 
 	var idSegment int
 
+	if slices.Contains(storeChartSegments[link.Store], segments[0]) {
+		segments[0] = "chart"
+	}
+
 	switch segments[0] {
+	case "top-100":
+		link.Type = Top100Link
+		link.Params = u.RawQuery
+		return &link, nil
+	case "genre":
+		if segmentsLength < 4 || segments[3] != "top-100" {
+			return nil, ErrInvalidUrl
+		}
+		idSegment = 2
+		link.Type = Top100Link
+	case "cart":
+		link.Type = CartLink
+		return &link, nil
 	case "track":
 		idSegment = 2
 		link.Type = TrackLink
@@ -80,17 +190,28 @@ func (b *Beatport) ParseUrl(inputURL string) (*Link, error) {
 		idSegment = 2
 		link.Type = ReleaseLink
 	case "library":
+		if segmentsLength < 2 {
+			return nil, ErrInvalidUrl
+		}
 		switch segments[1] {
 		case "playlists", "playlist":
 			idSegment = 2
 			link.Type = PlaylistLink
+		case "downloads":
+			link.Type = LibraryLink
+			link.Params = u.RawQuery
+			return &link, nil
 		default:
 			return nil, fmt.Errorf("invalid link type: %s/%s", segments[0], segments[1])
 		}
 	case "playlists":
-		idSegment = 2
+		// shared playlists (/playlists/share/<id>) or the API (/playlists/<id>)
+		idSegment = 1
+		if segmentsLength > 1 && segments[1] == "share" {
+			idSegment = 2
+		}
 		link.Type = PlaylistLink
-	case "chart", "playlist":
+	case "chart":
 		idSegment = 2
 		link.Type = ChartLink
 	case "label":
@@ -106,6 +227,9 @@ func (b *Beatport) ParseUrl(inputURL string) (*Link, error) {
 	case "releases":
 		idSegment = 1
 		link.Type = ReleaseLink
+	case "charts":
+		idSegment = 1
+		link.Type = ChartLink
 	default:
 		return nil, ErrInvalidUrl
 	}
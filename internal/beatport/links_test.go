@@ -0,0 +1,111 @@
+package beatport
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseUrlTop100(t *testing.T) {
+	b := &Beatport{}
+	tests := []struct {
+		url string
+		id  int64
+	}{
+		{"https://www.beatport.com/top-100", 0},
+		{"https://www.beatport.com/genre/techno-peak-time-driving/6/top-100", 6},
+		{"https://www.beatport.com/de/genre/house/5/top-100", 5},
+	}
+
+	for _, tt := range tests {
+		link, err := b.ParseUrl(tt.url)
+		if err != nil {
+			t.Errorf("ParseUrl(%q): %v", tt.url, err)
+			continue
+		}
+		if link.Type != Top100Link || link.ID != tt.id {
+			t.Errorf("ParseUrl(%q) = %s %d, expected %s %d", tt.url, link.Type, link.ID, Top100Link, tt.id)
+		}
+	}
+
+	if _, err := b.ParseUrl("https://www.beatport.com/genre/house/5"); err == nil {
+		t.Error("expected error for genre url without top-100")
+	}
+}
+
+func TestParseUrlPlaylist(t *testing.T) {
+	b := &Beatport{}
+	tests := []struct {
+		url string
+		id  int64
+	}{
+		{"https://www.beatport.com/library/playlists/123456", 123456},
+		{"https://www.beatport.com/library/playlist/123456", 123456},
+		{"https://www.beatport.com/de/library/playlists/123456", 123456},
+		{"https://www.beatport.com/playlists/share/123456", 123456},
+	}
+
+	for _, tt := range tests {
+		link, err := b.ParseUrl(tt.url)
+		if err != nil {
+			t.Errorf("ParseUrl(%q): %v", tt.url, err)
+			continue
+		}
+		if link.Type != PlaylistLink || link.ID != tt.id {
+			t.Errorf("ParseUrl(%q) = %s %d, expected %s %d", tt.url, link.Type, link.ID, PlaylistLink, tt.id)
+		}
+	}
+
+	if _, err := b.ParseUrl("https://www.beatport.com/library"); err == nil {
+		t.Error("expected error for library url without playlist")
+	}
+}
+
+func TestParseUrlStores(t *testing.T) {
+	b := &Beatport{}
+	tests := []struct {
+		url   string
+		store Store
+		typ   LinkType
+		id    int64
+	}{
+		{"https://www.beatport.com/chart/best-of-2024/812345", StoreBeatport, ChartLink, 812345},
+		{"https://www.beatsource.com/playlist/open-format-essentials/12345", StoreBeatsource, ChartLink, 12345},
+		{"https://www.beatsource.com/library/playlists/54321", StoreBeatsource, PlaylistLink, 54321},
+		{"https://www.beatsource.com/playlists/share/54321", StoreBeatsource, PlaylistLink, 54321},
+		{"https://api.beatsource.com/v4/catalog/charts/12345/", StoreBeatsource, ChartLink, 12345},
+		{"https://api.beatport.com/v4/catalog/playlists/54321/", StoreBeatport, PlaylistLink, 54321},
+		{"https://www.beatport.com/library/downloads", StoreBeatport, LibraryLink, 0},
+		{"https://www.beatport.com/cart", StoreBeatport, CartLink, 0},
+		{"https://Beatport.com/track/strobe/1696999", StoreBeatport, TrackLink, 1696999},
+		{"https://m.beatsource.com/track/strobe/1696999", StoreBeatsource, TrackLink, 1696999},
+		{"track:12345678", StoreBeatport, TrackLink, 12345678},
+		{"chart:998877", StoreBeatport, ChartLink, 998877},
+		{"bs:release:4455667", StoreBeatsource, ReleaseLink, 4455667},
+		{"isrc:GBARL1234567", StoreBeatport, ISRCLink, 0},
+	}
+
+	for _, tt := range tests {
+		link, err := b.ParseUrl(tt.url)
+		if err != nil {
+			t.Errorf("ParseUrl(%q): %v", tt.url, err)
+			continue
+		}
+		if link.Store != tt.store || link.Type != tt.typ || link.ID != tt.id {
+			t.Errorf("ParseUrl(%q) = %s %s %d, expected %s %s %d", tt.url, link.Store, link.Type, link.ID, tt.store, tt.typ, tt.id)
+		}
+	}
+
+	if link, _ := b.ParseUrl("bs:isrc:gbarl1234567"); link == nil || link.ISRC != "GBARL1234567" {
+		t.Errorf("ParseUrl(bs:isrc:gbarl1234567) = %+v, expected ISRC GBARL1234567", link)
+	}
+	if IsIDLink("isrc:GBARL12345") {
+		t.Error("IsIDLink() matched a malformed ISRC")
+	}
+
+	if _, err := b.ParseUrl("https://www.example.com/track/strobe/1696999"); !errors.Is(err, ErrUnsupportedHost) {
+		t.Errorf("ParseUrl() = %v, expected an unsupported host", err)
+	}
+	if _, err := b.ParseUrl("https://www.beatport.com/playlist/essentials/12345"); err == nil {
+		t.Error("expected error for beatport url with beatsource layout")
+	}
+}
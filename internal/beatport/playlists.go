@@ -1,12 +1,17 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 )
 
+var ErrPlaylistNotAccessible = errors.New("playlist is private or not accessible with this account")
+
 type Playlist struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
@@ -24,7 +29,24 @@ type PlaylistItem struct {
 	Track    Track `json:"track"`
 }
 
+// PlaylistTemplateKeywords are the keywords of playlist directory templates.
+var PlaylistTemplateKeywords = []string{
+	"id",
+	"name",
+	"first_genre",
+	"track_count",
+	"bpm_range",
+	"length",
+	"created_date",
+	"updated_date",
+}
+
 func (p *Playlist) DirectoryName(n NamingPreferences) string {
+	directoryName := ParseTemplate(n.Template, p.templateValues(n))
+	return SanitizePath(directoryName, n.Whitespace)
+}
+
+func (p *Playlist) templateValues(n NamingPreferences) map[string]string {
 	var firstGenre string
 	var bpmRange string
 
@@ -36,7 +58,7 @@ func (p *Playlist) DirectoryName(n NamingPreferences) string {
 		bpmRange = fmt.Sprintf("%d-%d", *p.BPMRange[0], *p.BPMRange[1])
 	}
 
-	templateValues := map[string]string{
+	return map[string]string{
 		"id":           strconv.Itoa(int(p.ID)),
 		"name":         SanitizeForPath(p.Name),
 		"first_genre":  SanitizeForPath(firstGenre),
@@ -46,19 +68,31 @@ func (p *Playlist) DirectoryName(n NamingPreferences) string {
 		"created_date": p.CreatedDate.Format("2006-01-02"),
 		"updated_date": p.UpdatedDate.Format("2006-01-02"),
 	}
-	directoryName := ParseTemplate(n.Template, templateValues)
-	return SanitizePath(directoryName, n.Whitespace)
 }
 
-func (b *Beatport) GetPlaylist(id int64) (*Playlist, error) {
+// playlistError explains the errors the API returns for playlists the
+// account can't see, which are user playlists that aren't public.
+func playlistError(err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusForbidden, http.StatusNotFound:
+			return fmt.Errorf("%w: %w", ErrPlaylistNotAccessible, err)
+		}
+	}
+	return err
+}
+
+func (b *Beatport) GetPlaylist(ctx context.Context, id int64) (*Playlist, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/playlists/%d/", id),
 		nil,
 		"",
 	)
 	if err != nil {
-		return nil, err
+		return nil, playlistError(err)
 	}
 	defer res.Body.Close()
 	response := &Playlist{}
@@ -68,15 +102,16 @@ func (b *Beatport) GetPlaylist(id int64) (*Playlist, error) {
 	return response, nil
 }
 
-func (b *Beatport) GetPlaylistItems(id int64, page int, params string) (*Paginated[PlaylistItem], error) {
+func (b *Beatport) GetPlaylistItems(ctx context.Context, id int64, page int, params string) (*Paginated[PlaylistItem], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/playlists/%d/tracks/?page=%d&%s", id, page, params),
 		nil,
 		"",
 	)
 	if err != nil {
-		return nil, err
+		return nil, playlistError(err)
 	}
 	defer res.Body.Close()
 	var response Paginated[PlaylistItem]
@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -34,8 +35,9 @@ func (r *Release) StoreUrl() string {
 	return storeUrl(r.ID, "release", r.Slug, r.Store)
 }
 
-func (b *Beatport) GetRelease(id int64) (*Release, error) {
+func (b *Beatport) GetRelease(ctx context.Context, id int64) (*Release, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/releases/%d/", id),
 		nil,
@@ -53,8 +55,9 @@ func (b *Beatport) GetRelease(id int64) (*Release, error) {
 	return response, nil
 }
 
-func (b *Beatport) GetReleaseTracks(id int64, page int, params string) (*Paginated[Track], error) {
+func (b *Beatport) GetReleaseTracks(ctx context.Context, id int64, page int, params string) (*Paginated[Track], error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/releases/%d/tracks/?page=%d&%s", id, page, params),
 		nil,
@@ -83,11 +86,32 @@ func (r *Release) Year() string {
 	return year
 }
 
+// ReleaseTemplateKeywords are the keywords of release directory templates.
+var ReleaseTemplateKeywords = []string{
+	"id",
+	"name",
+	"slug",
+	"artists",
+	"remixers",
+	"date",
+	"year",
+	"track_count",
+	"bpm_range",
+	"catalog_number",
+	"upc",
+	"label",
+}
+
 func (r *Release) DirectoryName(n NamingPreferences) string {
+	directoryName := ParseTemplate(n.Template, r.templateValues(n))
+	return SanitizePath(directoryName, n.Whitespace)
+}
+
+func (r *Release) templateValues(n NamingPreferences) map[string]string {
 	artistsString := r.Artists.Display(n.ArtistsLimit, n.ArtistsShortForm)
 	remixersString := r.Remixers.Display(n.ArtistsLimit, n.ArtistsShortForm)
 
-	templateValues := map[string]string{
+	return map[string]string{
 		"id":             strconv.Itoa(int(r.ID)),
 		"name":           SanitizeForPath(r.Name.String()),
 		"slug":           r.Slug,
@@ -101,6 +125,4 @@ func (r *Release) DirectoryName(n NamingPreferences) string {
 		"upc":            r.UPC,
 		"label":          SanitizeForPath(r.Label.Name),
 	}
-	directoryName := ParseTemplate(n.Template, templateValues)
-	return SanitizePath(directoryName, n.Whitespace)
 }
@@ -1,6 +1,7 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -9,12 +10,33 @@ import (
 type SearchResults struct {
 	Tracks   []Track   `json:"tracks"`
 	Releases []Release `json:"releases"`
+	Artists  []Artist  `json:"artists"`
 }
 
-func (b *Beatport) Search(query string) (*SearchResults, error) {
+// SearchParams selects the type of results to search for (tracks, releases
+// or artists, all of them when empty) and a page of PerPage results of each
+// type (the first page with the API's page size when zero).
+type SearchParams struct {
+	Type    string
+	Page    int
+	PerPage int
+}
+
+func (b *Beatport) Search(ctx context.Context, query string, params SearchParams) (*SearchResults, error) {
+	path := fmt.Sprintf("/catalog/search/?q=%s&order_by=-publish_date&is_available_for_streaming=true", url.QueryEscape(query))
+	if params.Type != "" {
+		path += "&type=" + params.Type
+	}
+	if params.Page > 0 {
+		path += fmt.Sprintf("&page=%d", params.Page)
+	}
+	if params.PerPage > 0 {
+		path += fmt.Sprintf("&per_page=%d", params.PerPage)
+	}
 	res, err := b.fetch(
+		ctx,
 		"GET",
-		fmt.Sprintf("/catalog/search/?q=%s&order_by=-publish_date&is_available_for_streaming=true", url.QueryEscape(query)),
+		path,
 		nil,
 		"",
 	)
@@ -26,5 +48,14 @@ func (b *Beatport) Search(query string) (*SearchResults, error) {
 	if err = json.NewDecoder(res.Body).Decode(response); err != nil {
 		return nil, err
 	}
+	for i := range response.Tracks {
+		response.Tracks[i].Store = b.store
+	}
+	for i := range response.Releases {
+		response.Releases[i].Store = b.store
+	}
+	for i := range response.Artists {
+		response.Artists[i].Store = b.store
+	}
 	return response, nil
 }
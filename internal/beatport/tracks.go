@@ -1,10 +1,14 @@
 package beatport
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Track struct {
@@ -25,7 +29,15 @@ type Track struct {
 	PublishDate string          `json:"publish_date"`
 	Release     Release         `json:"release"`
 	URL         string          `json:"url"`
+	SampleURL   string          `json:"sample_url"`
 	Store       Store           `json:"store"`
+
+	// Position is the track's position in the playlist or chart it is
+	// downloaded from, out of PositionCount tracks. With NumberByPosition,
+	// it also replaces the release track number in file names and tags.
+	Position         int  `json:"-"`
+	PositionCount    int  `json:"-"`
+	NumberByPosition bool `json:"-"`
 }
 
 type TrackDownload struct {
@@ -57,7 +69,87 @@ func (t *Track) SubgenreOrGenre() string {
 	return t.Genre.Name
 }
 
+// TrackNumber returns the number of the track and the total it counts to:
+// its position in the playlist or chart with NumberByPosition, and its
+// release track number otherwise.
+func (t *Track) TrackNumber() (int, int) {
+	if t.NumberByPosition && t.Position > 0 {
+		return t.Position, t.PositionCount
+	}
+	return t.Number, t.Release.TrackCount
+}
+
 func (t *Track) Filename(n NamingPreferences) string {
+	fileName := ParseTemplate(n.Template, t.templateValues(n))
+	return SanitizePath(fileName, n.Whitespace)
+}
+
+// Directory returns the directory of the track from a template of path
+// segments separated by "/", each sanitized on its own. Segments that
+// resolve to nothing are named "Unknown", so the hierarchy keeps its depth.
+func (t *Track) Directory(n NamingPreferences) string {
+	values := t.templateValues(n)
+	var segments []string
+	for _, segment := range strings.Split(n.Template, "/") {
+		if strings.TrimSpace(segment) == "" {
+			continue
+		}
+		name := strings.TrimSpace(SanitizePath(ParseTemplate(segment, values), n.Whitespace))
+		if name == "" || name == "." || name == ".." {
+			name = "Unknown"
+		}
+		segments = append(segments, name)
+	}
+	return filepath.Join(segments...)
+}
+
+// TrackTemplateKeywords are the keywords of track filename and directory templates.
+var TrackTemplateKeywords = []string{
+	"id",
+	"name",
+	"title",
+	"slug",
+	"mix_name",
+	"artists",
+	"artist",
+	"remixers",
+	"number",
+	"track_number",
+	"position",
+	"length",
+	"key",
+	"bpm",
+	"genre",
+	"subgenre",
+	"genre_with_subgenre",
+	"subgenre_or_genre",
+	"isrc",
+	"label",
+	"release",
+	"catalog_number",
+	"year",
+}
+
+// pathTemplateKeys are the track template keywords whose values may contain
+// characters that aren't allowed in paths.
+var pathTemplateKeys = []string{
+	"name", "title", "mix_name", "artists", "artist", "remixers", "genre", "subgenre",
+	"genre_with_subgenre", "subgenre_or_genre", "label", "release", "catalog_number",
+}
+
+func (t *Track) templateValues(n NamingPreferences) map[string]string {
+	templateValues := t.TemplateValues(n)
+	for _, key := range pathTemplateKeys {
+		if value, ok := templateValues[key]; ok {
+			templateValues[key] = SanitizeForPath(value)
+		}
+	}
+	return templateValues
+}
+
+// TemplateValues returns the values of the track template keywords as they
+// are, without removing the characters that aren't allowed in paths.
+func (t *Track) TemplateValues(n NamingPreferences) map[string]string {
 	artistsString := t.Artists.Display(n.ArtistsLimit, n.ArtistsShortForm)
 	remixersString := t.Remixers.Display(n.ArtistsLimit, n.ArtistsShortForm)
 	subgenre := ""
@@ -65,30 +157,56 @@ func (t *Track) Filename(n NamingPreferences) string {
 		subgenre = t.Subgenre.Name
 	}
 
+	trackNumber, trackTotal := t.TrackNumber()
+	number := NumberWithPadding(trackNumber, trackTotal, n.TrackNumberPadding)
+	position := number
+	if t.Position > 0 {
+		position = NumberWithPadding(t.Position, t.PositionCount, n.TrackNumberPadding)
+	}
+
 	templateValues := map[string]string{
 		"id":                  strconv.Itoa(int(t.ID)),
-		"name":                SanitizeForPath(t.Name.String()),
+		"name":                t.Name.String(),
+		"title":               t.Name.String(),
 		"slug":                t.Slug,
-		"mix_name":            SanitizeForPath(t.MixName.String()),
-		"artists":             SanitizeForPath(artistsString),
-		"remixers":            SanitizeForPath(remixersString),
-		"number":              NumberWithPadding(t.Number, t.Release.TrackCount, n.TrackNumberPadding),
+		"mix_name":            t.MixName.String(),
+		"artists":             artistsString,
+		"remixers":            remixersString,
+		"number":              number,
+		"track_number":        strconv.Itoa(trackNumber),
+		"position":            position,
 		"length":              t.LengthMs.Display(),
 		"key":                 t.Key.Display(n.KeySystem),
 		"bpm":                 strconv.Itoa(t.BPM),
-		"genre":               SanitizeForPath(t.Genre.Name),
-		"subgenre":            SanitizeForPath(subgenre),
-		"genre_with_subgenre": SanitizeForPath(t.GenreWithSubgenre("-")),
-		"subgenre_or_genre":   SanitizeForPath(t.SubgenreOrGenre()),
+		"genre":               t.Genre.Name,
+		"subgenre":            subgenre,
+		"genre_with_subgenre": t.GenreWithSubgenre("-"),
+		"subgenre_or_genre":   t.SubgenreOrGenre(),
 		"isrc":                t.ISRC,
-		"label":               SanitizeForPath(t.Release.Label.Name),
+		"label":               t.Release.Label.Name,
+		"release":             t.Release.Name.String(),
+		"catalog_number":      t.Release.CatalogNumber.String(),
+		"year":                t.Release.Year(),
 	}
-	fileName := ParseTemplate(n.Template, templateValues)
-	return SanitizePath(fileName, n.Whitespace)
+	if len(t.Artists) > 0 {
+		templateValues["artist"] = t.Artists[0].Name
+	}
+	return templateValues
+}
+
+// Year returns the year the track was published.
+func (t *Track) Year() string {
+	var year string
+	dateParsed, err := time.Parse("2006-01-02", t.PublishDate)
+	if err == nil {
+		year = dateParsed.Format("2006")
+	}
+	return year
 }
 
-func (b *Beatport) GetTrack(id int64) (*Track, error) {
+func (b *Beatport) GetTrack(ctx context.Context, id int64) (*Track, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf("/catalog/tracks/%d/", id),
 		nil,
@@ -106,8 +224,33 @@ func (b *Beatport) GetTrack(id int64) (*Track, error) {
 	return response, nil
 }
 
-func (b *Beatport) DownloadTrack(id int64, quality string) (*TrackDownload, error) {
+// GetTracksByISRC returns the tracks with isrc, usually one, or more for
+// tracks released again.
+func (b *Beatport) GetTracksByISRC(ctx context.Context, isrc string) ([]Track, error) {
+	res, err := b.fetch(
+		ctx,
+		"GET",
+		fmt.Sprintf("/catalog/tracks/?isrc=%s&per_page=100", url.QueryEscape(isrc)),
+		nil,
+		"",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response Paginated[Track]
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	for i := range response.Results {
+		response.Results[i].Store = b.store
+	}
+	return response.Results, nil
+}
+
+func (b *Beatport) DownloadTrack(ctx context.Context, id int64, quality string) (*TrackDownload, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf(
 			"/catalog/tracks/%d/download/?quality=%s",
@@ -128,8 +271,9 @@ func (b *Beatport) DownloadTrack(id int64, quality string) (*TrackDownload, erro
 	return response, nil
 }
 
-func (b *Beatport) StreamTrack(id int64) (*TrackStream, error) {
+func (b *Beatport) StreamTrack(ctx context.Context, id int64) (*TrackStream, error) {
 	res, err := b.fetch(
+		ctx,
 		"GET",
 		fmt.Sprintf(
 			"/catalog/tracks/%d/stream/",
@@ -0,0 +1,81 @@
+package beatport
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTrackFilenameNumberByPosition(t *testing.T) {
+	track := &Track{
+		Name:          "Strobe",
+		Number:        2,
+		Release:       Release{TrackCount: 3},
+		Position:      57,
+		PositionCount: 100,
+	}
+	n := NamingPreferences{Template: "{number}. {name}"}
+
+	if got := track.Filename(n); got != "2. Strobe" {
+		t.Errorf("Filename() = %q, expected the release track number", got)
+	}
+	track.NumberByPosition = true
+	if got := track.Filename(n); got != "057. Strobe" {
+		t.Errorf("Filename() = %q, expected the position", got)
+	}
+	if number, total := track.TrackNumber(); number != 57 || total != 100 {
+		t.Errorf("TrackNumber() = %d, %d, expected 57, 100", number, total)
+	}
+}
+
+func TestTrackDirectory(t *testing.T) {
+	track := &Track{
+		Artists: Artists{{Name: "AC/DC"}, {Name: "Other"}},
+		Genre:   Genre{Name: "Hard Techno"},
+		Release: Release{Name: "Live: 1991", Date: "1991-05-01"},
+	}
+	n := NamingPreferences{Template: "FLAC/{label}/{genre}/{artist}/{year} - {release}/"}
+
+	expected := filepath.Join("FLAC", "Unknown", "Hard Techno", "ACDC", "1991 - Live 1991")
+	if got := track.Directory(n); got != expected {
+		t.Errorf("Directory() = %q, expected %q", got, expected)
+	}
+}
+
+func TestTrackTemplateKeywords(t *testing.T) {
+	track := &Track{Artists: Artists{{Name: "Artist"}}}
+	values := track.TemplateValues(NamingPreferences{})
+	for key := range values {
+		if !slices.Contains(TrackTemplateKeywords, key) {
+			t.Errorf("TrackTemplateKeywords is missing %q", key)
+		}
+	}
+	if len(values) != len(TrackTemplateKeywords) {
+		t.Errorf("TemplateValues() has %d keywords, TrackTemplateKeywords has %d", len(values), len(TrackTemplateKeywords))
+	}
+}
+
+func TestDirectoryTemplateKeywords(t *testing.T) {
+	n := NamingPreferences{}
+	tests := []struct {
+		entity   string
+		values   map[string]string
+		keywords []string
+	}{
+		{"release", (&Release{}).templateValues(n), ReleaseTemplateKeywords},
+		{"playlist", (&Playlist{}).templateValues(n), PlaylistTemplateKeywords},
+		{"chart", (&Chart{}).templateValues(n), ChartTemplateKeywords},
+		{"label", (&Label{}).templateValues(), LabelTemplateKeywords},
+		{"artist", (&Artist{}).templateValues(), ArtistTemplateKeywords},
+	}
+	for _, tt := range tests {
+		for key := range tt.values {
+			if !slices.Contains(tt.keywords, key) {
+				t.Errorf("%s template keywords are missing %q", tt.entity, key)
+			}
+		}
+		if len(tt.values) != len(tt.keywords) {
+			t.Errorf("%s has %d template values and %d keywords", tt.entity, len(tt.values), len(tt.keywords))
+		}
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+const maxPathSegmentLength = 250
+
 type SanitizedString string
 type Duration int
 type NamingPreferences struct {
@@ -55,10 +60,20 @@ func SanitizeForPath(s string) string {
 }
 
 func SanitizePath(name string, whitespace string) string {
-	if len(name) > 250 {
-		name = name[:250]
+	if len(name) > maxPathSegmentLength {
+		name = name[:maxPathSegmentLength]
+		for !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
 	}
 
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+
 	oldnew := []string{
 		"<", "",
 		">", "",
@@ -76,7 +91,8 @@ func SanitizePath(name string, whitespace string) string {
 	r := strings.NewReplacer(oldnew...)
 	name = r.Replace(name)
 
-	return strings.Join(strings.Fields(name), " ")
+	// Windows doesn't allow names ending with a dot or a space
+	return strings.TrimRight(strings.Join(strings.Fields(name), " "), ". ")
 }
 
 func NumberWithPadding(value, total, padding int) string {
@@ -86,16 +102,53 @@ func NumberWithPadding(value, total, padding int) string {
 	return fmt.Sprintf("%0*d", padding, value)
 }
 
+// templatePlaceholderRegex matches the placeholders of templates: a keyword
+// with an optional zero-padding width, like {number:03}, and an optional
+// fallback for empty values, like {catalog_number|NOCAT}.
+var templatePlaceholderRegex = regexp.MustCompile(`\{(\w+)(?::(\d+))?(\|[^{}]*)?}`)
+
+// ParseTemplate replaces the placeholders of template with values. Numbers
+// are zero-padded to the placeholder's width, and empty values are replaced
+// with its fallback. Placeholders of unknown keywords are kept as they are.
 func ParseTemplate(template string, values map[string]string) string {
-	re := regexp.MustCompile(`\{(\w+)}`)
-	result := re.ReplaceAllStringFunc(template, func(placeholder string) string {
-		key := strings.Trim(placeholder, "{}")
-		if value, found := values[key]; found {
-			return value
+	return templatePlaceholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		matches := templatePlaceholderRegex.FindStringSubmatch(placeholder)
+		value, found := values[matches[1]]
+		if value == "" {
+			if matches[3] != "" {
+				return matches[3][1:]
+			}
+			if !found {
+				return placeholder
+			}
+			return ""
+		}
+		width, _ := strconv.Atoi(matches[2])
+		if _, err := strconv.Atoi(value); err == nil && width > len(value) {
+			value = strings.Repeat("0", width-len(value)) + value
 		}
-		return placeholder
+		return value
 	})
-	return result
+}
+
+// CheckTemplate returns an error pointing at the first placeholder of
+// template that has a keyword that isn't in keywords. Braces that don't form
+// a placeholder, like {} or {a b}, are literal text, as in ParseTemplate.
+func CheckTemplate(template string, keywords []string) error {
+	for _, match := range templatePlaceholderRegex.FindAllStringSubmatchIndex(template, -1) {
+		keyword := template[match[2]:match[3]]
+		if !slices.Contains(keywords, keyword) {
+			return templateError(template, match[0], "unknown keyword "+keyword)
+		}
+	}
+	return nil
+}
+
+// templateError describes a problem at byte offset i of template, with the
+// template and a caret under the column on the lines below.
+func templateError(template string, i int, problem string) error {
+	column := utf8.RuneCountInString(template[:i]) + 1
+	return fmt.Errorf("%s at column %d\n%s\n%s^", problem, column, template, strings.Repeat(" ", column-1))
 }
 
 func storeUrl(id int64, entity, slug string, store Store) string {
@@ -0,0 +1,89 @@
+package beatport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizePath(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		whitespace string
+		expected   string
+	}{
+		{"Illegal characters", `Artist: "Title" <Remix>?`, "", "Artist Title Remix"},
+		{"Whitespace character", "Artist - Title", "_", "Artist_-_Title"},
+		{"Trailing dots", "Greatest Hits Vol.", "", "Greatest Hits Vol"},
+		{"Control characters", "Title\x00\x1f", "", "Title"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizePath(tt.input, tt.whitespace); got != tt.expected {
+				t.Errorf("SanitizePath(%q) = %q, expected %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("Truncate on rune boundary", func(t *testing.T) {
+		input := "a"
+		for len(input) < 300 {
+			input += "ö"
+		}
+		got := SanitizePath(input, "")
+		if len(got) > maxPathSegmentLength {
+			t.Errorf("Length %d exceeds %d", len(got), maxPathSegmentLength)
+		}
+		if got[len(got)-2:] != "ö" {
+			t.Errorf("Truncated in the middle of a rune: %q", got[len(got)-4:])
+		}
+	})
+}
+
+func TestParseTemplate(t *testing.T) {
+	values := map[string]string{"number": "3", "name": "Title", "catalog_number": ""}
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"{number:02} {name}", "03 Title"},
+		{"{number:01}", "3"},
+		{"{name:05}", "Title"},
+		{"[{catalog_number|NOCAT}]", "[NOCAT]"},
+		{"{name|Untitled}", "Title"},
+		{"{missing|none} {missing}", "none {missing}"},
+	}
+
+	for _, tt := range tests {
+		if got := ParseTemplate(tt.template, values); got != tt.expected {
+			t.Errorf("ParseTemplate(%q) = %q, expected %q", tt.template, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	keywords := []string{"number", "name", "catalog_number"}
+	for _, template := range []string{
+		"{number:02} {name}", "{catalog_number|NOCAT} - {name}", "no placeholders",
+		"{name} {}", "{ {name} }", "{name", "{number:x}",
+	} {
+		if err := CheckTemplate(template, keywords); err != nil {
+			t.Errorf("CheckTemplate(%q) failed: %v", template, err)
+		}
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"{number} {titel}", "unknown keyword titel at column 10\n{number} {titel}\n         ^"},
+		{"{ {titel}", "unknown keyword titel at column 3"},
+	}
+	for _, tt := range tests {
+		err := CheckTemplate(tt.template, keywords)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("CheckTemplate(%q) = %v, expected %q", tt.template, err, tt.expected)
+		}
+	}
+}
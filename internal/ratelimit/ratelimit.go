@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket refilled at rate tokens per second, holding at
+// most burst tokens. It is safe for concurrent use.
+type Limiter struct {
+	rate    float64
+	maxRate float64
+	burst   int
+	tokens  float64
+	last    time.Time
+	mutex   sync.Mutex
+}
+
+func New(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		maxRate: rate,
+		burst:   burst,
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// WaitN blocks until n tokens are available or ctx is done. Tokens are
+// reserved before waiting, so concurrent callers are served in order.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mutex.Lock()
+	l.refill()
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mutex.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Throttle halves the rate, down to a tenth of the configured rate, and
+// makes every caller wait at least delay before the next token. The rate
+// recovers linearly to the configured rate over recoveryPeriod.
+func (l *Limiter) Throttle(delay time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.refill()
+	l.rate = max(l.rate/2, l.maxRate/10)
+	l.tokens = min(l.tokens, 0) - delay.Seconds()*l.rate
+}
+
+// Rate returns the current rate in tokens per second.
+func (l *Limiter) Rate() float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.rate
+}
+
+const recoveryPeriod = time.Minute
+
+func (l *Limiter) refill() {
+	now := time.Now()
+	elapsed := now.Sub(l.last).Seconds()
+	l.tokens = min(float64(l.burst), l.tokens+elapsed*l.rate)
+	l.rate = min(l.maxRate, l.rate+l.maxRate*elapsed/recoveryPeriod.Seconds())
+	l.last = now
+}
+
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+// NewReader returns a reader that reads from r no faster than the limiter
+// allows, one token per byte.
+func NewReader(ctx context.Context, r io.Reader, limiter *Limiter) io.Reader {
+	return &reader{
+		ctx:     ctx,
+		r:       r,
+		limiter: limiter,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	limiter := New(100, 1)
+	limiter.Throttle(50 * time.Millisecond)
+
+	if rate := limiter.Rate(); rate > 51 {
+		t.Fatalf("rate after throttle = %v, want about 50", rate)
+	}
+
+	start := time.Now()
+	if err := limiter.WaitN(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("waited %v, want at least 50ms", elapsed)
+	}
+
+	for range 10 {
+		limiter.Throttle(0)
+	}
+	if rate := limiter.Rate(); rate < 10 {
+		t.Fatalf("rate = %v, want no less than a tenth of the configured rate", rate)
+	}
+}
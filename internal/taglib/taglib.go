@@ -97,6 +97,16 @@ func (f *File) SetProperty(property string, value *string) {
 	C.taglib_property_set(f.fp, propertyC, valueC)
 }
 
+// AppendProperty adds value to the values of property, for tags with
+// several values like one ARTIST per artist.
+func (f *File) AppendProperty(property string, value string) {
+	propertyC := getCCharPointer(property)
+	defer C.free(unsafe.Pointer(propertyC))
+	valueC := getCCharPointer(value)
+	defer C.free(unsafe.Pointer(valueC))
+	C.taglib_property_set_append(f.fp, propertyC, valueC)
+}
+
 func (f *File) PropertyKeys() ([]string, error) {
 	keysC := C.taglib_property_keys(f.fp)
 	defer C.taglib_property_free(keysC)